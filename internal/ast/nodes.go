@@ -41,6 +41,17 @@ func (n InterpolationNode) node() {}
 // Pos returns the source position of the node.
 func (n InterpolationNode) Pos() Position { return n.Position }
 
+// CommentNode stores the text of a <#-- ... --> comment.
+type CommentNode struct {
+	Position Position
+	Text     string
+}
+
+func (n CommentNode) node() {}
+
+// Pos returns the source position of the node.
+func (n CommentNode) Pos() Position { return n.Position }
+
 // IfElseIf represents one elseif branch in an if block.
 type IfElseIf struct {
 	Position Position
@@ -75,12 +86,24 @@ func (n ListNode) node() {}
 // Pos returns the source position of the node.
 func (n ListNode) Pos() Position { return n.Position }
 
-// AssignNode represents an <#assign ...> or <#local ...> directive.
+// Assignment is one name=value pair of an assignment directive. Op is "=",
+// a compound operator such as "+=", or "++"/"--", which have no Expr.
+type Assignment struct {
+	Name string
+	Op   string
+	Expr string
+}
+
+// AssignNode represents an <#assign ...>, <#local ...> or <#global ...>
+// directive. The capture form <#assign x>...</#assign> has a single
+// assignment without Op and stores the output of Body.
 type AssignNode struct {
-	Position Position
-	Name     string
-	Expr     string
-	Local    bool
+	Position    Position
+	Assignments []Assignment
+	Local       bool
+	Global      bool
+	Capture     bool
+	Body        []Node
 }
 
 func (n AssignNode) node() {}
@@ -88,10 +111,94 @@ func (n AssignNode) node() {}
 // Pos returns the source position of the node.
 func (n AssignNode) Pos() Position { return n.Position }
 
-// SettingNode represents ignored FreeMarker setting directives.
-type SettingNode struct {
+// AttemptNode represents <#attempt>...<#recover>...</#attempt>: Recover
+// renders instead of Body when Body fails.
+type AttemptNode struct {
+	Position Position
+	Body     []Node
+	Recover  []Node
+}
+
+func (n AttemptNode) node() {}
+
+// Pos returns the source position of the node.
+func (n AttemptNode) Pos() Position { return n.Position }
+
+// CompressNode represents <#compress>...</#compress>, whose output has its
+// whitespace collapsed.
+type CompressNode struct {
+	Position Position
+	Body     []Node
+}
+
+func (n CompressNode) node() {}
+
+// Pos returns the source position of the node.
+func (n CompressNode) Pos() Position { return n.Position }
+
+// EscapeNode represents <#escape Var as Expr>...</#escape>, which applies
+// Expr to every interpolation of Body.
+type EscapeNode struct {
+	Position Position
+	Var      string
+	Expr     string
+	Body     []Node
+}
+
+func (n EscapeNode) node() {}
+
+// Pos returns the source position of the node.
+func (n EscapeNode) Pos() Position { return n.Position }
+
+// NoEscapeNode represents <#noescape>...</#noescape>, which turns off the
+// enclosing <#escape> blocks for Body.
+type NoEscapeNode struct {
 	Position Position
-	Raw      string
+	Body     []Node
+}
+
+func (n NoEscapeNode) node() {}
+
+// Pos returns the source position of the node.
+func (n NoEscapeNode) Pos() Position { return n.Position }
+
+// OutputFormatNode represents <#outputformat Format>...</#outputformat>.
+// Format is the unparsed argument, normally a string literal.
+type OutputFormatNode struct {
+	Position Position
+	Format   string
+	Body     []Node
+}
+
+func (n OutputFormatNode) node() {}
+
+// Pos returns the source position of the node.
+func (n OutputFormatNode) Pos() Position { return n.Position }
+
+// AutoEscNode represents <#autoesc> (Enabled) and <#noautoesc> blocks.
+type AutoEscNode struct {
+	Position Position
+	Enabled  bool
+	Body     []Node
+}
+
+func (n AutoEscNode) node() {}
+
+// Pos returns the source position of the node.
+func (n AutoEscNode) Pos() Position { return n.Position }
+
+// SettingParam is one key=value pair of a <#setting> or <#ftl> directive.
+type SettingParam struct {
+	Key   string
+	Value string
+}
+
+// SettingNode represents <#setting ...> and <#ftl ...> header directives.
+type SettingNode struct {
+	Position  Position
+	Directive string
+	Raw       string
+	Params    []SettingParam
 }
 
 func (n SettingNode) node() {}
@@ -112,7 +219,8 @@ func (n FunctionNode) node() {}
 // Pos returns the source position of the node.
 func (n FunctionNode) Pos() Position { return n.Position }
 
-// BareDirectiveNode represents directives such as <#return> and <#break>.
+// BareDirectiveNode represents directives without a body, such as <#return>,
+// <#break>, <#continue>, <#stop> and <#flush>.
 type BareDirectiveNode struct {
 	Position Position
 	Name     string
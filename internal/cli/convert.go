@@ -44,7 +44,12 @@ func runConvert(ctx context.Context, cfg config.Config) error {
 		return fmt.Errorf("no template files matched %q under %q", cfg.Glob, cfg.In)
 	}
 
-	converter := convert.NewConverter()
+	delims := convert.Delims{Left: cfg.LeftDelim, Right: cfg.RightDelim}.OrDefault()
+	converter := convert.NewConverterWithOptions(convert.Options{
+		StrictBooleans:   cfg.StrictBooleans,
+		PreserveComments: cfg.PreserveComments,
+		Delims:           delims,
+	})
 	var (
 		converted        int
 		conversionFailed int
@@ -93,7 +98,7 @@ func runConvert(ctx context.Context, cfg config.Config) error {
 		item.FeaturesDetected = append(item.FeaturesDetected, result.Features...)
 		item.HelpersRequired = append(item.HelpersRequired, result.Helpers...)
 
-		if err := templatecheck.ParseConvertedTemplate(f.RelPath, result.Output); err != nil {
+		if err := templatecheck.ParseConvertedTemplateWithDelims(f.RelPath, result.Output, result.Delims); err != nil {
 			parseFailed++
 			item.Status = report.StatusParseError
 			item.Diagnostics = []report.DiagnosticItem{report.ToDiagnosticItem(f.RelPath, err)}
@@ -111,7 +116,7 @@ func runConvert(ctx context.Context, cfg config.Config) error {
 			samplePath := rendercheck.SamplePath(cfg.SamplesRoot, f.RelPath)
 			item.RenderChecked = true
 			item.SamplePath = samplePath
-			status, htmlOutput, renderErr := rendercheck.RenderConvertedTemplate(f.RelPath, result.Output, samplePath)
+			status, htmlOutput, renderErr := rendercheck.RenderConvertedTemplateWithDelims(f.RelPath, result.Output, samplePath, result.Delims)
 			if renderErr != nil {
 				renderFailed++
 				item.Status = report.StatusRenderError
@@ -195,6 +200,8 @@ func runConvert(ctx context.Context, cfg config.Config) error {
 		RenderFailed:     renderFailed,
 		NoSample:         noSample,
 		HelpersNeeded:    helperList,
+		LeftDelim:        delims.Left,
+		RightDelim:       delims.Right,
 	}
 
 	if err := writeReports(cfg, summary, fileItems); err != nil {
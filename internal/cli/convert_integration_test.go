@@ -116,6 +116,84 @@ func TestRunConvertRenderCheckMissingSampleIsNonFatal(t *testing.T) {
 	require.Empty(t, rep.Files[0].RenderedPath)
 }
 
+func TestRunConvertStrictBooleansReportsNonBooleanConditions(t *testing.T) {
+	root := t.TempDir()
+	in := filepath.Join(root, "in")
+	out := filepath.Join(root, "out")
+	samples := filepath.Join(root, "samples")
+	require.NoError(t, os.MkdirAll(in, 0o755))
+	require.NoError(t, os.MkdirAll(samples, 0o755))
+
+	mustWrite(t, filepath.Join(in, "mail.ftl"), `<#if name>Hello ${name}</#if>`)
+	mustWrite(t, filepath.Join(samples, "mail.ftl.json"), `{"name":"Ada"}`)
+
+	jsonReport := filepath.Join(root, "report.json")
+
+	cfg := config.Default()
+	cfg.In = in
+	cfg.Out = out
+	cfg.RenderCheck = true
+	cfg.SamplesRoot = samples
+	cfg.ReportJSON = jsonReport
+
+	require.NoError(t, runConvert(context.Background(), cfg))
+
+	cfg.StrictBooleans = true
+	err := runConvert(context.Background(), cfg)
+	var exitErr *ExitError
+	require.True(t, errors.As(err, &exitErr))
+	require.Equal(t, ExitCodeValidationFailed, exitErr.Code)
+
+	raw, err := os.ReadFile(jsonReport)
+	require.NoError(t, err)
+	var rep report.JSONReport
+	require.NoError(t, json.Unmarshal(raw, &rep))
+	require.Equal(t, 1, rep.Summary.RenderFailed)
+	require.Len(t, rep.Files, 1)
+	require.Equal(t, report.StatusRenderError, rep.Files[0].Status)
+	require.Contains(t, rep.Files[0].HelpersRequired, "asBool")
+	require.Len(t, rep.Files[0].Diagnostics, 1)
+	require.Equal(t, "RENDER_NON_BOOLEAN_CONDITION", rep.Files[0].Diagnostics[0].Code)
+	require.Contains(t, rep.Files[0].Diagnostics[0].Message, "expected a boolean, got string")
+}
+
+func TestRunConvertCustomDelimsAreReported(t *testing.T) {
+	root := t.TempDir()
+	in := filepath.Join(root, "in")
+	out := filepath.Join(root, "out")
+	samples := filepath.Join(root, "samples")
+	require.NoError(t, os.MkdirAll(in, 0o755))
+	require.NoError(t, os.MkdirAll(samples, 0o755))
+
+	mustWrite(t, filepath.Join(in, "mail.ftl"), `<p v-if="ok">{{ label }} ${name}</p>`)
+	mustWrite(t, filepath.Join(samples, "mail.ftl.json"), `{"name":"Ada"}`)
+
+	cfg := config.Default()
+	cfg.In = in
+	cfg.Out = out
+	cfg.RenderCheck = true
+	cfg.SamplesRoot = samples
+	cfg.LeftDelim = "[["
+	cfg.RightDelim = "]]"
+	cfg.ReportJSON = filepath.Join(root, "report.json")
+
+	require.NoError(t, runConvert(context.Background(), cfg))
+
+	converted, err := os.ReadFile(filepath.Join(out, "mail.gotmpl"))
+	require.NoError(t, err)
+	require.Equal(t, `<p v-if="ok">{{ label }} [[interpolate .name]]</p>`, string(converted))
+	rendered, err := os.ReadFile(filepath.Join(out, "mail.rendered.html"))
+	require.NoError(t, err)
+	require.Equal(t, `<p v-if="ok">{{ label }} Ada</p>`, string(rendered))
+
+	raw, err := os.ReadFile(cfg.ReportJSON)
+	require.NoError(t, err)
+	var rep report.JSONReport
+	require.NoError(t, json.Unmarshal(raw, &rep))
+	require.Equal(t, "[[", rep.Summary.LeftDelim)
+	require.Equal(t, "]]", rep.Summary.RightDelim)
+}
+
 func TestRunConvertRenderCheckInvalidSampleReturnsExitCode3(t *testing.T) {
 	root := t.TempDir()
 	in := filepath.Join(root, "in")
@@ -201,8 +279,8 @@ func TestRunConvertRenderCheckMissingPathSafeAccess(t *testing.T) {
 	require.NoError(t, os.MkdirAll(in, 0o755))
 	require.NoError(t, os.MkdirAll(samples, 0o755))
 
-	mustWrite(t, filepath.Join(in, "mail.ftl"), `exists=${product.color??};fallback=${product.color!"blue"}`)
-	mustWrite(t, filepath.Join(samples, "mail.ftl.json"), `{}`)
+	mustWrite(t, filepath.Join(in, "mail.ftl"), `exists=${(product.color??)?c};fallback=${product.color!"blue"};deep=${(order.product.color)!"red"}`)
+	mustWrite(t, filepath.Join(samples, "mail.ftl.json"), `{"product":{}}`)
 
 	cfg := config.Default()
 	cfg.In = in
@@ -222,7 +300,7 @@ func TestRunConvertRenderCheckMissingPathSafeAccess(t *testing.T) {
 
 	rendered, err := os.ReadFile(renderedPath)
 	require.NoError(t, err)
-	require.Equal(t, "exists=false;fallback=blue", strings.TrimSpace(string(rendered)))
+	require.Equal(t, "exists=false;fallback=blue;deep=red", strings.TrimSpace(string(rendered)))
 
 	type typedProduct struct {
 		Name string
@@ -233,7 +311,11 @@ func TestRunConvertRenderCheckMissingPathSafeAccess(t *testing.T) {
 	var buf bytes.Buffer
 	err = tpl.Execute(&buf, map[string]any{"product": typedProduct{Name: "shirt"}})
 	require.NoError(t, err)
-	require.Equal(t, "exists=false;fallback=blue", strings.TrimSpace(buf.String()))
+	require.Equal(t, "exists=false;fallback=blue;deep=red", strings.TrimSpace(buf.String()))
+
+	buf.Reset()
+	err = tpl.Execute(&buf, map[string]any{})
+	require.ErrorContains(t, err, "product is missing; only the last step before ! or ?? may be missing")
 }
 
 func TestRunConvertUnsupportedFunctionReturnsExitCode2(t *testing.T) {
@@ -27,6 +27,10 @@ func NewRootCmd() *cobra.Command {
 	cmd.Flags().BoolVar(&cfg.RenderCheck, "render-check", cfg.RenderCheck, "Enable render checks (M3)")
 	cmd.Flags().StringVar(&cfg.SamplesRoot, "samples-root", cfg.SamplesRoot, "Path to sample JSON root")
 	cmd.Flags().BoolVar(&cfg.Strict, "strict", cfg.Strict, "Enable strict conversion behavior")
+	cmd.Flags().BoolVar(&cfg.StrictBooleans, "strict-booleans", cfg.StrictBooleans, "Fail render checks when <#if> conditions are not booleans")
+	cmd.Flags().BoolVar(&cfg.PreserveComments, "preserve-comments", cfg.PreserveComments, "Keep FreeMarker comments as Go template comments")
+	cmd.Flags().StringVar(&cfg.LeftDelim, "left-delim", "", "Left action delimiter of converted templates (default {{)")
+	cmd.Flags().StringVar(&cfg.RightDelim, "right-delim", "", "Right action delimiter of converted templates (default }})")
 	cmd.Flags().StringVar(&cfg.ReportJSON, "report-json", "", "Optional JSON report output path")
 	cmd.Flags().StringVar(&cfg.ReportCSV, "report-csv", "", "Optional CSV report output path")
 
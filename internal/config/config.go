@@ -25,8 +25,13 @@ type Config struct {
 	ReportJSON string
 	ReportCSV  string
 
-	RenderCheck bool
-	Strict      bool
+	RenderCheck      bool
+	Strict           bool
+	StrictBooleans   bool
+	PreserveComments bool
+
+	LeftDelim  string
+	RightDelim string
 }
 
 // Default returns baseline configuration values used by CLI flags.
@@ -0,0 +1,184 @@
+package convert
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/cruffinoni/ftl2gotpl/internal/decimal"
+)
+
+// divisionMinScale is the minimum number of fraction digits FreeMarker's
+// BigDecimal arithmetic engine keeps when dividing.
+const divisionMinScale = 12
+
+// toDecimal converts a value to an exact decimal. Floats use their shortest
+// decimal representation, as Java's Double.toString does.
+func toDecimal(v any) (decimal.Decimal, error) {
+	n, err := toNumber(v)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	switch t := n.(type) {
+	case int64:
+		return decimal.FromInt64(t), nil
+	case float64:
+		return decimal.FromFloat64(t)
+	case decimal.Decimal:
+		return t, nil
+	default:
+		return decimal.Decimal{}, fmt.Errorf("unsupported numeric type %T", n)
+	}
+}
+
+// decimalOperands converts both operands of a binary arithmetic operator.
+func decimalOperands(op string, a any, b any) (decimal.Decimal, decimal.Decimal, error) {
+	left, err := toDecimal(a)
+	if err != nil {
+		return decimal.Decimal{}, decimal.Decimal{}, fmt.Errorf("left operand of %q: %w", op, err)
+	}
+	right, err := toDecimal(b)
+	if err != nil {
+		return decimal.Decimal{}, decimal.Decimal{}, fmt.Errorf("right operand of %q: %w", op, err)
+	}
+	return left, right, nil
+}
+
+// addValues implements FreeMarker's + operator: string concatenation when
+// either operand is a string, sequence concatenation, hash merging, and
+// exact numeric addition otherwise.
+func addValues(a any, b any, args ...any) (any, error) {
+	args, settings := splitSettingsArg(args)
+	if len(args) != 0 {
+		return nil, fmt.Errorf("add expects two operands")
+	}
+	a, b = indirect(a), indirect(b)
+	_, leftText := a.(string)
+	_, rightText := b.(string)
+	if leftText || rightText {
+		left, err := concatOperand(a, settings)
+		if err != nil {
+			return nil, err
+		}
+		right, err := concatOperand(b, settings)
+		if err != nil {
+			return nil, err
+		}
+		return left + right, nil
+	}
+	if left, ok := a.(map[string]any); ok {
+		right, ok := b.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("cannot add %T to a hash", b)
+		}
+		merged := make(map[string]any, len(left)+len(right))
+		for k, v := range left {
+			merged[k] = v
+		}
+		for k, v := range right {
+			merged[k] = v
+		}
+		return merged, nil
+	}
+	if isSequence(a) || isSequence(b) {
+		if !isSequence(a) || !isSequence(b) {
+			return nil, fmt.Errorf("cannot add %T and %T", a, b)
+		}
+		left, right := reflect.ValueOf(a), reflect.ValueOf(b)
+		joined := make([]any, 0, left.Len()+right.Len())
+		for i := 0; i < left.Len(); i++ {
+			joined = append(joined, left.Index(i).Interface())
+		}
+		for i := 0; i < right.Len(); i++ {
+			joined = append(joined, right.Index(i).Interface())
+		}
+		return joined, nil
+	}
+	left, right, err := decimalOperands("+", a, b)
+	if err != nil {
+		return nil, err
+	}
+	return normalizeDecimal(left.Add(right)), nil
+}
+
+// concatOperand renders one side of a string concatenation.
+func concatOperand(v any, settings *formatSettings) (string, error) {
+	if v == nil {
+		return "", fmt.Errorf("cannot concatenate a missing value")
+	}
+	if _, ok := v.(bool); ok && (settings == nil || settings.booleanFormat == "") {
+		return "", fmt.Errorf("cannot concatenate a boolean without boolean_format")
+	}
+	formatted, err := formatWithSettings(v, settings)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprint(formatted), nil
+}
+
+func isSequence(v any) bool {
+	if v == nil {
+		return false
+	}
+	kind := reflect.ValueOf(v).Kind()
+	return kind == reflect.Slice || kind == reflect.Array
+}
+
+// subtractValues implements FreeMarker's binary - operator.
+func subtractValues(a any, b any) (any, error) {
+	left, right, err := decimalOperands("-", a, b)
+	if err != nil {
+		return nil, err
+	}
+	return normalizeDecimal(left.Sub(right)), nil
+}
+
+// multiplyValues implements FreeMarker's * operator.
+func multiplyValues(a any, b any) (any, error) {
+	left, right, err := decimalOperands("*", a, b)
+	if err != nil {
+		return nil, err
+	}
+	return normalizeDecimal(left.Mul(right)), nil
+}
+
+// divideValues implements FreeMarker's / operator, keeping at least
+// divisionMinScale fraction digits and rounding half up.
+func divideValues(a any, b any) (any, error) {
+	left, right, err := decimalOperands("/", a, b)
+	if err != nil {
+		return nil, err
+	}
+	scale := max(int32(divisionMinScale), left.Scale(), right.Scale())
+	quotient, err := left.Quo(right, scale, decimal.HalfUp)
+	if err != nil {
+		return nil, err
+	}
+	return normalizeDecimal(quotient), nil
+}
+
+// moduloValues implements FreeMarker's % operator, which truncates both
+// operands to integers and keeps the sign of the dividend like Java's %.
+func moduloValues(a any, b any) (any, error) {
+	left, right, err := decimalOperands("%", a, b)
+	if err != nil {
+		return nil, err
+	}
+	left, right = left.Round(0, decimal.Down), right.Round(0, decimal.Down)
+	if right.Sign() == 0 {
+		return nil, fmt.Errorf("division by zero")
+	}
+	quotient, err := left.Quo(right, 0, decimal.Down)
+	if err != nil {
+		return nil, err
+	}
+	return normalizeDecimal(left.Sub(quotient.Mul(right))), nil
+}
+
+// negateValue implements FreeMarker's unary - operator.
+func negateValue(v any) (any, error) {
+	d, err := toDecimal(v)
+	if err != nil {
+		return nil, fmt.Errorf("operand of unary \"-\": %w", err)
+	}
+	return normalizeDecimal(d.Neg()), nil
+}
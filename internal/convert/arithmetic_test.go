@@ -0,0 +1,79 @@
+package convert
+
+import (
+	"testing"
+
+	"github.com/cruffinoni/ftl2gotpl/internal/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArithmeticHelpersAreExact(t *testing.T) {
+	add := func(a any, b any) (any, error) { return addValues(a, b) }
+	tests := []struct {
+		name string
+		fn   func(any, any) (any, error)
+		a, b any
+		want any
+	}{
+		{"money product", multiplyValues, decimal.MustParse("19.99"), int64(3), decimal.MustParse("59.97")},
+		{"float sum", add, 0.1, 0.2, decimal.MustParse("0.3")},
+		{"integral result", subtractValues, "10.50", "0.5", int64(10)},
+		{"int overflow", add, int64(9223372036854775807), int64(1), decimal.MustParse("9223372036854775808")},
+		{"division scale", divideValues, int64(10), int64(3), decimal.MustParse("3.333333333333")},
+		{"division half up", divideValues, int64(2), int64(3), decimal.MustParse("0.666666666667")},
+		{"exact division", divideValues, int64(10), int64(4), decimal.MustParse("2.500000000000")},
+		{"modulo", moduloValues, int64(17), int64(5), int64(2)},
+		{"modulo truncates", moduloValues, -7.9, 2.5, int64(-1)},
+	}
+	for _, tc := range tests {
+		got, err := tc.fn(tc.a, tc.b)
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, tc.want, got, tc.name)
+	}
+
+	got, err := negateValue("1.50")
+	require.NoError(t, err)
+	assert.Equal(t, decimal.MustParse("-1.50"), got)
+
+	for _, tc := range []struct {
+		fn   func(any, any) (any, error)
+		a, b any
+	}{
+		{divideValues, int64(1), int64(0)},
+		{moduloValues, int64(1), 0.5},
+		{multiplyValues, "abc", int64(1)},
+		{subtractValues, true, int64(1)},
+	} {
+		_, err := tc.fn(tc.a, tc.b)
+		assert.Error(t, err)
+	}
+}
+
+func TestAddValuesConcatenation(t *testing.T) {
+	got, err := addValues("Total: ", decimal.MustParse("1234.5"))
+	require.NoError(t, err)
+	assert.Equal(t, "Total: 1,234.5", got)
+
+	got, err = addValues(int64(3), " items", &formatSettings{locale: "fr_FR"})
+	require.NoError(t, err)
+	assert.Equal(t, "3 items", got)
+
+	got, err = addValues("ok: ", true, &formatSettings{booleanFormat: "yes,no"})
+	require.NoError(t, err)
+	assert.Equal(t, "ok: yes", got)
+
+	_, err = addValues("ok: ", true)
+	assert.Error(t, err)
+
+	got, err = addValues([]any{"a"}, []string{"b", "c"})
+	require.NoError(t, err)
+	assert.Equal(t, []any{"a", "b", "c"}, got)
+
+	got, err = addValues(map[string]any{"a": 1, "b": 2}, map[string]any{"b": 3})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"a": 1, "b": 3}, got)
+
+	_, err = addValues([]any{"a"}, int64(1))
+	assert.Error(t, err)
+}
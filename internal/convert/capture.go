@@ -0,0 +1,129 @@
+package convert
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+)
+
+// captureScope is the data a capture block executes with: the data model and
+// the local variables visible where the block was assigned.
+type captureScope struct {
+	data   any
+	locals map[string]any
+	// assigned is shared by copies of the scope, so the values a block
+	// records can be read after it ran.
+	assigned map[string]any
+}
+
+// Data returns the data model as a one-item sequence, so the block ranges
+// over it once to restore dot.
+func (s captureScope) Data() []any {
+	return []any{s.data}
+}
+
+// Root returns the data model, for capture blocks nested in this one.
+func (s captureScope) Root() any {
+	return s.data
+}
+
+// Local returns the value of a captured local variable.
+func (s captureScope) Local(name string) any {
+	return s.locals[name]
+}
+
+// Assign records the value a block gave a variable, to be copied back into
+// the template after the block. It prints nothing.
+func (s captureScope) Assign(name string, value any) string {
+	s.assigned[name] = value
+	return ""
+}
+
+// Assigned returns the value the block gave a variable, or current when the
+// block recorded none, for example because it failed.
+func (s captureScope) Assigned(name string, current any) any {
+	if value, ok := s.assigned[name]; ok {
+		return value
+	}
+	return current
+}
+
+// newCaptureScope implements captureScope: the data model followed by
+// name/value pairs of local variables.
+func newCaptureScope(data any, pairs ...any) (captureScope, error) {
+	if len(pairs)%2 != 0 {
+		return captureScope{}, fmt.Errorf("captureScope expects name/value pairs")
+	}
+	scope := captureScope{data: data, locals: make(map[string]any, len(pairs)/2), assigned: map[string]any{}}
+	for i := 0; i < len(pairs); i += 2 {
+		name, ok := pairs[i].(string)
+		if !ok {
+			return captureScope{}, fmt.Errorf("captureScope name must be a string, got %T", pairs[i])
+		}
+		scope.locals[name] = pairs[i+1]
+	}
+	return scope, nil
+}
+
+// unboundCapture is the capture helper of a FuncMap that is not bound to a
+// template yet.
+func unboundCapture(name string, _ captureScope) (template.HTML, error) {
+	return "", fmt.Errorf("capture %q: call BindTemplate on the parsed template before executing it", name)
+}
+
+// attemptResult is the outcome of an <#attempt> block: its output, or the
+// error that made it fail.
+type attemptResult struct {
+	output template.HTML
+	err    error
+}
+
+// Failed reports whether the attempt block failed.
+func (r attemptResult) Failed() bool {
+	return r.err != nil
+}
+
+// Output returns what the attempt block rendered when it succeeded.
+func (r attemptResult) Output() template.HTML {
+	return r.output
+}
+
+// Message returns the error message of a failed attempt block, for .error.
+func (r attemptResult) Message() string {
+	if r.err == nil {
+		return ""
+	}
+	return r.err.Error()
+}
+
+// unboundTryTemplate is the tryTemplate helper of a FuncMap that is not bound
+// to a template yet.
+func unboundTryTemplate(name string, _ captureScope) (attemptResult, error) {
+	return attemptResult{}, fmt.Errorf("tryTemplate %q: call BindTemplate on the parsed template before executing it", name)
+}
+
+// BindTemplate binds the capture and tryTemplate helpers to t, so
+// <#assign x>...</#assign> and <#attempt> blocks can render their define.
+// Call it after parsing a converted template and before executing it; it
+// returns t.
+func BindTemplate(t *template.Template) *template.Template {
+	return t.Funcs(template.FuncMap{
+		"capture": func(name string, scope captureScope) (template.HTML, error) {
+			var buf strings.Builder
+			if err := t.ExecuteTemplate(&buf, name, scope); err != nil {
+				return "", err
+			}
+			// The block was escaped by html/template when it rendered.
+			return template.HTML(buf.String()), nil
+		},
+		"tryTemplate": func(name string, scope captureScope) attemptResult {
+			// Output is buffered so a failed block leaves nothing behind,
+			// like FreeMarker's attempt.
+			var buf strings.Builder
+			if err := t.ExecuteTemplate(&buf, name, scope); err != nil {
+				return attemptResult{err: err}
+			}
+			return attemptResult{output: template.HTML(buf.String())}
+		},
+	})
+}
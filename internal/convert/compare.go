@@ -0,0 +1,134 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"reflect"
+
+	"github.com/cruffinoni/ftl2gotpl/internal/decimal"
+)
+
+// isNumeric reports whether v is a number value. Unlike toNumber it rejects
+// numeric strings, which FreeMarker never compares as numbers.
+func isNumeric(v any) bool {
+	switch indirect(v).(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64,
+		float32, float64, decimal.Decimal, json.Number:
+		return true
+	}
+	return false
+}
+
+// valueTypeName describes a value with FreeMarker's type names for errors.
+func valueTypeName(v any) string {
+	v = indirect(v)
+	if v == nil {
+		return "missing value"
+	}
+	if _, kind, ok := asDatetime(v); ok {
+		return kind.String()
+	}
+	if isNumeric(v) {
+		return "number"
+	}
+	switch v.(type) {
+	case string, template.HTML:
+		return "string"
+	case bool:
+		return "boolean"
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Slice, reflect.Array:
+		return "sequence"
+	case reflect.Map, reflect.Struct:
+		return "hash"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// compareValues orders a and b following FreeMarker's comparison rules:
+// numbers compare by value whatever their Go type, datetimes of the same kind
+// by instant, and strings and booleans only for equality. Any other pairing
+// is an error, as it is in FreeMarker.
+func compareValues(op string, a any, b any) (int, error) {
+	a, b = indirect(a), indirect(b)
+	relational := op != "==" && op != "!="
+	if a != nil && b != nil {
+		if isNumeric(a) && isNumeric(b) {
+			left, right, err := decimalOperands(op, a, b)
+			if err != nil {
+				return 0, err
+			}
+			return left.Cmp(right), nil
+		}
+		if left, leftKind, ok := asDatetime(a); ok {
+			if right, rightKind, ok := asDatetime(b); ok {
+				if leftKind != rightKind {
+					return 0, fmt.Errorf("can't compare a %s with a %s", leftKind, rightKind)
+				}
+				return left.Compare(right), nil
+			}
+		}
+		if left, ok := textValue(a); ok {
+			if right, ok := textValue(b); ok {
+				if relational {
+					return 0, fmt.Errorf("can't use operator %q on string values", op)
+				}
+				return boolOrder(left == right), nil
+			}
+		}
+		if left, ok := a.(bool); ok {
+			if right, ok := b.(bool); ok {
+				if relational {
+					return 0, fmt.Errorf("can't use operator %q on boolean values", op)
+				}
+				return boolOrder(left == right), nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("can't compare %s and %s with %q", valueTypeName(a), valueTypeName(b), op)
+}
+
+// textValue returns the text of a string or pre-escaped HTML value.
+func textValue(v any) (string, bool) {
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case template.HTML:
+		return string(t), true
+	case emptyValue:
+		return "", true
+	}
+	return "", false
+}
+
+// boolOrder turns an equality result into a compareValues ordering.
+func boolOrder(equal bool) int {
+	if equal {
+		return 0
+	}
+	return 1
+}
+
+// comparisonHelpers maps FreeMarker's comparison operators to helpers.
+var comparisonHelpers = map[string]string{
+	"==": "equals",
+	"=":  "equals",
+	"!=": "notEquals",
+	"<":  "lessThan",
+	"<=": "lessOrEqual",
+	">":  "greaterThan",
+	">=": "greaterOrEqual",
+}
+
+// comparisonHelper returns a template helper applying one comparison operator.
+func comparisonHelper(op string, holds func(order int) bool) func(any, any) (bool, error) {
+	return func(a any, b any) (bool, error) {
+		order, err := compareValues(op, a, b)
+		if err != nil {
+			return false, err
+		}
+		return holds(order), nil
+	}
+}
@@ -0,0 +1,75 @@
+package convert
+
+import (
+	"encoding/json"
+	"html/template"
+	"testing"
+	"time"
+
+	"github.com/cruffinoni/ftl2gotpl/internal/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareValues(t *testing.T) {
+	noon := time.Date(2026, 3, 12, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		op   string
+		a, b any
+		want int
+	}{
+		{"int and float", "==", int64(1), 1.0, 0},
+		{"int and decimal", "<", int64(2), decimal.MustParse("2.5"), -1},
+		{"json number", ">", json.Number("10.10"), 10.09, 1},
+		{"scale ignored", "==", decimal.MustParse("1.50"), 1.5, 0},
+		{"strings", "==", "mim", "mim", 0},
+		{"html and string", "!=", template.HTML("a"), "b", 1},
+		{"booleans", "==", true, false, 1},
+		{"datetimes", "<", noon, noon.Add(time.Second), -1},
+		{"tagged dates", "==", datetimeValue{at: noon, kind: kindDate}, datetimeValue{at: noon, kind: kindDate}, 0},
+	}
+	for _, tc := range tests {
+		got, err := compareValues(tc.op, tc.a, tc.b)
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, tc.want, got, tc.name)
+	}
+
+	errors := []struct {
+		op   string
+		a, b any
+		want string
+	}{
+		{"==", int64(1), "1", `can't compare number and string with "=="`},
+		{"<", "a", "b", `can't use operator "<" on string values`},
+		{">=", true, false, `can't use operator ">=" on boolean values`},
+		{"==", nil, "x", `can't compare missing value and string with "=="`},
+		{"==", datetimeValue{at: noon, kind: kindDate}, noon, "can't compare a date with a datetime"},
+		{"!=", []any{1}, []any{1}, `can't compare sequence and sequence with "!="`},
+	}
+	for _, tc := range errors {
+		_, err := compareValues(tc.op, tc.a, tc.b)
+		assert.EqualError(t, err, tc.want)
+	}
+}
+
+func TestComparisonHelpers(t *testing.T) {
+	fm := StubFuncMap()
+	cases := map[string][2]bool{
+		"equals":         {false, true},
+		"notEquals":      {true, false},
+		"lessThan":       {true, false},
+		"lessOrEqual":    {true, true},
+		"greaterThan":    {false, false},
+		"greaterOrEqual": {false, true},
+	}
+	for name, want := range cases {
+		fn := fm[name].(func(any, any) (bool, error))
+		got, err := fn(int64(1), 1.5)
+		require.NoError(t, err, name)
+		assert.Equal(t, want[0], got, name)
+		got, err = fn(1.5, decimal.MustParse("1.50"))
+		require.NoError(t, err, name)
+		assert.Equal(t, want[1], got, name)
+	}
+}
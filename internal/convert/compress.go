@@ -0,0 +1,47 @@
+package convert
+
+import (
+	"html/template"
+	"strings"
+)
+
+// compressWhitespace collapses text the way FreeMarker's <#compress> does:
+// each run of whitespace becomes a line break when it holds one and a space
+// otherwise, and the first and last runs are removed.
+func compressWhitespace(text string) string {
+	var out strings.Builder
+	for i := 0; i < len(text); {
+		if !isCompressSpace(text[i]) {
+			out.WriteByte(text[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(text) && isCompressSpace(text[j]) {
+			j++
+		}
+		if i > 0 && j < len(text) {
+			if strings.ContainsAny(text[i:j], "\n\r") {
+				out.WriteByte('\n')
+			} else {
+				out.WriteByte(' ')
+			}
+		}
+		i = j
+	}
+	return out.String()
+}
+
+func isCompressSpace(ch byte) bool {
+	switch ch {
+	case ' ', '\t', '\n', '\r', '\f', '\v':
+		return true
+	}
+	return false
+}
+
+// compressOutput implements the compress helper over the rendered body of a
+// <#compress> block, which html/template already escaped.
+func compressOutput(body template.HTML) template.HTML {
+	return template.HTML(compressWhitespace(string(body)))
+}
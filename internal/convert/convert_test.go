@@ -1,7 +1,13 @@
 package convert
 
 import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"io"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -12,7 +18,7 @@ func TestConvertIfAndInterpolation(t *testing.T) {
 	got, err := c.Convert("sample.ftl", input)
 	require.NoError(t, err)
 
-	want := `{{if eq .client_id "mim"}}Hi {{.user.name}}{{else}}Bye{{end}}`
+	want := `{{if equals .client_id "mim"}}Hi {{interpolate .user.name}}{{else}}Bye{{end}}`
 	require.Equal(t, want, got.Output)
 }
 
@@ -22,7 +28,7 @@ func TestConvertListLocalVar(t *testing.T) {
 	got, err := c.Convert("sample.ftl", input)
 	require.NoError(t, err)
 
-	want := `{{range $user_index, $user := .users}}{{$user.name}}{{end}}`
+	want := `{{range $user_index, $user := .users}}{{interpolate $user.name}}{{end}}`
 	require.Equal(t, want, got.Output)
 }
 
@@ -39,7 +45,566 @@ func TestConvertFormatPriceFunctionUsesStubHelper(t *testing.T) {
 	got, err := c.Convert("sample.ftl", input)
 	require.NoError(t, err)
 
-	want := `{{/* ftl function formatPrice ignored: using helper stub */}}{{formatPrice (default "" (safeAccess . "ad" "price"))}}`
+	want := `{{/* ftl function formatPrice ignored: using helper stub */}}{{formatPrice (default "" (safeAccessLast . "ad" "price"))}}`
 	require.Equal(t, want, got.Output)
-	require.Equal(t, []string{"default", "formatPrice", "safeAccess"}, got.Helpers)
+	require.Equal(t, []string{"default", "formatPrice", "safeAccessLast"}, got.Helpers)
+}
+
+func TestConvertBooleanFormatSetting(t *testing.T) {
+	c := NewConverter()
+	input := `<#setting boolean_format="yes,no">${flag?string}|${flag?string("on", "off")}`
+	got, err := c.Convert("sample.ftl", input)
+	require.NoError(t, err)
+
+	want := `{{toString .flag (ftlSettings "boolean_format" "yes,no")}}|{{formatBoolean .flag "on" "off"}}`
+	require.Equal(t, want, got.Output)
+
+	_, err = c.Convert("sample.ftl", `<#setting boolean_format="yes">`)
+	require.Error(t, err)
+
+	got, err = c.Convert("sample.ftl", `${flag}|${flag?string}<#setting boolean_format="yes,no">|${flag}`)
+	require.NoError(t, err)
+	tpl, err := template.New("sample").Funcs(StubFuncMap()).Parse(got.Output)
+	require.NoError(t, err)
+	err = tpl.Execute(io.Discard, map[string]any{"flag": true})
+	require.ErrorContains(t, err, "cannot print a boolean without boolean_format")
+
+	got, err = c.Convert("sample.ftl", `${flag?string}<#setting boolean_format="yes,no">|${flag}`)
+	require.NoError(t, err)
+	tpl, err = template.New("sample").Funcs(StubFuncMap()).Parse(got.Output)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, tpl.Execute(&buf, map[string]any{"flag": true}))
+	require.Equal(t, "true|yes", buf.String())
+}
+
+func TestConvertNestedListLoopState(t *testing.T) {
+	c := NewConverter()
+	input := `<#list rows as row><#list row.cells as cell>${cell}<#if cell?has_next>,</#if></#list><#if row?has_next>;</#if></#list>`
+	got, err := c.Convert("sample.ftl", input)
+	require.NoError(t, err)
+
+	want := `{{$row_length := len .rows}}{{range $row_index, $row := .rows}}` +
+		`{{$cell_length := len $row.cells}}{{range $cell_index, $cell := $row.cells}}{{interpolate $cell}}{{if loopHasNext $cell_index $cell_length}},{{end}}{{end}}` +
+		`{{if loopHasNext $row_index $row_length}};{{end}}{{end}}`
+	require.Equal(t, want, got.Output)
+
+	tpl, err := template.New("sample").Funcs(StubFuncMap()).Parse(got.Output)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	data := map[string]any{"rows": []any{
+		map[string]any{"cells": []any{"a", "b"}},
+		map[string]any{"cells": []any{"c"}},
+	}}
+	require.NoError(t, tpl.Execute(&buf, data))
+	require.Equal(t, "a,b;c", buf.String())
+}
+
+func TestConvertSettingsThreadedThroughEmission(t *testing.T) {
+	c := NewConverter()
+	input := `<#ftl encoding="UTF-8" output_format="HTML"><#setting number_format="0.00">${price}|${price?string("#")}|${name?trim}`
+	got, err := c.Convert("sample.ftl", input)
+	require.NoError(t, err)
+
+	want := `{{/* ftl header ignored: encoding="UTF-8" */}}` +
+		`{{interpolate .price (ftlSettings "number_format" "0.00")}}|` +
+		`{{toString .price "#" (ftlSettings "number_format" "0.00")}}|` +
+		`{{trim .name}}`
+	require.Equal(t, want, got.Output)
+
+	tpl, err := template.New("sample").Funcs(StubFuncMap()).Parse(got.Output)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, tpl.Execute(&buf, map[string]any{"price": 13.5, "name": " x "}))
+	require.Equal(t, "13.50|14|x", buf.String())
+}
+
+func TestConvertHeaderEscapingSettings(t *testing.T) {
+	for input, want := range map[string]string{
+		`<#ftl output_format="plainText">${a}`:                   "<a>",
+		`<#ftl output_format="HTML" auto_esc=false>${a}`:         "<a>",
+		`<#ftl auto_esc=true>${a}<#noautoesc>${a}</#noautoesc>`:  "&lt;a&gt;<a>",
+		`<#ftl output_format="XHTML">${a}`:                       "&lt;a&gt;",
+		`<#noautoesc><#setting locale="de_DE">${a}</#noautoesc>`: "<a>",
+	} {
+		got, err := NewConverter().Convert("sample.ftl", input)
+		require.NoError(t, err, input)
+		tpl, err := template.New("sample").Funcs(StubFuncMap()).Parse(got.Output)
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		require.NoError(t, tpl.Execute(&buf, map[string]any{"a": "<a>"}))
+		require.Equal(t, want, buf.String(), input)
+	}
+}
+
+func TestConvertUnsupportedSettingsReportDiagnostics(t *testing.T) {
+	tests := map[string]string{
+		"unknown key":          `<#setting classic_compatible=true>`,
+		"unsupported locale":   `<#setting locale="xx_YY">`,
+		"unsupported region":   `<#setting locale="de_CH">`,
+		"invalid number":       `<#setting number_format="abc">`,
+		"unknown time zone":    `<#setting time_zone="Mars/Olympus">`,
+		"non literal value":    `<#setting locale=userLocale>`,
+		"javascript output":    `<#ftl output_format="JavaScript">`,
+		"invalid auto_esc":     `<#ftl auto_esc="no">`,
+		"setting inside block": `<#if x><#setting number_format="0"></#if>`,
+	}
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewConverter().Convert("sample.ftl", input)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestConvertInterpolationDefaultNumberFormat(t *testing.T) {
+	c := NewConverter()
+	input := `${amount}|${amount?c}|${label}`
+	got, err := c.Convert("sample.ftl", input)
+	require.NoError(t, err)
+	require.Equal(t, `{{interpolate .amount}}|{{computerString .amount}}|{{interpolate .label}}`, got.Output)
+
+	tpl, err := template.New("sample").Funcs(StubFuncMap()).Parse(got.Output)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, tpl.Execute(&buf, map[string]any{"amount": int64(1234567), "label": "1234567"}))
+	require.Equal(t, "1,234,567|1234567|1234567", buf.String())
+}
+
+func TestConvertTimeZoneSetting(t *testing.T) {
+	c := NewConverter()
+	input := `<#setting time_zone="Europe/Paris"><#setting datetime_format="HH:mm z">${sentAt}`
+	got, err := c.Convert("sample.ftl", input)
+	require.NoError(t, err)
+	require.Equal(t, `{{interpolate .sentAt (ftlSettings "datetime_format" "HH:mm z" "time_zone" "Europe/Paris")}}`, got.Output)
+
+	tpl, err := template.New("sample").Funcs(StubFuncMap()).Parse(got.Output)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, tpl.Execute(&buf, map[string]any{"sentAt": time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC)}))
+	require.Equal(t, "14:00 CEST", buf.String())
+}
+
+func TestConvertDecimalArithmetic(t *testing.T) {
+	c := NewConverter()
+	input := `${price * qty}|${(price * qty)?c}|${total / 3}|${"Sum: " + (price + 0.01)}`
+	got, err := c.Convert("sample.ftl", input)
+	require.NoError(t, err)
+
+	tpl, err := template.New("sample").Funcs(StubFuncMap()).Parse(got.Output)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	data := map[string]any{"price": json.Number("19.99"), "qty": int64(1000), "total": int64(10)}
+	require.NoError(t, tpl.Execute(&buf, data))
+	require.Equal(t, "19,990|19990|3.333|Sum: 20", buf.String())
+}
+
+func TestConvertNumericComparisonsMixTypes(t *testing.T) {
+	c := NewConverter()
+	input := `<#if count == 1.0>one</#if>|<#if price < 20>cheap</#if>|<#if name != "x">named</#if>`
+	got, err := c.Convert("sample.ftl", input)
+	require.NoError(t, err)
+
+	tpl, err := template.New("sample").Funcs(StubFuncMap()).Parse(got.Output)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	data := map[string]any{"count": int64(1), "price": json.Number("19.99"), "name": "Ada"}
+	require.NoError(t, tpl.Execute(&buf, data))
+	require.Equal(t, "one|cheap|named", buf.String())
+
+	buf.Reset()
+	err = tpl.Execute(&buf, map[string]any{"count": "1", "price": int64(1), "name": "Ada"})
+	require.ErrorContains(t, err, `can't compare string and number with "=="`)
+}
+
+func TestConvertGreaterThanInsideTags(t *testing.T) {
+	c := NewConverter()
+	input := `<#if (count > 1)>many</#if>|<#if count gte 2>two+</#if>|<#if count lt 5 && (count >= 3)>mid</#if>`
+	got, err := c.Convert("sample.ftl", input)
+	require.NoError(t, err)
+	require.Equal(t, `{{if (greaterThan .count 1)}}many{{end}}|{{if greaterOrEqual .count 2}}two+{{end}}|{{if and (lessThan .count 5) ((greaterOrEqual .count 3))}}mid{{end}}`, got.Output)
+}
+
+func TestConvertStrictBooleans(t *testing.T) {
+	input := `<#if flag>a<#elseif count gt 1>b</#if>`
+	got, err := NewConverter().Convert("sample.ftl", input)
+	require.NoError(t, err)
+	require.Equal(t, `{{if .flag}}a{{else if greaterThan .count 1}}b{{end}}`, got.Output)
+
+	got, err = NewConverterWithOptions(Options{StrictBooleans: true}).Convert("sample.ftl", input)
+	require.NoError(t, err)
+	require.Equal(t, `{{if asBool .flag}}a{{else if asBool (greaterThan .count 1)}}b{{end}}`, got.Output)
+	require.Contains(t, got.Helpers, "asBool")
+
+	tpl, err := template.New("sample").Funcs(StubFuncMap()).Parse(got.Output)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, tpl.Execute(&buf, map[string]any{"flag": false, "count": int64(2)}))
+	require.Equal(t, "b", buf.String())
+
+	buf.Reset()
+	err = tpl.Execute(&buf, map[string]any{"flag": "", "count": int64(2)})
+	require.ErrorIs(t, err, ErrNonBooleanCondition)
+	require.ErrorContains(t, err, "expected a boolean, got string")
+}
+
+func TestConvertStrictBooleansChecksLogicalOperands(t *testing.T) {
+	got, err := NewConverterWithOptions(Options{StrictBooleans: true}).Convert("sample.ftl", `<#if name && flag>a</#if><#if !count>b</#if>`)
+	require.NoError(t, err)
+	require.Equal(t, `{{if asBool (and (asBool .name) (asBool .flag))}}a{{end}}{{if asBool (not (asBool .count))}}b{{end}}`, got.Output)
+
+	tpl, err := template.New("sample").Funcs(StubFuncMap()).Parse(got.Output)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	err = tpl.Execute(&buf, map[string]any{"name": "x", "flag": true, "count": false})
+	require.ErrorIs(t, err, ErrNonBooleanCondition, "a non-boolean && operand fails")
+
+	buf.Reset()
+	err = tpl.Execute(&buf, map[string]any{"name": true, "flag": true, "count": int64(0)})
+	require.ErrorIs(t, err, ErrNonBooleanCondition, "a non-boolean ! operand fails")
+
+	buf.Reset()
+	require.NoError(t, tpl.Execute(&buf, map[string]any{"name": true, "flag": true, "count": false}))
+	require.Equal(t, "ab", buf.String())
+}
+
+func TestConvertNumericInterpolation(t *testing.T) {
+	c := NewConverter()
+	input := `#{x; M2}|#{y; M2}|#{x; m2}|#{y; m2}|#{x; m1M2}|#{y; m1M2}|#{big}|#{x * 1000; m1}`
+	got, err := c.Convert("sample.ftl", input)
+	require.NoError(t, err)
+	require.Contains(t, got.Output, `{{formatNumeric .x 0 2}}|{{formatNumeric .y 0 2}}|{{formatNumeric .x 2 2}}`)
+	require.Contains(t, got.Helpers, "formatNumeric")
+
+	tpl, err := template.New("sample").Funcs(StubFuncMap()).Parse(got.Output)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, tpl.Execute(&buf, map[string]any{"x": 2.582, "y": int64(4), "big": int64(1234567)}))
+	require.Equal(t, "2.58|4|2.58|4.00|2.58|4.0|1234567|2582.0", buf.String())
+
+	got, err = c.Convert("sample.ftl", `#{x - (y &gt; 1)?c?number; m1}`)
+	require.NoError(t, err)
+	require.Equal(t, `{{formatNumeric (subtract .x (toNumber (computerString ((greaterThan .y 1))))) 1 1}}`, got.Output)
+
+	for _, invalid := range []string{`#{x; m3M2}`, `#{x; M}`, `#{x; m2m2}`} {
+		_, err := c.Convert("sample.ftl", invalid)
+		require.ErrorContains(t, err, "EMIT_INVALID_NUMERIC_FORMAT", invalid)
+	}
+}
+
+func TestConvertParenthesizedDefaults(t *testing.T) {
+	c := NewConverter()
+	input := `[${(a?trim)!"none"}][${(order.total * 2)!0}][${name!}][<#list tags! as t>${t}</#list>][${((order.id)??)?c}]`
+	got, err := c.Convert("sample.ftl", input)
+	require.NoError(t, err)
+
+	tpl, err := template.New("sample").Funcs(StubFuncMap()).Parse(got.Output)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, tpl.Execute(&buf, map[string]any{}))
+	require.Equal(t, "[none][0][][][false]", buf.String())
+
+	buf.Reset()
+	data := map[string]any{"a": " x ", "order": map[string]any{"total": int64(21), "id": "7"}, "name": "Ada", "tags": []any{"p", "q"}}
+	require.NoError(t, tpl.Execute(&buf, data))
+	require.Equal(t, "[x][42][Ada][pq][true]", buf.String())
+}
+
+func TestConvertRegexBuiltins(t *testing.T) {
+	c := NewConverter()
+	input := `<#if code?matches(r"[A-Z]{2}\d+")>ok</#if>|${code?matches(r"([A-Z]+)(\d+)")?groups[2]}|` +
+		`<#list text?matches(r"(\w)(\d)") as m>${m}=${m?groups[1]};</#list>|` +
+		`${name?replace(r"(\w+) (\w+)", "$2 $1", "r")}|<#list csv?split(r"\s*,\s*", "r") as p>[${p}]</#list>`
+	got, err := c.Convert("sample.ftl", input)
+	require.NoError(t, err)
+	require.Contains(t, got.Output, `{{if matches .code "[A-Z]{2}\\d+"}}`)
+	require.Contains(t, got.Output, `index (groups .code "([A-Z]+)(\\d+)") 2`)
+	require.Contains(t, got.Output, `range $m_index, $m := matchAll .text "(\\w)(\\d)"`)
+
+	tpl, err := template.New("sample").Funcs(StubFuncMap()).Parse(got.Output)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	data := map[string]any{"code": "FR42", "text": "a1 b2", "name": "Ada Lovelace", "csv": "x , y,z"}
+	require.NoError(t, tpl.Execute(&buf, data))
+	require.Equal(t, "ok|42|a1=a;b2=b;|Lovelace Ada|[x][y][z]", buf.String())
+
+	for _, unsupported := range []string{`${s?matches("a(?=b)")}`, `${s?replace(r"(a)\1", "", "r")}`} {
+		_, err := c.Convert("sample.ftl", unsupported)
+		require.ErrorContains(t, err, "EMIT_UNSUPPORTED_REGEX", unsupported)
+	}
+	_, err = c.Convert("sample.ftl", `${s?replace("(?=", "")}`)
+	require.NoError(t, err, "without the r flag the pattern is a literal string")
+}
+
+func TestConvertMatchesResultVariables(t *testing.T) {
+	c := NewConverter()
+	for _, unsupported := range []string{
+		`<#assign res = s?matches(r"(\w)(\d)")><#if res>${res?groups[1]}</#if>`,
+		`<#assign res = s?matches(r"\d")><#list res as m>${m}</#list>`,
+		`<#assign res = s?matches(r"\d")><#attempt><#list res as m>${m}</#list><#recover>x</#attempt>`,
+	} {
+		_, err := c.Convert("sample.ftl", unsupported)
+		require.ErrorContains(t, err, "EMIT_UNSUPPORTED_MATCHES_RESULT", unsupported)
+	}
+
+	for _, supported := range []string{
+		`<#assign res = s?matches(r"\d")><#if res>ok</#if>`,
+		`<#assign res = s?matches(r"\d")><#assign res = items><#list res as m>${m}</#list>`,
+		`<#assign res = s?matches(r"\d")><#list xs as res>${res?groups[0]}</#list>`,
+	} {
+		_, err := c.Convert("sample.ftl", supported)
+		require.NoError(t, err, supported)
+	}
+}
+
+func TestConvertAssignForms(t *testing.T) {
+	c := NewConverter()
+	input := `<#assign a=1 b = a + 2><#assign a += 5><#assign b++><#global label = "n">${a} ${b} ${label}|` +
+		`<#list items as i><#assign row><b>${i}</b> of ${title}</#assign>${row};</#list>`
+	got, err := c.Convert("sample.ftl", input)
+	require.NoError(t, err)
+	require.Contains(t, got.Output, `{{$a := 1}}{{$b := add $a 2}}{{$a = add $a (5)}}{{$b = add $b 1}}{{$label := "n"}}`)
+	require.Contains(t, got.Output, `{{$row = capture "sample.ftl#capture1" (captureScope $ "a" $a "b" $b "i" $i "i_index" $i_index "label" $label "row" $row)}}`)
+	require.Contains(t, got.Output, `{{$row := .Local "row"}}{{range .Data}}<b>{{interpolate $i}}</b> of {{interpolate .title}}{{end}}{{end}}`)
+	require.True(t, strings.HasSuffix(got.Output, "{{end}}{{end}}"), "defines follow the template")
+	require.Contains(t, got.Features, "assign:capture")
+	require.Contains(t, got.Features, "directive:global")
+
+	tpl, err := template.New("sample").Funcs(StubFuncMap()).Parse(got.Output)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	data := map[string]any{"items": []any{"p", "<q>"}, "title": "T"}
+	require.ErrorContains(t, tpl.Execute(&buf, data), "call BindTemplate")
+
+	buf.Reset()
+	require.NoError(t, BindTemplate(tpl).Execute(&buf, data))
+	require.Equal(t, "6 4 n|<b>p</b> of T;<b>&lt;q&gt;</b> of T;", buf.String())
+}
+
+func TestConvertDefineBodiesKeepAssignments(t *testing.T) {
+	input := `<#compress> <#assign x = 1> </#compress>[${x}]` +
+		`<#attempt><#assign y = "ok"><#recover>err</#attempt>[${y}]` +
+		`<#attempt><#assign y = "retry">${"x"?number}<#recover>err</#attempt>[${y}]` +
+		`<#assign out><#assign w = x + 1><#compress><#assign v = w * 10></#compress></#assign>[${w} ${v}]`
+	got, err := NewConverter().Convert("sample.ftl", input)
+	require.NoError(t, err)
+	require.Contains(t, got.Output, `{{$x = $ftlScope1.Assigned "x" $x}}`)
+
+	tpl, err := template.New("sample").Funcs(StubFuncMap()).Parse(got.Output)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, BindTemplate(tpl).Execute(&buf, map[string]any{}))
+	require.Equal(t, "[1][ok]err[retry][2 20]", buf.String())
+}
+
+func TestConvertDefineBodiesSeeEnclosingLoopLength(t *testing.T) {
+	inputs := map[string]string{
+		"capture":  `<#list xs as x><#assign row>${x}<#if x?has_next>,</#if></#assign>${row}</#list>`,
+		"attempt":  `<#list xs as x><#attempt>${x}<#if x?has_next>,</#if><#recover>!</#attempt></#list>`,
+		"compress": `<#list xs as x><#compress>${x}<#if !x?is_last>,</#if></#compress></#list>`,
+	}
+	for kind, input := range inputs {
+		got, err := NewConverter().Convert("sample.ftl", input)
+		require.NoError(t, err, kind)
+		require.Contains(t, got.Output, `{{$x_length := .Local "x_length"}}`, kind)
+
+		tpl, err := template.New("sample").Funcs(StubFuncMap()).Parse(got.Output)
+		require.NoError(t, err, kind)
+		var buf bytes.Buffer
+		require.NoError(t, BindTemplate(tpl).Execute(&buf, map[string]any{"xs": []any{"a", "b", "c"}}), kind)
+		require.Equal(t, "a,b,c", buf.String(), kind)
+	}
+}
+
+func TestConvertDataModelInsideCapture(t *testing.T) {
+	got, err := NewConverter().Convert("sample.ftl", `<#assign b>${.data_model.x}<#assign c>${.data_model.x}!</#assign>${c}</#assign>${b}`)
+	require.NoError(t, err)
+	require.Contains(t, got.Output, `{{interpolate $.Root.x}}`)
+
+	tpl, err := template.New("sample").Funcs(StubFuncMap()).Parse(got.Output)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, BindTemplate(tpl).Execute(&buf, map[string]any{"x": "v"}))
+	require.Equal(t, "vv!", buf.String())
+}
+
+func TestConvertHoistsBlockAssignments(t *testing.T) {
+	c := NewConverter()
+	input := `<#if flag><#assign greeting = "hi"><#else><#assign greeting = "bye"></#if>` +
+		`<#list items as i><#assign last = i><#assign count = (count!0) + 1></#list>${greeting} ${last!"-"} ${count!0}`
+	got, err := c.Convert("sample.ftl", input)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(got.Output, `{{$greeting := safeAccess . "greeting"}}{{$last := safeAccess . "last"}}{{$count := safeAccess . "count"}}`))
+	require.Contains(t, got.Output, `{{if .flag}}{{$greeting = "hi"}}{{else}}{{$greeting = "bye"}}{{end}}`)
+
+	tpl, err := template.New("sample").Funcs(StubFuncMap()).Parse(got.Output)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, tpl.Execute(&buf, map[string]any{"flag": true, "items": []any{"a", "b"}}))
+	require.Equal(t, "hi b 2", buf.String())
+
+	buf.Reset()
+	require.NoError(t, tpl.Execute(&buf, map[string]any{"flag": false, "items": []any{}, "count": int64(7)}))
+	require.Equal(t, "bye - 7", buf.String(), "unassigned variables read the data model")
+}
+
+func TestConvertAttemptRecover(t *testing.T) {
+	c := NewConverter()
+	input := `<#list items as i><#attempt>[${i}: ${i?number * 2}]<#recover>[${i} failed<#if .error?has_content>!</#if>]</#attempt></#list>`
+	got, err := c.Convert("sample.ftl", input)
+	require.NoError(t, err)
+	require.Contains(t, got.Output, `{{$ftlAttempt1 := tryTemplate "sample.ftl#attempt1" (captureScope $ "i" $i "i_index" $i_index)}}{{if $ftlAttempt1.Failed}}`)
+	require.Contains(t, got.Output, `{{else}}{{$ftlAttempt1.Output}}{{end}}`)
+	require.Contains(t, got.Features, "directive:attempt")
+
+	tpl, err := template.New("sample").Funcs(StubFuncMap()).Parse(got.Output)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	data := map[string]any{"items": []any{"1", "x", "<3>"}}
+	require.ErrorContains(t, tpl.Execute(&buf, data), "call BindTemplate")
+
+	buf.Reset()
+	require.NoError(t, BindTemplate(tpl).Execute(&buf, data))
+	require.Equal(t, "[1: 2][x failed!][&lt;3&gt; failed!]", buf.String(), "partial output of a failed attempt is dropped")
+
+	_, err = c.Convert("sample.ftl", `<#attempt>${a}</#attempt>`)
+	require.ErrorContains(t, err, "PARSE_INVALID_ATTEMPT")
+	_, err = c.Convert("sample.ftl", `<#attempt>${a}<#recover>-`)
+	require.ErrorContains(t, err, "PARSE_UNCLOSED_ATTEMPT")
+	_, err = c.Convert("sample.ftl", `${.error}`)
+	require.ErrorContains(t, err, "only available inside <#recover>")
+}
+
+func TestConvertNoparseAndCompress(t *testing.T) {
+	c := NewConverter()
+	got, err := c.Convert("sample.ftl", "<#noparse><script>var t = `${x}`;</script></#noparse><#compress>\n  <ul>\n\n    <li>a</li>  <li>b</li>\n  </ul>\n</#compress>")
+	require.NoError(t, err)
+	require.Equal(t, "<script>var t = `${x}`;</script><ul>\n<li>a</li> <li>b</li>\n</ul>", got.Output)
+	require.Contains(t, got.Features, "directive:compress")
+
+	got, err = c.Convert("sample.ftl", "<#list items as i><#compress>\n  <p>\n    ${i}  !\n  </p>\n</#compress></#list>")
+	require.NoError(t, err)
+	require.Contains(t, got.Output, `{{compress (capture "sample.ftl#compress1" (captureScope $ "i" $i "i_index" $i_index))}}`)
+
+	tpl, err := template.New("sample").Funcs(StubFuncMap()).Parse(got.Output)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, BindTemplate(tpl).Execute(&buf, map[string]any{"items": []any{" a  b ", "<c>"}}))
+	require.Equal(t, "<p>\na b !\n</p><p>\n&lt;c&gt; !\n</p>", buf.String())
+}
+
+func TestConvertEscapingDirectives(t *testing.T) {
+	c := NewConverter()
+	input := `<#escape x as x?html>${a}<#noescape>${b}</#noescape></#escape><#escape x as x!"-">${missing}</#escape>` +
+		`<#outputformat "plainText">${c}</#outputformat><#noautoesc>${d}<#autoesc>${e}</#autoesc></#noautoesc>`
+	got, err := c.Convert("sample.ftl", input)
+	require.NoError(t, err)
+	require.Contains(t, got.Output, `{{interpolate .a}}{{safeHTML (interpolate .b)}}`)
+	require.Contains(t, got.Features, "directive:escape")
+	require.Contains(t, got.Features, "directive:noautoesc")
+
+	tpl, err := template.New("sample").Funcs(StubFuncMap()).Parse(got.Output)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, tpl.Execute(&buf, map[string]any{"a": "<a>", "b": "<b>", "c": "<c>", "d": "<d>", "e": "<e>"}))
+	require.Equal(t, "&lt;a&gt;<b>-<c><d>&lt;e&gt;", buf.String())
+
+	for input, code := range map[string]string{
+		`<#escape x>${a}</#escape>`:                        "PARSE_INVALID_ESCAPE",
+		`<#escape x as y?html>${a}</#escape>`:              "EMIT_UNSUPPORTED_ESCAPE",
+		`<#noescape>${a}</#noescape>`:                      "EMIT_INVALID_NOESCAPE",
+		`<#outputformat "JavaScript">${a}</#outputformat>`: "EMIT_UNSUPPORTED_OUTPUT_FORMAT",
+		`<#outputformat fmt>${a}</#outputformat>`:          "EMIT_UNSUPPORTED_OUTPUT_FORMAT",
+		`<#noautoesc>${a}`:                                 "PARSE_UNCLOSED_NOAUTOESC",
+	} {
+		_, err := c.Convert("sample.ftl", input)
+		require.ErrorContains(t, err, code, input)
+	}
+}
+
+func TestConvertPreserveComments(t *testing.T) {
+	input := "<#-- @ftlvariable name=\"user\" type=\"User\" -->\n<#-- TODO: owner */ billing -->Hi ${user}<#-- noinspection FtlReferencesInspection -->"
+	got, err := NewConverter().Convert("sample.ftl", input)
+	require.NoError(t, err)
+	require.Equal(t, "\nHi {{interpolate .user}}", got.Output)
+	require.NotContains(t, got.Features, "node:comment")
+
+	got, err = NewConverterWithOptions(Options{PreserveComments: true}).Convert("sample.ftl", "<#-- @ftlvariable name=\"user\" type=\"User\" -->${user}")
+	require.NoError(t, err)
+	require.NotContains(t, got.Features, "node:comment")
+
+	got, err = NewConverterWithOptions(Options{PreserveComments: true}).Convert("sample.ftl", input)
+	require.NoError(t, err)
+	require.Equal(t, "\n{{/* TODO: owner * / billing */}}Hi {{interpolate .user}}", got.Output)
+	require.Contains(t, got.Features, "node:comment")
+}
+
+func TestConvertEscapesDelimitersInText(t *testing.T) {
+	input := `<div>{{#each items}}{{this}}{{/each}}</div><style>p {<#if wide>width: 100%</#if>}</style>` +
+		`<#assign x>{</#assign>${x}`
+	got, err := NewConverter().Convert("sample.ftl", input)
+	require.NoError(t, err)
+	require.Contains(t, got.Output, `<div>{{"{{"}}#each items}}{{"{{"}}this}}{{"{{"}}/each}}</div>`)
+	require.Contains(t, got.Output, `<style>p {{"{"}}{{if .wide}}width: 100%{{end}}}</style>`)
+	require.Equal(t, Delims{Left: "{{", Right: "}}"}, got.Delims)
+
+	tpl, err := template.New("sample").Funcs(StubFuncMap()).Parse(got.Output)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, BindTemplate(tpl).Execute(&buf, map[string]any{"wide": false}))
+	require.True(t, strings.HasPrefix(buf.String(), "<div>{{#each items}}{{this}}{{/each}}</div>"), buf.String())
+	require.True(t, strings.HasSuffix(buf.String(), "{"), buf.String())
+
+	got, err = NewConverterWithOptions(Options{Delims: Delims{Left: "[[", Right: "]]"}}).Convert("sample.ftl", `{{x}} [[y]] ${name}<#-- c --><#assign z>${name}</#assign>`)
+	require.NoError(t, err)
+	require.Equal(t, `{{x}} [["[["]]y]] [[interpolate .name]][[define "sample.ftl#capture1"]][[range .Data]][[interpolate .name]][[end]][[end]]`, strings.Replace(got.Output, `[[$z := capture "sample.ftl#capture1" (captureScope $)]]`, "", 1))
+	require.Equal(t, Delims{Left: "[[", Right: "]]"}, got.Delims)
+}
+
+func TestConvertFlowDirectives(t *testing.T) {
+	c := NewConverter()
+	input := `<#list items as i><#if i == "skip"><#continue></#if><#if i == "end"><#return></#if>${i},<#flush></#list>` +
+		`<#if done><#return></#if><#assign tail = "!">after${tail}`
+	got, err := c.Convert("sample.ftl", input)
+	require.NoError(t, err)
+	require.Contains(t, got.Output, `{{if equals $i "skip"}}{{continue}}{{end}}`)
+	require.Contains(t, got.Output, `{{if equals $i "end"}}{{$ftlReturned = true}}{{break}}{{end}}{{if $ftlReturned}}{{break}}{{end}}`)
+	require.Contains(t, got.Output, `{{/* ftl flush ignored: Go templates have no explicit flush */}}`)
+
+	tpl, err := template.New("sample").Funcs(StubFuncMap()).Parse(got.Output)
+	require.NoError(t, err)
+	render := func(data map[string]any) string {
+		var buf bytes.Buffer
+		require.NoError(t, tpl.Execute(&buf, data))
+		return buf.String()
+	}
+	require.Equal(t, "a,b,after!", render(map[string]any{"items": []any{"a", "skip", "b"}, "done": false}))
+	require.Equal(t, "a,", render(map[string]any{"items": []any{"a", "end", "b"}, "done": false}))
+	require.Equal(t, "a,", render(map[string]any{"items": []any{"a"}, "done": true}))
+
+	got, err = c.Convert("sample.ftl", `before<#if fail><#stop "bad "+ reason></#if>`)
+	require.NoError(t, err)
+	tpl, err = template.New("sample").Funcs(StubFuncMap()).Parse(got.Output)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	err = tpl.Execute(&buf, map[string]any{"fail": true, "reason": "input"})
+	require.ErrorIs(t, err, ErrStopped)
+	require.ErrorContains(t, err, "template processing stopped: bad input")
+
+	for _, unsupported := range []string{`<#return 1>`, `<#assign x><#return></#assign>`} {
+		_, err := c.Convert("sample.ftl", unsupported)
+		require.ErrorContains(t, err, "EMIT_UNSUPPORTED_RETURN", unsupported)
+	}
+
+	for input, code := range map[string]string{
+		`<#list items as i><#assign x><#if i == 2><#break></#if>${i}</#assign>${x}</#list>`:          "EMIT_UNSUPPORTED_BREAK",
+		`<#list items as i><#attempt><#if i == 2><#break></#if>${i}<#recover>err</#attempt></#list>`: "EMIT_UNSUPPORTED_BREAK",
+		`<#list items as i><#compress> <#if i == 2><#continue></#if>${i} </#compress></#list>`:       "EMIT_UNSUPPORTED_CONTINUE",
+	} {
+		_, err := c.Convert("sample.ftl", input)
+		require.ErrorContains(t, err, code, input)
+	}
+	got, err = c.Convert("sample.ftl", `<#compress><#list items as i><#if i == 2><#break></#if>${i} </#list></#compress>`)
+	require.NoError(t, err, "loops inside the block keep break")
+	require.Contains(t, got.Output, `{{break}}`)
 }
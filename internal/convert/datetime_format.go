@@ -0,0 +1,734 @@
+package convert
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// datetimeKind mirrors FreeMarker's date, time and datetime value types.
+type datetimeKind int
+
+const (
+	kindDatetime datetimeKind = iota
+	kindDate
+	kindTime
+)
+
+// String returns the FreeMarker name of the datetime type.
+func (k datetimeKind) String() string {
+	switch k {
+	case kindDate:
+		return "date"
+	case kindTime:
+		return "time"
+	default:
+		return "datetime"
+	}
+}
+
+// datetimeValue is a time tagged with the FreeMarker type produced by ?date,
+// ?time and ?datetime; the type selects date_format, time_format or
+// datetime_format when the value is printed.
+type datetimeValue struct {
+	at   time.Time
+	kind datetimeKind
+}
+
+// String renders the value like time.Time so plain {{.}} output stays readable.
+func (v datetimeValue) String() string {
+	return v.at.String()
+}
+
+// asDatetime unwraps time.Time and datetimeValue arguments.
+func asDatetime(v any) (time.Time, datetimeKind, bool) {
+	switch t := indirect(v).(type) {
+	case time.Time:
+		return t, kindDatetime, true
+	case datetimeValue:
+		return t.at, t.kind, true
+	}
+	return time.Time{}, kindDatetime, false
+}
+
+// datetimeToken is one field or literal run of a SimpleDateFormat pattern.
+type datetimeToken struct {
+	letter  byte
+	count   int
+	literal string
+}
+
+// datetimeLetters lists the SimpleDateFormat pattern letters.
+const datetimeLetters = "GyYMLwWDdFEuaHkKhmsSzZX"
+
+// datetimeStyles maps FreeMarker's named date styles to localeData indexes.
+var datetimeStyles = map[string]int{
+	"short":  0,
+	"medium": 1,
+	"long":   2,
+	"full":   3,
+}
+
+// isoDatetimeFormats are FreeMarker's ISO 8601 format names.
+var isoDatetimeFormats = map[string]struct{}{
+	"iso": {},
+	"xs":  {},
+}
+
+// parseDatetimePattern splits a SimpleDateFormat pattern into tokens.
+func parseDatetimePattern(pattern string) ([]datetimeToken, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("unsupported format pattern %q", pattern)
+	}
+
+	var tokens []datetimeToken
+	var literal strings.Builder
+	flushLiteral := func() {
+		if literal.Len() > 0 {
+			tokens = append(tokens, datetimeToken{literal: literal.String()})
+			literal.Reset()
+		}
+	}
+	hasField := false
+
+	for i := 0; i < len(pattern); {
+		ch := pattern[i]
+		if ch == '\'' {
+			if i+1 < len(pattern) && pattern[i+1] == '\'' {
+				literal.WriteByte('\'')
+				i += 2
+				continue
+			}
+			i++
+			closed := false
+			for i < len(pattern) {
+				if pattern[i] == '\'' {
+					if i+1 < len(pattern) && pattern[i+1] == '\'' {
+						literal.WriteByte('\'')
+						i += 2
+						continue
+					}
+					i++
+					closed = true
+					break
+				}
+				literal.WriteByte(pattern[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated quoted literal in pattern %q", pattern)
+			}
+			continue
+		}
+
+		if !isASCIILetter(ch) {
+			literal.WriteByte(ch)
+			i++
+			continue
+		}
+		if !strings.ContainsRune(datetimeLetters, rune(ch)) {
+			return nil, fmt.Errorf("unsupported datetime token %q in pattern %q", ch, pattern)
+		}
+
+		j := i
+		for j < len(pattern) && pattern[j] == ch {
+			j++
+		}
+		if ch == 'X' && j-i > 3 {
+			return nil, fmt.Errorf("too many 'X' letters in pattern %q", pattern)
+		}
+		flushLiteral()
+		tokens = append(tokens, datetimeToken{letter: ch, count: j - i})
+		hasField = true
+		i = j
+	}
+	flushLiteral()
+
+	if !hasField {
+		return nil, fmt.Errorf("unsupported format pattern %q", pattern)
+	}
+	return tokens, nil
+}
+
+func isASCIILetter(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+// resolveDatetimePattern expands FreeMarker's named styles ("short", "medium",
+// "long", "full" and, for datetimes, "<date>_<time>" pairs) into a locale's
+// pattern; anything else is treated as a SimpleDateFormat pattern.
+func resolveDatetimePattern(format string, kind datetimeKind, loc *localeData) string {
+	if style, ok := datetimeStyles[format]; ok {
+		switch kind {
+		case kindDate:
+			return loc.dateStyles[style]
+		case kindTime:
+			return loc.timeStyles[style]
+		}
+		return combineDatetimePattern(loc, loc.dateStyles[style], loc.timeStyles[style])
+	}
+	if dateName, timeName, found := strings.Cut(format, "_"); found && kind == kindDatetime {
+		dateStyle, dateOK := datetimeStyles[dateName]
+		timeStyle, timeOK := datetimeStyles[timeName]
+		if dateOK && timeOK {
+			return combineDatetimePattern(loc, loc.dateStyles[dateStyle], loc.timeStyles[timeStyle])
+		}
+	}
+	return format
+}
+
+func combineDatetimePattern(loc *localeData, datePattern string, timePattern string) string {
+	return strings.NewReplacer("{1}", datePattern, "{0}", timePattern).Replace(loc.dateTime)
+}
+
+// validateDatetimeFormat checks a date_format, time_format or datetime_format value.
+func validateDatetimeFormat(format string, kind datetimeKind) error {
+	if _, ok := isoDatetimeFormats[format]; ok {
+		return nil
+	}
+	_, err := parseDatetimePattern(resolveDatetimePattern(format, kind, locales[defaultLocale]))
+	return err
+}
+
+// isoDatetimePattern returns the ISO 8601 pattern FreeMarker's "iso" format
+// uses for a value type.
+func isoDatetimePattern(kind datetimeKind) string {
+	switch kind {
+	case kindDate:
+		return "yyyy-MM-dd"
+	case kindTime:
+		return "HH:mm:ssXXX"
+	}
+	return "yyyy-MM-dd'T'HH:mm:ssXXX"
+}
+
+// isoFraction returns the milliseconds FreeMarker's ISO formats print after
+// the seconds: none when they are zero, otherwise without trailing zeros.
+func isoFraction(t time.Time) string {
+	millis := t.Nanosecond() / int(time.Millisecond)
+	if millis == 0 {
+		return ""
+	}
+	return "." + strings.TrimRight(fmt.Sprintf("%03d", millis), "0")
+}
+
+// datetimeTokens resolves a format for a value type to pattern tokens.
+func datetimeTokens(format string, kind datetimeKind, loc *localeData) ([]datetimeToken, error) {
+	if _, ok := isoDatetimeFormats[format]; ok {
+		format = isoDatetimePattern(kind)
+	}
+	return parseDatetimePattern(resolveDatetimePattern(format, kind, loc))
+}
+
+// formatDatetime renders a time with a date format or named style in a locale.
+func formatDatetime(t time.Time, format string, kind datetimeKind, loc *localeData) (string, error) {
+	tokens, err := datetimeTokens(format, kind, loc)
+	if err != nil {
+		return "", err
+	}
+	_, iso := isoDatetimeFormats[format]
+
+	var b strings.Builder
+	for _, tok := range tokens {
+		if tok.letter == 0 {
+			b.WriteString(tok.literal)
+			continue
+		}
+		if iso && tok.letter == 's' {
+			b.WriteString(padNumber(t.Second(), tok.count))
+			b.WriteString(isoFraction(t))
+			continue
+		}
+		switch tok.letter {
+		case 'G':
+			if t.Year() > 0 {
+				b.WriteString(loc.eras[1])
+			} else {
+				b.WriteString(loc.eras[0])
+			}
+		case 'y':
+			writeYear(&b, eraYear(t.Year()), tok.count)
+		case 'Y':
+			year, _ := weekOfYear(t, loc)
+			writeYear(&b, eraYear(year), tok.count)
+		case 'M', 'L':
+			month := int(t.Month())
+			switch {
+			case tok.count >= 4:
+				b.WriteString(loc.months[month-1])
+			case tok.count == 3:
+				b.WriteString(loc.monthsShort[month-1])
+			default:
+				b.WriteString(padNumber(month, tok.count))
+			}
+		case 'w':
+			_, week := weekOfYear(t, loc)
+			b.WriteString(padNumber(week, tok.count))
+		case 'W':
+			b.WriteString(padNumber(weekOfMonth(t, loc), tok.count))
+		case 'D':
+			b.WriteString(padNumber(t.YearDay(), tok.count))
+		case 'd':
+			b.WriteString(padNumber(t.Day(), tok.count))
+		case 'F':
+			b.WriteString(padNumber((t.Day()-1)/7+1, tok.count))
+		case 'E':
+			if tok.count >= 4 {
+				b.WriteString(loc.days[t.Weekday()])
+			} else {
+				b.WriteString(loc.daysShort[t.Weekday()])
+			}
+		case 'u':
+			day := int(t.Weekday())
+			if day == 0 {
+				day = 7
+			}
+			b.WriteString(padNumber(day, tok.count))
+		case 'a':
+			if t.Hour() < 12 {
+				b.WriteString(loc.amPm[0])
+			} else {
+				b.WriteString(loc.amPm[1])
+			}
+		case 'H':
+			b.WriteString(padNumber(t.Hour(), tok.count))
+		case 'k':
+			hour := t.Hour()
+			if hour == 0 {
+				hour = 24
+			}
+			b.WriteString(padNumber(hour, tok.count))
+		case 'K':
+			b.WriteString(padNumber(t.Hour()%12, tok.count))
+		case 'h':
+			hour := t.Hour() % 12
+			if hour == 0 {
+				hour = 12
+			}
+			b.WriteString(padNumber(hour, tok.count))
+		case 'm':
+			b.WriteString(padNumber(t.Minute(), tok.count))
+		case 's':
+			b.WriteString(padNumber(t.Second(), tok.count))
+		case 'S':
+			b.WriteString(padNumber(t.Nanosecond()/int(time.Millisecond), tok.count))
+		case 'z':
+			b.WriteString(zoneName(t, tok.count >= 4, loc))
+		case 'Z':
+			b.WriteString(zoneOffset(t, false, false))
+		case 'X':
+			_, offset := t.Zone()
+			switch {
+			case offset == 0:
+				b.WriteByte('Z')
+			case tok.count == 1:
+				b.WriteString(zoneOffset(t, false, true))
+			default:
+				b.WriteString(zoneOffset(t, tok.count == 3, false))
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+// eraYear converts an astronomical year to the year of its era.
+func eraYear(year int) int {
+	if year <= 0 {
+		return 1 - year
+	}
+	return year
+}
+
+func writeYear(b *strings.Builder, year int, count int) {
+	if count == 2 {
+		b.WriteString(padNumber(year%100, 2))
+		return
+	}
+	b.WriteString(padNumber(year, count))
+}
+
+// zoneName returns the short or long name of t's zone. Long names come from
+// the locale and are looked up by IANA ID, since abbreviations such as CST
+// are ambiguous; other zones fall back to the short form.
+func zoneName(t time.Time, long bool, loc *localeData) string {
+	name, offset := t.Zone()
+	if long {
+		if names, ok := loc.zoneNames[zoneMetazones[t.Location().String()]]; ok {
+			if t.IsDST() {
+				return names[1]
+			}
+			return names[0]
+		}
+	}
+	if name == "" || name[0] == '+' || name[0] == '-' {
+		return "GMT" + zoneOffsetText(offset, true, false)
+	}
+	return name
+}
+
+// zoneOffset renders t's UTC offset as "+hhmm", "+hh:mm" or "+hh".
+func zoneOffset(t time.Time, colon bool, hoursOnly bool) string {
+	_, offset := t.Zone()
+	return zoneOffsetText(offset, colon, hoursOnly)
+}
+
+func zoneOffsetText(offset int, colon bool, hoursOnly bool) string {
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	hours := padNumber(offset/3600, 2)
+	if hoursOnly {
+		return sign + hours
+	}
+	minutes := padNumber(offset%3600/60, 2)
+	if colon {
+		return sign + hours + ":" + minutes
+	}
+	return sign + hours + minutes
+}
+
+// weekStart returns the day (relative to the 1st) on which week 1 of the
+// year or month beginning on first starts, following java.util.Calendar.
+func weekStart(first time.Time, loc *localeData) int {
+	offset := (int(first.Weekday()) - int(loc.firstDayOfWeek) + 7) % 7
+	start := 1 - offset
+	if 7-offset < loc.minWeekDays {
+		start += 7
+	}
+	return start
+}
+
+// weekOfYear returns the week-based year and week number of t.
+func weekOfYear(t time.Time, loc *localeData) (int, int) {
+	year := t.Year()
+	day := t.YearDay()
+	start := weekStart(time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC), loc)
+	if day < start {
+		prevStart := weekStart(time.Date(year-1, time.January, 1, 0, 0, 0, 0, time.UTC), loc)
+		prevDays := time.Date(year-1, time.December, 31, 0, 0, 0, 0, time.UTC).YearDay()
+		return year - 1, (day+prevDays-prevStart)/7 + 1
+	}
+	nextStart := weekStart(time.Date(year+1, time.January, 1, 0, 0, 0, 0, time.UTC), loc)
+	daysInYear := time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC).YearDay()
+	if day >= daysInYear+nextStart {
+		return year + 1, 1
+	}
+	return year, (day-start)/7 + 1
+}
+
+// weekOfMonth returns t's week within its month; days before the first full
+// week belong to week 0.
+func weekOfMonth(t time.Time, loc *localeData) int {
+	start := weekStart(time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC), loc)
+	diff := t.Day() - start
+	if diff < 0 {
+		return 0
+	}
+	return diff/7 + 1
+}
+
+func padNumber(n int, width int) string {
+	s := strconv.Itoa(n)
+	for len(s) < width {
+		s = "0" + s
+	}
+	return s
+}
+
+// parseDatetime parses text with a date format or named style, the way
+// SimpleDateFormat.parse reads it, except that the whole text must match and
+// out-of-range fields are rejected instead of rolled over. Two-digit years
+// resolve to the century window of 80 years before and 20 after now. Text
+// without a zone field is read as local time in zone.
+func parseDatetime(text string, format string, kind datetimeKind, loc *localeData, zone *time.Location, now time.Time) (time.Time, error) {
+	tokens, err := datetimeTokens(format, kind, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	year, month, day := 1970, 1, 1
+	hour, minute, second, millis := 0, 0, 0, 0
+	yearDay := 0
+	pm, hasAmPm, hour12 := false, false, false
+	bc := false
+	twoDigitYear := false
+
+	pos := 0
+	for i, tok := range tokens {
+		if tok.letter == 0 {
+			if !strings.HasPrefix(text[pos:], tok.literal) {
+				return time.Time{}, fmt.Errorf("cannot parse %q with %q: expected %q at offset %d", text, format, tok.literal, pos)
+			}
+			pos += len(tok.literal)
+			continue
+		}
+
+		abutting := i+1 < len(tokens) && tokens[i+1].letter != 0 && isNumericDatetimeToken(tokens[i+1])
+		readNumber := func() (int, error) {
+			n, width, ok := scanDigits(text[pos:], tok.count, abutting)
+			if !ok {
+				return 0, fmt.Errorf("cannot parse %q with %q: expected a number at offset %d", text, format, pos)
+			}
+			pos += width
+			return n, nil
+		}
+		readName := func(names ...[]string) (int, error) {
+			index, width := matchName(text[pos:], names...)
+			if index < 0 {
+				return 0, fmt.Errorf("cannot parse %q with %q: unexpected text at offset %d", text, format, pos)
+			}
+			pos += width
+			return index, nil
+		}
+
+		switch tok.letter {
+		case 'G':
+			era, err := readName(loc.eras[:])
+			if err != nil {
+				return time.Time{}, err
+			}
+			bc = era == 0
+		case 'y', 'Y':
+			start := pos
+			if year, err = readNumber(); err != nil {
+				return time.Time{}, err
+			}
+			twoDigitYear = tok.count <= 2 && pos-start == 2
+		case 'M', 'L':
+			if tok.count >= 3 {
+				index, err := readName(loc.months[:], loc.monthsShort[:])
+				if err != nil {
+					return time.Time{}, err
+				}
+				month = index + 1
+				continue
+			}
+			if month, err = readNumber(); err != nil {
+				return time.Time{}, err
+			}
+		case 'd':
+			if day, err = readNumber(); err != nil {
+				return time.Time{}, err
+			}
+		case 'D':
+			if yearDay, err = readNumber(); err != nil {
+				return time.Time{}, err
+			}
+		case 'E':
+			if _, err := readName(loc.days[:], loc.daysShort[:]); err != nil {
+				return time.Time{}, err
+			}
+		case 'w', 'W', 'F', 'u':
+			if _, err := readNumber(); err != nil {
+				return time.Time{}, err
+			}
+		case 'a':
+			index, err := readName(loc.amPm[:])
+			if err != nil {
+				return time.Time{}, err
+			}
+			pm = index == 1
+			hasAmPm = true
+		case 'H':
+			if hour, err = readNumber(); err != nil {
+				return time.Time{}, err
+			}
+		case 'k':
+			if hour, err = readNumber(); err != nil {
+				return time.Time{}, err
+			}
+			if hour == 24 {
+				hour = 0
+			}
+		case 'K', 'h':
+			if hour, err = readNumber(); err != nil {
+				return time.Time{}, err
+			}
+			if tok.letter == 'h' && hour == 12 {
+				hour = 0
+			}
+			hour12 = true
+		case 'm':
+			if minute, err = readNumber(); err != nil {
+				return time.Time{}, err
+			}
+		case 's':
+			if second, err = readNumber(); err != nil {
+				return time.Time{}, err
+			}
+			if _, iso := isoDatetimeFormats[format]; iso && strings.HasPrefix(text[pos:], ".") {
+				// The ISO formats print milliseconds only when they are set.
+				end := pos + 1
+				for end < len(text) && end < pos+4 && text[end] >= '0' && text[end] <= '9' {
+					end++
+				}
+				if end == pos+1 {
+					return time.Time{}, fmt.Errorf("cannot parse %q with %q: expected a number at offset %d", text, format, pos+1)
+				}
+				digits := text[pos+1 : end]
+				millis, _ = strconv.Atoi(digits + strings.Repeat("0", 3-len(digits)))
+				pos = end
+			}
+		case 'S':
+			if millis, err = readNumber(); err != nil {
+				return time.Time{}, err
+			}
+		case 'z', 'Z', 'X':
+			parsed, width, err := scanZone(text[pos:])
+			if err != nil {
+				return time.Time{}, fmt.Errorf("cannot parse %q with %q: %w", text, format, err)
+			}
+			zone = parsed
+			pos += width
+		}
+	}
+	if pos != len(text) {
+		return time.Time{}, fmt.Errorf("cannot parse %q with %q: unexpected trailing text %q", text, format, text[pos:])
+	}
+
+	if twoDigitYear {
+		year = resolveTwoDigitYear(year, now)
+	}
+	if bc {
+		year = 1 - year
+	}
+	if hour12 {
+		if hour > 11 {
+			return time.Time{}, fmt.Errorf("cannot parse %q with %q: hour %d is out of range", text, format, hour)
+		}
+		if hasAmPm && pm {
+			hour += 12
+		}
+	}
+	if yearDay > 0 {
+		t := time.Date(year, time.January, yearDay, 0, 0, 0, 0, time.UTC)
+		if t.Year() != year {
+			return time.Time{}, fmt.Errorf("cannot parse %q with %q: day of year %d is out of range", text, format, yearDay)
+		}
+		month, day = int(t.Month()), t.Day()
+	}
+
+	t := time.Date(year, time.Month(month), day, hour, minute, second, millis*int(time.Millisecond), zone)
+	if t.Year() != year || int(t.Month()) != month || t.Day() != day ||
+		t.Hour() != hour || t.Minute() != minute || t.Second() != second || millis > 999 {
+		return time.Time{}, fmt.Errorf("cannot parse %q with %q: field out of range", text, format)
+	}
+	return t, nil
+}
+
+func isNumericDatetimeToken(tok datetimeToken) bool {
+	switch tok.letter {
+	case 'M', 'L':
+		return tok.count < 3
+	case 'y', 'Y', 'w', 'W', 'D', 'd', 'F', 'u', 'H', 'k', 'K', 'h', 'm', 's', 'S':
+		return true
+	}
+	return false
+}
+
+// scanDigits reads a decimal number; abutting fields read exactly width digits.
+func scanDigits(s string, width int, abutting bool) (int, int, bool) {
+	n := 0
+	for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+		if abutting && n == width {
+			break
+		}
+		n++
+	}
+	if n == 0 || (abutting && n != width) {
+		return 0, 0, false
+	}
+	value, err := strconv.Atoi(s[:n])
+	if err != nil {
+		return 0, 0, false
+	}
+	return value, n, true
+}
+
+// matchName finds the longest case-insensitive name prefixing s and returns
+// its index within its list.
+func matchName(s string, lists ...[]string) (int, int) {
+	best, bestWidth := -1, 0
+	for _, names := range lists {
+		for i, name := range names {
+			if len(name) <= bestWidth || len(name) > len(s) {
+				continue
+			}
+			if strings.EqualFold(s[:len(name)], name) {
+				best, bestWidth = i, len(name)
+			}
+		}
+	}
+	return best, bestWidth
+}
+
+// scanZone reads "Z", "UTC"/"GMT" with an optional offset, a bare "+hh",
+// "+hhmm" or "+hh:mm" offset, or a zone name such as "Europe/Paris".
+func scanZone(s string) (*time.Location, int, error) {
+	pos := 0
+	switch {
+	case strings.HasPrefix(s, "Z"):
+		return time.UTC, 1, nil
+	case strings.HasPrefix(s, "UTC"), strings.HasPrefix(s, "GMT"):
+		pos = 3
+		if pos == len(s) || (s[pos] != '+' && s[pos] != '-') {
+			return time.UTC, pos, nil
+		}
+	}
+	if pos == 0 && len(s) > 0 && isASCIILetter(s[0]) {
+		end := strings.IndexFunc(s, func(r rune) bool {
+			return !(r == '/' || r == '_' || (r < 128 && isASCIILetter(byte(r))))
+		})
+		if end < 0 {
+			end = len(s)
+		}
+		zone, err := resolveTimeZone(s[:end])
+		if err != nil {
+			return nil, 0, err
+		}
+		return zone, end, nil
+	}
+	if pos >= len(s) || (s[pos] != '+' && s[pos] != '-') {
+		return nil, 0, fmt.Errorf("expected a time zone at %q", s)
+	}
+	sign := 1
+	if s[pos] == '-' {
+		sign = -1
+	}
+	pos++
+	hours, width, ok := scanDigits(s[pos:], 2, true)
+	if !ok {
+		return nil, 0, fmt.Errorf("invalid time zone offset in %q", s)
+	}
+	pos += width
+	minutes := 0
+	if pos < len(s) && s[pos] == ':' {
+		pos++
+	}
+	if m, width, ok := scanDigits(s[pos:], 2, true); ok {
+		minutes = m
+		pos += width
+	}
+	if hours > 23 || minutes > 59 {
+		return nil, 0, fmt.Errorf("invalid time zone offset in %q", s)
+	}
+	offset := sign * (hours*3600 + minutes*60)
+	if offset == 0 {
+		return time.UTC, pos, nil
+	}
+	return time.FixedZone(zoneOffsetText(offset, true, false), offset), pos, nil
+}
+
+// resolveTwoDigitYear places a two-digit year within 80 years before and 20
+// years after now, like SimpleDateFormat's default century.
+func resolveTwoDigitYear(year int, now time.Time) int {
+	start := now.Year() - 80
+	resolved := start - start%100 + year
+	if resolved < start {
+		resolved += 100
+	}
+	return resolved
+}
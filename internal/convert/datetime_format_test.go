@@ -0,0 +1,146 @@
+package convert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatDatetimeMatchesJava(t *testing.T) {
+	ts := time.Date(2026, 3, 12, 14, 5, 9, 45*int(time.Millisecond), time.UTC)
+	zone := func(name string) *time.Location {
+		location, err := time.LoadLocation(name)
+		require.NoError(t, err)
+		return location
+	}
+	berlin := zone("Europe/Berlin")
+	tests := []struct {
+		pattern string
+		locale  string
+		at      time.Time
+		want    string
+	}{
+		{pattern: "EEE, d MMM yyyy", want: "Thu, 12 Mar 2026"},
+		{pattern: "EEEE d MMMM", locale: "fr_FR", want: "jeudi 12 mars"},
+		{pattern: "hh:mm a", want: "02:05 PM"},
+		{pattern: "HH:mm:ss.SSS", want: "14:05:09.045"},
+		{pattern: "z|zzzz|Z|X|XX|XXX", want: "UTC|Coordinated Universal Time|+0000|Z|Z|Z"},
+		{pattern: "G yy yyyyy", want: "AD 26 02026"},
+		{pattern: "z|zzzz", locale: "de_DE", at: ts.In(berlin), want: "CET|Mitteleuropäische Normalzeit"},
+		{pattern: "zzzz", locale: "fr_FR", at: time.Date(2026, 7, 1, 12, 0, 0, 0, berlin), want: "heure d’été d’Europe centrale"},
+		{pattern: "z|zzzz", at: ts.In(zone("Asia/Shanghai")), want: "CST|China Standard Time"},
+		{pattern: "z|zzzz", at: ts.In(zone("America/Chicago")), want: "CDT|Central Daylight Time"},
+		{pattern: "zzzz", at: ts.In(zone("Europe/London")), want: "Greenwich Mean Time"},
+		{pattern: "zzzz", at: ts.In(zone("Asia/Kolkata")), want: "IST"},
+		{pattern: "D w W F u", want: "71 11 2 2 4"},
+		{pattern: "w", locale: "de_DE", want: "11"},
+		{pattern: "k K", at: time.Date(2026, 3, 12, 0, 30, 0, 0, time.UTC), want: "24 0"},
+		{pattern: "YYYY-'W'ww", at: time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC), want: "2025-W01"},
+		{pattern: "YYYY-'W'ww", locale: "de_DE", at: time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC), want: "2026-W53"},
+		{pattern: "h 'o''clock'", want: "2 o'clock"},
+		{pattern: "Z XXX", at: time.Date(2026, 3, 12, 14, 5, 9, 0, time.FixedZone("", 5*3600+1800)), want: "+0530 +05:30"},
+		{pattern: "medium", want: "Mar 12, 2026, 2:05:09 PM"},
+	}
+
+	for _, tc := range tests {
+		locale := tc.locale
+		if locale == "" {
+			locale = defaultLocale
+		}
+		loc, err := lookupLocale(locale)
+		require.NoError(t, err)
+		at := tc.at
+		if at.IsZero() {
+			at = ts
+		}
+		got, err := formatDatetime(at, tc.pattern, kindDatetime, loc)
+		require.NoError(t, err, tc.pattern)
+		assert.Equal(t, tc.want, got, tc.pattern)
+	}
+}
+
+func TestFormatDatetimeStylesByKind(t *testing.T) {
+	ts := time.Date(2026, 3, 12, 14, 5, 9, 0, time.UTC)
+	loc := locales[defaultLocale]
+
+	got, err := formatDatetime(ts, "medium", kindDate, loc)
+	require.NoError(t, err)
+	assert.Equal(t, "Mar 12, 2026", got)
+
+	got, err = formatDatetime(ts, "short", kindTime, loc)
+	require.NoError(t, err)
+	assert.Equal(t, "2:05 PM", got)
+
+	got, err = formatDatetime(ts, "iso", kindDatetime, loc)
+	require.NoError(t, err)
+	assert.Equal(t, "2026-03-12T14:05:09Z", got)
+
+	withMillis := time.Date(2026, 3, 12, 14, 5, 9, 120*int(time.Millisecond)+456, time.UTC)
+	got, err = formatDatetime(withMillis, "iso", kindDatetime, loc)
+	require.NoError(t, err)
+	assert.Equal(t, "2026-03-12T14:05:09.12Z", got)
+	got, err = formatDatetime(withMillis.Add(3*time.Millisecond), "xs", kindTime, loc)
+	require.NoError(t, err)
+	assert.Equal(t, "14:05:09.123Z", got)
+	got, err = formatDatetime(withMillis, "iso", kindDate, loc)
+	require.NoError(t, err)
+	assert.Equal(t, "2026-03-12", got)
+
+	_, err = formatDatetime(ts, "yyyy-MM-dd Q", kindDatetime, loc)
+	assert.Error(t, err)
+	_, err = formatDatetime(ts, "XXXX", kindDatetime, loc)
+	assert.Error(t, err)
+}
+
+func TestParseDatetimeMatchesJava(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		text    string
+		pattern string
+		locale  string
+		kind    datetimeKind
+		want    time.Time
+	}{
+		{text: "12/03/2026", pattern: "dd/MM/yyyy", want: time.Date(2026, 3, 12, 0, 0, 0, 0, time.UTC)},
+		{text: "20260312", pattern: "yyyyMMdd", want: time.Date(2026, 3, 12, 0, 0, 0, 0, time.UTC)},
+		{text: "12/03/26", pattern: "dd/MM/yy", want: time.Date(2026, 3, 12, 0, 0, 0, 0, time.UTC)},
+		{text: "12/03/60", pattern: "dd/MM/yy", want: time.Date(1960, 3, 12, 0, 0, 0, 0, time.UTC)},
+		{text: "12 mars 2026", pattern: "d MMMM yyyy", locale: "fr_FR", want: time.Date(2026, 3, 12, 0, 0, 0, 0, time.UTC)},
+		{text: "2:05 PM", pattern: "h:mm a", kind: kindTime, want: time.Date(1970, 1, 1, 14, 5, 0, 0, time.UTC)},
+		{text: "12:05 am", pattern: "h:mm a", kind: kindTime, want: time.Date(1970, 1, 1, 0, 5, 0, 0, time.UTC)},
+		{text: "071 2026", pattern: "DDD yyyy", want: time.Date(2026, 3, 12, 0, 0, 0, 0, time.UTC)},
+		{text: "Mar 12, 2026", pattern: "medium", kind: kindDate, want: time.Date(2026, 3, 12, 0, 0, 0, 0, time.UTC)},
+		{text: "2026-03-12T14:05:09Z", pattern: "iso", want: time.Date(2026, 3, 12, 14, 5, 9, 0, time.UTC)},
+		{text: "2026-03-12T14:05:09.12Z", pattern: "iso", want: time.Date(2026, 3, 12, 14, 5, 9, 120*int(time.Millisecond), time.UTC)},
+		{
+			text:    "Thu, 12 Mar 2026 14:05:09.045 +0100",
+			pattern: "EEE, d MMM yyyy HH:mm:ss.SSS Z",
+			want:    time.Date(2026, 3, 12, 13, 5, 9, 45*int(time.Millisecond), time.UTC),
+		},
+	}
+
+	for _, tc := range tests {
+		locale := tc.locale
+		if locale == "" {
+			locale = defaultLocale
+		}
+		loc, err := lookupLocale(locale)
+		require.NoError(t, err)
+		got, err := parseDatetime(tc.text, tc.pattern, tc.kind, loc, time.UTC, now)
+		require.NoError(t, err, tc.text)
+		assert.True(t, tc.want.Equal(got), "%s: got %s, want %s", tc.text, got, tc.want)
+	}
+
+	for _, tc := range []struct{ text, pattern string }{
+		{text: "31/02/2026", pattern: "dd/MM/yyyy"},
+		{text: "12/03/2026x", pattern: "dd/MM/yyyy"},
+		{text: "12-03-2026", pattern: "dd/MM/yyyy"},
+		{text: "13:00 PM", pattern: "hh:mm a"},
+		{text: "Foo 12", pattern: "MMM d"},
+	} {
+		_, err := parseDatetime(tc.text, tc.pattern, kindDatetime, locales[defaultLocale], time.UTC, now)
+		assert.Error(t, err, tc.text)
+	}
+}
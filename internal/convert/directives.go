@@ -3,14 +3,28 @@ package convert
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/cruffinoni/ftl2gotpl/internal/ast"
 	"github.com/cruffinoni/ftl2gotpl/internal/diagnostics"
 )
 
+// mapConditionAt maps an <#if> or <#elseif> condition, checking that it is a
+// boolean at render time when strict booleans are enabled.
+func (e *emitter) mapConditionAt(expr string, line int, col int) (string, error) {
+	cond, err := e.mapExprAt(expr, line, col)
+	if err != nil || !e.strictBooleans {
+		return cond, err
+	}
+	e.helpers["asBool"] = struct{}{}
+	return "asBool " + wrap(cond), nil
+}
+
 // emitIfNode converts FreeMarker if/elseif/else blocks to Go template actions.
 func (e *emitter) emitIfNode(n ast.IfNode) error {
-	cond, err := e.mapExprAt(n.Cond, n.Position.Line, n.Position.Column)
+	cond, err := e.mapConditionAt(n.Cond, n.Position.Line, n.Position.Column)
 	if err != nil {
 		return err
 	}
@@ -24,7 +38,7 @@ func (e *emitter) emitIfNode(n ast.IfNode) error {
 	e.popScope()
 
 	for _, alt := range n.ElseIf {
-		altCond, mapErr := e.mapExprAt(alt.Cond, alt.Position.Line, alt.Position.Column)
+		altCond, mapErr := e.mapConditionAt(alt.Cond, alt.Position.Line, alt.Position.Column)
 		if mapErr != nil {
 			return mapErr
 		}
@@ -52,56 +66,357 @@ func (e *emitter) emitIfNode(n ast.IfNode) error {
 }
 
 // emitListNode converts a FreeMarker list block to a Go range action.
+//
+// The body is emitted first so that loop builtins needing the sequence length
+// (?has_next, ?is_last) can request a length variable declared before range.
 func (e *emitter) emitListNode(n ast.ListNode) error {
-	seq, err := e.mapExprAt(n.SeqExpr, n.Position.Line, n.Position.Column)
+	seq, err := e.mapSequenceAt(n.SeqExpr, n.Position.Line, n.Position.Column)
 	if err != nil {
 		return err
 	}
 
 	indexVar := n.ItemVar + "_index"
-	e.writeAction("range $" + indexVar + ", $" + n.ItemVar + " := " + seq)
+	lengthVar := n.ItemVar + "_length"
+	_, outerNeedsLength := e.loopLengths[n.ItemVar]
+	delete(e.loopLengths, n.ItemVar)
+
+	// The item shadows a variable of the same name holding a ?matches result.
+	_, shadowsMatch := e.matchVars[n.ItemVar]
+	delete(e.matchVars, n.ItemVar)
 	e.pushScope()
 	e.declareLocal(indexVar)
 	e.declareLocal(n.ItemVar)
-	if err := e.emitNodes(n.Body); err != nil {
-		e.popScope()
+	e.loopDepth++
+	body, err := e.captureOutput(func() error {
+		return e.emitNodes(n.Body)
+	})
+	e.loopDepth--
+	e.popScope()
+	if shadowsMatch {
+		e.matchVars[n.ItemVar] = struct{}{}
+	}
+	if err != nil {
 		return err
 	}
-	e.popScope()
+
+	if _, ok := e.loopLengths[n.ItemVar]; ok {
+		e.writeAction("$" + lengthVar + " := len " + wrap(seq))
+	}
+	if outerNeedsLength {
+		e.loopLengths[n.ItemVar] = struct{}{}
+	} else {
+		delete(e.loopLengths, n.ItemVar)
+	}
+	e.writeAction("range $" + indexVar + ", $" + n.ItemVar + " := " + seq)
+	e.buf.WriteString(body)
 	e.writeAction("end")
 	return nil
 }
 
-// emitAssignNode maps assign/local directives to Go template assignments.
+// emitAssignNode maps assign, local and global directives to Go template
+// variable assignments. Compound operators are lowered to the arithmetic they
+// abbreviate, and capture blocks render through a define.
 func (e *emitter) emitAssignNode(n ast.AssignNode) error {
-	expr, err := e.mapExprAt(n.Expr, n.Position.Line, n.Position.Column)
+	if n.Capture {
+		return e.emitCaptureNode(n)
+	}
+	for _, a := range n.Assignments {
+		expr, err := e.mapExprAt(assignmentExpr(a), n.Position.Line, n.Position.Column)
+		if err != nil {
+			return err
+		}
+		e.assignVariable(a.Name, expr, n.Global)
+	}
+	return nil
+}
+
+// assignmentExpr returns the FreeMarker expression an assignment stores, so
+// x += y becomes x + (y) and x++ becomes x + 1.
+func assignmentExpr(a ast.Assignment) string {
+	switch a.Op {
+	case "++":
+		return a.Name + " + 1"
+	case "--":
+		return a.Name + " - 1"
+	case "=":
+		return a.Expr
+	default:
+		return a.Name + " " + strings.TrimSuffix(a.Op, "=") + " (" + a.Expr + ")"
+	}
+}
+
+// assignVariable writes $name, declaring it on first use: in the root scope
+// for <#global>, otherwise in the current scope.
+func (e *emitter) assignVariable(name string, expr string, global bool) {
+	if strings.HasPrefix(expr, "matches ") {
+		e.matchVars[name] = struct{}{}
+	} else {
+		delete(e.matchVars, name)
+	}
+	if e.isLocal(name) {
+		e.writeAction("$" + name + " = " + expr)
+		e.recordAssignment(name)
+		return
+	}
+	e.writeAction("$" + name + " := " + expr)
+	if global {
+		e.scopes[0][name] = struct{}{}
+		return
+	}
+	e.declareLocal(name)
+}
+
+// emitCaptureNode converts <#assign x>...</#assign>: the capture helper
+// renders the body's define into the variable.
+func (e *emitter) emitCaptureNode(n ast.AssignNode) error {
+	name, scope, assigned, err := e.emitDefine("capture", n.Body)
 	if err != nil {
 		return err
 	}
-	if e.isLocal(n.Name) {
-		e.writeAction("$" + n.Name + " = " + expr)
-		return nil
+	e.helpers["capture"] = struct{}{}
+	e.assignVariable(n.Assignments[0].Name, "capture "+strconv.Quote(name)+" "+wrap(scope), n.Global)
+	e.writeBack(scope, assigned)
+	return nil
+}
+
+// emitAttemptNode converts <#attempt>...<#recover>...</#attempt>. The attempt
+// body renders through its define with the tryTemplate helper, which keeps
+// the output or the error; the recover body is emitted inline and runs when
+// the attempt failed, with .error reading the failure message.
+func (e *emitter) emitAttemptNode(n ast.AttemptNode) error {
+	name, scope, assigned, err := e.emitDefine("attempt", n.Body)
+	if err != nil {
+		return err
 	}
-	e.writeAction("$" + n.Name + " := " + expr)
-	e.declareLocal(n.Name)
+	result := fmt.Sprintf("ftlAttempt%d", len(e.defines))
+	e.helpers["tryTemplate"] = struct{}{}
+	e.writeAction("$" + result + " := tryTemplate " + strconv.Quote(name) + " " + wrap(scope))
+	e.writeBack(scope, assigned)
+	e.writeAction("if $" + result + ".Failed")
+	e.pushScope()
+	e.recoverVars = append(e.recoverVars, result)
+	err = e.emitNodes(n.Recover)
+	e.recoverVars = e.recoverVars[:len(e.recoverVars)-1]
+	e.popScope()
+	if err != nil {
+		return err
+	}
+	e.writeAction("else")
+	e.writeAction("$" + result + ".Output")
+	e.writeAction("end")
 	return nil
 }
 
-// emitBareDirectiveNode handles directives represented without a full block.
-func (e *emitter) emitBareDirectiveNode(n ast.BareDirectiveNode) error {
-	switch n.Name {
-	case "break":
-		e.writeAction("break")
+// emitCompressNode converts <#compress>...</#compress>. A body of plain text
+// is collapsed now; otherwise the body renders through its define and the
+// compress helper collapses the output.
+func (e *emitter) emitCompressNode(n ast.CompressNode) error {
+	var text strings.Builder
+	static := true
+	for _, node := range n.Body {
+		switch t := node.(type) {
+		case ast.TextNode:
+			text.WriteString(t.Text)
+		case ast.CommentNode:
+			static = static && !e.preserveComments
+		default:
+			static = false
+		}
+	}
+	if static {
+		e.writeText(compressWhitespace(text.String()))
 		return nil
-	case "return":
+	}
+
+	name, scope, assigned, err := e.emitDefine("compress", n.Body)
+	if err != nil {
+		return err
+	}
+	e.helpers["capture"] = struct{}{}
+	e.helpers["compress"] = struct{}{}
+	e.writeAction("compress (capture " + strconv.Quote(name) + " " + wrap(scope) + ")")
+	e.writeBack(scope, assigned)
+	return nil
+}
+
+// emitDefine emits body as a {{define}} appended after the template and
+// returns its name with the captureScope to execute it with. The define
+// re-declares the visible locals from its captureScope data and ranges once
+// over the data model so dot is unchanged.
+//
+// Variables the body assigns are hoisted, so they are visible here. The
+// define records each assignment in the captureScope as it runs, which is
+// then kept in a variable; the caller copies them back with writeBack once
+// the define ran.
+func (e *emitter) emitDefine(kind string, body []ast.Node) (string, string, []string, error) {
+	outer := e.currentLocals()
+	locals := make([]string, 0, len(outer))
+	for name := range outer {
+		locals = append(locals, name)
+	}
+
+	// The data model is $ in the template and $.Root inside a define.
+	root := "$"
+	if e.defineDepth > 0 {
+		root = "$.Root"
+	}
+	// Loops around the block are outside the define, where break and
+	// continue cannot reach them.
+	loopDepth := e.loopDepth
+	e.loopDepth = 0
+	e.pushScope()
+	e.defineDepth++
+	e.defineLocals = append(e.defineLocals, outer)
+	out, err := e.captureOutput(func() error {
+		return e.emitNodes(body)
+	})
+	e.defineLocals = e.defineLocals[:len(e.defineLocals)-1]
+	e.defineDepth--
+	e.popScope()
+	e.loopDepth = loopDepth
+	if err != nil {
+		return "", "", nil, err
+	}
+	// ?has_next and ?is_last on an enclosing loop item need its length,
+	// which the list declares before its range.
+	for item := range e.loopLengths {
+		if _, ok := outer[item+"_index"]; ok {
+			locals = append(locals, item+"_length")
+		}
+	}
+	sort.Strings(locals)
+
+	var assigned []string
+	for _, variable := range assignedVariables(body) {
+		if e.isLocal(variable) {
+			assigned = append(assigned, variable)
+		}
+	}
+
+	name := fmt.Sprintf("%s#%s%d", e.file, kind, len(e.defines)+1)
+	var define strings.Builder
+	define.WriteString(e.action("define " + strconv.Quote(name)))
+	scope := "captureScope " + root
+	for _, local := range locals {
+		define.WriteString(e.action("$" + local + " := .Local " + strconv.Quote(local)))
+		scope += " " + strconv.Quote(local) + " $" + local
+	}
+	define.WriteString(e.action("range .Data") + out + e.action("end") + e.action("end"))
+	e.defines = append(e.defines, define.String())
+	e.helpers["captureScope"] = struct{}{}
+	if len(assigned) > 0 {
+		scopeVar := fmt.Sprintf("$ftlScope%d", len(e.defines))
+		e.writeAction(scopeVar + " := " + scope)
+		scope = scopeVar
+	}
+	return name, scope, assigned, nil
+}
+
+// writeBack copies the variables a define assigned from its captureScope.
+// Assignments made before a failing attempt body stopped are kept, as
+// FreeMarker only discards the body's output.
+func (e *emitter) writeBack(scope string, assigned []string) {
+	for _, variable := range assigned {
+		e.writeAction("$" + variable + " = " + scope + ".Assigned " + strconv.Quote(variable) + " $" + variable)
+		e.recordAssignment(variable)
+	}
+}
+
+// recordAssignment stores a variable assigned inside a define in its
+// captureScope right away, so the template sees it even if the body fails
+// later on.
+func (e *emitter) recordAssignment(name string) {
+	if len(e.defineLocals) == 0 {
+		return
+	}
+	if _, ok := e.defineLocals[len(e.defineLocals)-1][name]; ok {
+		e.writeAction("$.Assign " + strconv.Quote(name) + " $" + name)
+	}
+}
+
+// emitSettingNode validates settings and threads them through later emission.
+//
+// Settings are applied lexically, so they are only accepted outside if/list
+// blocks where conversion-time order matches FreeMarker's runtime order.
+func (e *emitter) emitSettingNode(n ast.SettingNode) error {
+	if len(e.scopes) > 1 {
 		return diagnostics.New(
-			"EMIT_UNSUPPORTED_RETURN",
+			"EMIT_UNSUPPORTED_SETTING_SCOPE",
 			e.file,
 			n.Position.Line,
 			n.Position.Column,
-			"unsupported <#return> outside converted function semantics",
-			n.Args,
+			"settings are only supported outside if/list blocks",
+			n.Raw,
 		)
+	}
+
+	next := e.settings.clone()
+	var ignored []string
+	for _, param := range n.Params {
+		key := settingKey(param.Key)
+		if _, ok := ignoredSettingKeys[key]; ok {
+			ignored = append(ignored, param.Key+"="+param.Value)
+			continue
+		}
+		value, err := settingValue(param.Value)
+		if err == nil {
+			err = validateSetting(key, value)
+		}
+		if err != nil {
+			return diagnostics.New("EMIT_UNSUPPORTED_SETTING", e.file, n.Position.Line, n.Position.Column, err.Error(), n.Raw)
+		}
+		next[key] = value
+	}
+	e.settings = next
+	for _, param := range n.Params {
+		if key := settingKey(param.Key); key == "output_format" || key == "auto_esc" {
+			e.rawOutput = next.rawOutput()
+		}
+	}
+
+	if len(ignored) > 0 {
+		label := "ftl setting"
+		if n.Directive == "ftl" {
+			label = "ftl header"
+		}
+		e.writeComment(label + " ignored: " + strings.Join(ignored, " "))
+	}
+	return nil
+}
+
+// emitBareDirectiveNode handles directives represented without a full block.
+func (e *emitter) emitBareDirectiveNode(n ast.BareDirectiveNode) error {
+	switch n.Name {
+	case "break", "continue":
+		if e.loopDepth == 0 && e.defineDepth > 0 {
+			return diagnostics.New(
+				"EMIT_UNSUPPORTED_"+strings.ToUpper(n.Name),
+				e.file,
+				n.Position.Line,
+				n.Position.Column,
+				fmt.Sprintf("<#%s> inside a capture, attempt or compress block cannot leave the enclosing list", n.Name),
+				"",
+			)
+		}
+		e.writeAction(n.Name)
+		return nil
+	case "flush":
+		e.writeComment("ftl flush ignored: Go templates have no explicit flush")
+		return nil
+	case "stop":
+		action := "stop"
+		if n.Args != "" {
+			msg, err := e.mapExprAt(n.Args, n.Position.Line, n.Position.Column)
+			if err != nil {
+				return err
+			}
+			action += " " + wrap(msg)
+		}
+		e.helpers["stop"] = struct{}{}
+		e.writeAction(action)
+		return nil
+	case "return":
+		return e.emitReturn(n)
 	default:
 		return diagnostics.New(
 			"EMIT_UNSUPPORTED_DIRECTIVE_NODE",
@@ -113,3 +428,24 @@ func (e *emitter) emitBareDirectiveNode(n ast.BareDirectiveNode) error {
 		)
 	}
 }
+
+// emitReturn converts a top-level <#return>, which ends the template without
+// an error: it sets the return flag that guards the rest of the template, and
+// leaves the enclosing loop.
+func (e *emitter) emitReturn(n ast.BareDirectiveNode) error {
+	reason := ""
+	switch {
+	case n.Args != "":
+		reason = "<#return> with a value is only valid inside a function"
+	case e.defineDepth > 0:
+		reason = "<#return> inside a capture, attempt or compress block is unsupported"
+	}
+	if reason != "" {
+		return diagnostics.New("EMIT_UNSUPPORTED_RETURN", e.file, n.Position.Line, n.Position.Column, reason, n.Args)
+	}
+	e.writeAction("$" + returnedVar + " = true")
+	if e.loopDepth > 0 {
+		e.writeAction("break")
+	}
+	return nil
+}
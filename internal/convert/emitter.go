@@ -2,8 +2,10 @@
 package convert
 
 import (
+	"errors"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/cruffinoni/ftl2gotpl/internal/ast"
@@ -17,21 +19,66 @@ type Result struct {
 	Output   string
 	Helpers  []string
 	Features []string
+	Delims   Delims
+}
+
+// Options tunes how templates are converted.
+type Options struct {
+	// StrictBooleans wraps <#if> and <#elseif> conditions in the asBool
+	// helper so non-boolean values fail at render time, as in FreeMarker,
+	// instead of following Go's truthiness rules.
+	StrictBooleans bool
+	// Delims sets the action delimiters of the output, like
+	// template.Delims; an empty delimiter stands for the default.
+	Delims Delims
+	// PreserveComments keeps <#-- ... --> comments as Go template
+	// comments, except those holding tool pragmas such as @ftlvariable.
+	PreserveComments bool
+}
+
+// Delims are the action delimiters of converted output, which must be
+// passed to template.Delims when parsing it.
+type Delims struct {
+	Left  string
+	Right string
+}
+
+// OrDefault fills empty delimiters with Go's defaults, {{ and }}.
+func (d Delims) OrDefault() Delims {
+	if d.Left == "" {
+		d.Left = "{{"
+	}
+	if d.Right == "" {
+		d.Right = "}}"
+	}
+	return d
 }
 
 // Converter transforms FreeMarker source into Go html/template source.
-type Converter struct{}
+type Converter struct {
+	opts Options
+}
 
-// NewConverter builds a stateless converter.
+// NewConverter builds a stateless converter with default options.
 func NewConverter() *Converter {
 	return &Converter{}
 }
 
+// NewConverterWithOptions builds a stateless converter using opts.
+func NewConverterWithOptions(opts Options) *Converter {
+	return &Converter{opts: opts}
+}
+
 func newEmitter(file string) *emitter {
 	return &emitter{
-		file:    file,
-		helpers: map[string]struct{}{},
-		scopes:  []map[string]struct{}{{}},
+		file:        file,
+		delims:      Delims{}.OrDefault(),
+		buf:         &strings.Builder{},
+		helpers:     map[string]struct{}{},
+		scopes:      []map[string]struct{}{{}},
+		settings:    settingsState{},
+		loopLengths: map[string]struct{}{},
+		matchVars:   map[string]struct{}{},
 	}
 }
 
@@ -47,35 +94,108 @@ func (c *Converter) Convert(file string, input string) (Result, error) {
 	}
 
 	e := newEmitter(file)
+	e.delims = c.opts.Delims.OrDefault()
+	e.strictBooleans = c.opts.StrictBooleans
+	e.preserveComments = c.opts.PreserveComments
 	if err := e.emitDocument(doc); err != nil {
 		return Result{}, err
 	}
+	if len(e.defines) > 0 {
+		e.splitDelimJoin(e.delims.Left)
+	}
+	for _, define := range e.defines {
+		e.buf.WriteString(define)
+	}
 	return Result{
 		Output:   e.buf.String(),
 		Helpers:  e.helperList(),
-		Features: detectFeatures(doc, e.helperList()),
+		Features: detectFeatures(doc, e.helperList(), e.preserveComments),
+		Delims:   e.delims,
 	}, nil
 }
 
+// returnedVar names the flag variable set by a top-level <#return>.
+const returnedVar = "ftlReturned"
+
 // emitter performs AST emission and tracks local variable scope.
 type emitter struct {
-	file    string
-	buf     strings.Builder
-	helpers map[string]struct{}
-	scopes  []map[string]struct{}
+	file        string
+	delims      Delims
+	buf         *strings.Builder
+	helpers     map[string]struct{}
+	scopes      []map[string]struct{}
+	settings    settingsState
+	loopLengths map[string]struct{}
+	// matchVars holds the variables last assigned a whole-string ?matches
+	// result.
+	matchVars map[string]struct{}
+	// defines holds {{define}} blocks, such as capture bodies, written
+	// after the template since Go only accepts them at the top level.
+	defines []string
+	// defineDepth counts the capture, attempt and compress bodies being
+	// emitted.
+	defineDepth int
+	// defineLocals stacks the locals visible around each define, whose
+	// assignments the define records in its captureScope.
+	defineLocals []map[string]struct{}
+	// recoverVars stacks the attempt results of enclosing recover blocks,
+	// which .error reads.
+	recoverVars []string
+	// escapes stacks the enclosing <#escape> blocks.
+	escapes []escapeRule
+	// rawOutput is set where FreeMarker would print interpolations without
+	// escaping, so they go through safeHTML.
+	rawOutput bool
+	// loopDepth counts the list blocks being emitted.
+	loopDepth int
+	// returns is set when the template has a top-level <#return>, tracked
+	// in the returnedVar flag.
+	returns bool
+
+	strictBooleans   bool
+	preserveComments bool
 }
 
-// emitDocument emits the parsed document in original order.
+// emitDocument emits the parsed document in original order, after declaring
+// the variables that blocks assign. A hoisted variable starts out as the data
+// model value of the same name, which FreeMarker reads until the assignment
+// runs.
 func (e *emitter) emitDocument(doc ast.Document) error {
+	for _, name := range hoistedVariables(doc.Nodes) {
+		e.helpers["safeAccess"] = struct{}{}
+		e.writeAction("$" + name + " := safeAccess . " + strconv.Quote(name))
+		e.scopes[0][name] = struct{}{}
+	}
+	if anyReturns(doc.Nodes) {
+		e.returns = true
+		e.writeAction("$" + returnedVar + " := false")
+	}
 	return e.emitNodes(doc.Nodes)
 }
 
-// emitNodes emits each node from a sequence.
+// emitNodes emits each node from a sequence. After a node that may run a
+// top-level <#return>, the remaining nodes only render while the return flag
+// is unset: inside a loop the loop is left, elsewhere they are guarded.
 func (e *emitter) emitNodes(nodes []ast.Node) error {
-	for _, node := range nodes {
+	for i, node := range nodes {
 		if err := e.emitNode(node); err != nil {
 			return err
 		}
+		if !e.returns || i == len(nodes)-1 || !containsReturn(node) {
+			continue
+		}
+		if e.loopDepth > 0 {
+			e.writeAction("if $" + returnedVar)
+			e.writeAction("break")
+			e.writeAction("end")
+			continue
+		}
+		e.writeAction("if not $" + returnedVar)
+		if err := e.emitNodes(nodes[i+1:]); err != nil {
+			return err
+		}
+		e.writeAction("end")
+		return nil
 	}
 	return nil
 }
@@ -84,14 +204,22 @@ func (e *emitter) emitNodes(nodes []ast.Node) error {
 func (e *emitter) emitNode(node ast.Node) error {
 	switch n := node.(type) {
 	case ast.TextNode:
-		e.buf.WriteString(n.Text)
+		e.writeText(n.Text)
+		return nil
+	case ast.CommentNode:
+		if e.preserveComments && !isPragmaComment(n.Text) {
+			e.writeComment(strings.TrimSpace(n.Text))
+		}
 		return nil
 	case ast.InterpolationNode:
-		expr, err := e.mapExprAt(n.Expr, n.Position.Line, n.Position.Column)
+		if n.AltStyle {
+			return e.emitNumericInterpolation(n)
+		}
+		expr, err := e.mapExprAt(e.escapeInterpolation(n.Expr), n.Position.Line, n.Position.Column)
 		if err != nil {
 			return err
 		}
-		e.writeAction(expr)
+		e.writeAction(e.unescapedOutput(e.interpolation(expr)))
 		return nil
 	case ast.IfNode:
 		return e.emitIfNode(n)
@@ -99,9 +227,20 @@ func (e *emitter) emitNode(node ast.Node) error {
 		return e.emitListNode(n)
 	case ast.AssignNode:
 		return e.emitAssignNode(n)
+	case ast.AttemptNode:
+		return e.emitAttemptNode(n)
+	case ast.CompressNode:
+		return e.emitCompressNode(n)
+	case ast.EscapeNode:
+		return e.emitEscapeNode(n)
+	case ast.NoEscapeNode:
+		return e.emitNoEscapeNode(n)
+	case ast.OutputFormatNode:
+		return e.emitOutputFormatNode(n)
+	case ast.AutoEscNode:
+		return e.emitAutoEscNode(n)
 	case ast.SettingNode:
-		e.writeComment("ftl setting ignored: " + n.Raw)
-		return nil
+		return e.emitSettingNode(n)
 	case ast.FunctionNode:
 		if n.Name == "formatPrice" {
 			e.helpers["formatPrice"] = struct{}{}
@@ -141,11 +280,42 @@ func (e *emitter) emitNode(node ast.Node) error {
 
 // mapExprAt maps a FreeMarker expression and keeps source location on errors.
 func (e *emitter) mapExprAt(expr string, line int, col int) (string, error) {
+	return e.mapExprIn(expr, line, col, false)
+}
+
+// mapSequenceAt maps the sequence of a list directive, where a trailing
+// ?matches lists the matches instead of testing the whole string.
+func (e *emitter) mapSequenceAt(expr string, line int, col int) (string, error) {
+	return e.mapExprIn(expr, line, col, true)
+}
+
+func (e *emitter) mapExprIn(expr string, line int, col int, sequence bool) (string, error) {
 	mapper := newExpressionMapper(e.currentLocals())
+	mapper.settings = e.settings
+	mapper.templateName = e.file
+	mapper.sequence = sequence
+	mapper.inDefine = e.defineDepth > 0
+	mapper.strictBooleans = e.strictBooleans
+	mapper.matchVars = e.matchVars
+	if len(e.recoverVars) > 0 {
+		mapper.errorVar = e.recoverVars[len(e.recoverVars)-1]
+	}
 	mapped, err := mapper.mapExpr(expr)
+	if err == nil && sequence {
+		err = mapper.checkMatchResult(mapped, "a list source")
+	}
 	if err != nil {
+		code := "EMIT_EXPRESSION_MAP"
+		var regexErr regexError
+		var matchErr matchResultError
+		switch {
+		case errors.As(err, &regexErr):
+			code = "EMIT_UNSUPPORTED_REGEX"
+		case errors.As(err, &matchErr):
+			code = "EMIT_UNSUPPORTED_MATCHES_RESULT"
+		}
 		return "", diagnostics.New(
-			"EMIT_EXPRESSION_MAP",
+			code,
 			e.file,
 			line,
 			col,
@@ -156,21 +326,129 @@ func (e *emitter) mapExprAt(expr string, line int, col int) (string, error) {
 	for _, h := range mapper.helperList() {
 		e.helpers[h] = struct{}{}
 	}
+	for item := range mapper.loopLengths {
+		e.loopLengths[item] = struct{}{}
+	}
 	return mapped, nil
 }
 
+// captureOutput runs fn against a fresh buffer and returns what it emitted.
+func (e *emitter) captureOutput(fn func() error) (string, error) {
+	outer := e.buf
+	e.buf = &strings.Builder{}
+	err := fn()
+	// The output is followed by an action wherever it is used.
+	e.splitDelimJoin(e.delims.Left)
+	out := e.buf.String()
+	e.buf = outer
+	return out, err
+}
+
+// interpolation routes a mapped ${...} expression through FreeMarker value
+// formatting, unless the expression already yields text (for example ?c).
+func (e *emitter) interpolation(expr string) string {
+	if producesText(expr) {
+		return expr
+	}
+	e.helpers["interpolate"] = struct{}{}
+	settings := e.settings.helperArg()
+	if settings == "" {
+		return "interpolate " + wrap(expr)
+	}
+	e.helpers["ftlSettings"] = struct{}{}
+	return "interpolate " + wrap(expr) + " " + settings
+}
+
+// emitNumericInterpolation converts the legacy #{expr} and #{expr; mXMY}
+// forms, which print numbers in the computer-language format with the
+// requested minimum (mX) and maximum (MY) fraction digits.
+func (e *emitter) emitNumericInterpolation(n ast.InterpolationNode) error {
+	minFrac, maxFrac := 0, maxNumericFractionDigits
+	raw := n.Expr
+	// The format follows the last top-level ";", since entity operators such
+	// as &gt; contain one too.
+	if parts := splitTopLevel(n.Expr, ";"); len(parts) > 0 {
+		spec := parts[len(parts)-1]
+		if spec == "" || spec[0] == 'm' || spec[0] == 'M' {
+			var err error
+			minFrac, maxFrac, err = parseNumericFormat(spec)
+			if err != nil {
+				return diagnostics.New("EMIT_INVALID_NUMERIC_FORMAT", e.file, n.Position.Line, n.Position.Column, err.Error(), n.Expr)
+			}
+			raw = strings.Join(parts[:len(parts)-1], ";")
+		}
+	}
+	expr, err := e.mapExprAt(raw, n.Position.Line, n.Position.Column)
+	if err != nil {
+		return err
+	}
+	e.helpers["formatNumeric"] = struct{}{}
+	e.writeAction(fmt.Sprintf("formatNumeric %s %d %d", wrap(expr), minFrac, maxFrac))
+	return nil
+}
+
+// isPragmaComment reports whether a FreeMarker comment holds a directive for
+// a tool rather than a note, like IntelliJ's <#-- @ftlvariable ... --> and
+// <#-- noinspection ... -->.
+func isPragmaComment(text string) bool {
+	text = strings.TrimSpace(text)
+	return strings.HasPrefix(text, "@") || strings.HasPrefix(strings.ToLower(text), "noinspection")
+}
+
+// action wraps a raw Go template action in the output delimiters.
+func (e *emitter) action(action string) string {
+	return e.delims.Left + action + e.delims.Right
+}
+
 // writeAction writes a raw Go template action.
 func (e *emitter) writeAction(action string) {
-	e.buf.WriteString("{{")
-	e.buf.WriteString(action)
-	e.buf.WriteString("}}")
+	e.splitDelimJoin(e.delims.Left)
+	e.buf.WriteString(e.action(action))
 }
 
 // writeComment writes a Go template comment, escaping comment terminators.
 func (e *emitter) writeComment(text string) {
-	e.buf.WriteString("{{/* ")
-	e.buf.WriteString(strings.ReplaceAll(text, "*/", "* /"))
-	e.buf.WriteString(" */}}")
+	e.splitDelimJoin(e.delims.Left)
+	e.buf.WriteString(e.action("/* " + strings.ReplaceAll(text, "*/", "* /") + " */"))
+}
+
+// writeText writes literal template text, printing each left delimiter in it
+// through an action such as {{"{{"}} so Go does not read it as one.
+func (e *emitter) writeText(text string) {
+	if text == "" {
+		return
+	}
+	e.splitDelimJoin(text)
+	left := e.delims.Left
+	for {
+		i := strings.Index(text, left)
+		if i < 0 {
+			break
+		}
+		e.buf.WriteString(text[:i])
+		e.writeAction(strconv.Quote(left))
+		text = text[i+len(left):]
+	}
+	e.buf.WriteString(text)
+}
+
+// splitDelimJoin keeps the end of the output from forming a left delimiter
+// with next, as text ending in "{" would before an action: the partial
+// delimiter is printed through an action instead.
+func (e *emitter) splitDelimJoin(next string) {
+	out := e.buf.String()
+	left := e.delims.Left
+	if strings.HasSuffix(out, e.delims.Right) {
+		return
+	}
+	for n := len(left) - 1; n > 0; n-- {
+		if strings.HasSuffix(out, left[:n]) && strings.HasPrefix(next, left[n:]) {
+			e.buf.Reset()
+			e.buf.WriteString(out[:len(out)-n])
+			e.buf.WriteString(e.action(strconv.Quote(left[:n])))
+			return
+		}
+	}
 }
 
 // helperList returns deterministic helper names used during expression mapping.
@@ -0,0 +1,135 @@
+package convert
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cruffinoni/ftl2gotpl/internal/ast"
+	"github.com/cruffinoni/ftl2gotpl/internal/diagnostics"
+)
+
+// htmlEscapes lists the escape builtins that html/template's contextual
+// escaping already covers, so <#escape x as x?html> adds nothing.
+var htmlEscapes = map[string]struct{}{
+	"?html":  {},
+	"?xhtml": {},
+	"?xml":   {},
+}
+
+// escapeRule is one active <#escape> block.
+type escapeRule struct {
+	// suffix is applied to each interpolation, "?url" for
+	// <#escape x as x?url>. It is empty for HTML escaping, which
+	// html/template does on its own.
+	suffix string
+}
+
+// emitEscapeNode converts <#escape x as expr>. HTML escaping is dropped as
+// redundant; other expressions must apply builtins or a default to x, and
+// are appended to each interpolation in the body.
+func (e *emitter) emitEscapeNode(n ast.EscapeNode) error {
+	suffix, ok := strings.CutPrefix(n.Expr, n.Var)
+	if !ok || suffix == "" || (suffix[0] != '?' && suffix[0] != '!') {
+		return diagnostics.New(
+			"EMIT_UNSUPPORTED_ESCAPE",
+			e.file,
+			n.Position.Line,
+			n.Position.Column,
+			fmt.Sprintf("escape expression must apply builtins to %s, as in %s?html", n.Var, n.Var),
+			n.Expr,
+		)
+	}
+	if _, ok := htmlEscapes[suffix]; ok {
+		suffix = ""
+	}
+	e.escapes = append(e.escapes, escapeRule{suffix: suffix})
+	err := e.emitNodes(n.Body)
+	e.escapes = e.escapes[:len(e.escapes)-1]
+	return err
+}
+
+// emitNoEscapeNode converts <#noescape>. The enclosing escape expressions
+// are suspended; if one of them escaped HTML, the body's interpolations are
+// printed through safeHTML so html/template leaves them unescaped too.
+func (e *emitter) emitNoEscapeNode(n ast.NoEscapeNode) error {
+	if len(e.escapes) == 0 {
+		return diagnostics.New("EMIT_INVALID_NOESCAPE", e.file, n.Position.Line, n.Position.Column, "<#noescape> used outside an <#escape> block", "")
+	}
+	escapes, raw := e.escapes, e.rawOutput
+	for _, rule := range escapes {
+		if rule.suffix == "" {
+			e.rawOutput = true
+		}
+	}
+	e.escapes = nil
+	err := e.emitNodes(n.Body)
+	e.escapes, e.rawOutput = escapes, raw
+	return err
+}
+
+// emitOutputFormatNode converts <#outputformat "name">. The markup formats
+// are what html/template escapes for; plainText output is printed through
+// safeHTML. The other formats escape differently from html/template and
+// fail with a diagnostic.
+func (e *emitter) emitOutputFormatNode(n ast.OutputFormatNode) error {
+	quoted, isString, err := normalizeStringLiteral(n.Format)
+	var format string
+	if err == nil && isString {
+		format, err = strconv.Unquote(quoted)
+	}
+	if err != nil || !isString {
+		return diagnostics.New("EMIT_UNSUPPORTED_OUTPUT_FORMAT", e.file, n.Position.Line, n.Position.Column, "outputformat name must be a string literal", n.Format)
+	}
+
+	raw := e.rawOutput
+	switch format {
+	case "HTML", "XHTML", "XML":
+		e.rawOutput = false
+	case "plainText":
+		e.rawOutput = true
+	default:
+		return diagnostics.New(
+			"EMIT_UNSUPPORTED_OUTPUT_FORMAT",
+			e.file,
+			n.Position.Line,
+			n.Position.Column,
+			fmt.Sprintf("output format %q has no html/template equivalent", format),
+			n.Format,
+		)
+	}
+	err = e.emitNodes(n.Body)
+	e.rawOutput = raw
+	return err
+}
+
+// emitAutoEscNode converts <#autoesc> and <#noautoesc>: auto-escaping is
+// html/template's default, and turning it off prints through safeHTML.
+func (e *emitter) emitAutoEscNode(n ast.AutoEscNode) error {
+	raw := e.rawOutput
+	e.rawOutput = !n.Enabled
+	err := e.emitNodes(n.Body)
+	e.rawOutput = raw
+	return err
+}
+
+// escapeInterpolation applies the active escape expressions to a ${...}
+// expression, innermost first as FreeMarker nests them.
+func (e *emitter) escapeInterpolation(expr string) string {
+	for i := len(e.escapes) - 1; i >= 0; i-- {
+		if suffix := e.escapes[i].suffix; suffix != "" {
+			expr = "(" + expr + ")" + suffix
+		}
+	}
+	return expr
+}
+
+// unescapedOutput marks an interpolation action as safe HTML when automatic
+// escaping is turned off.
+func (e *emitter) unescapedOutput(action string) string {
+	if !e.rawOutput || strings.HasPrefix(action, "safeHTML ") {
+		return action
+	}
+	e.helpers["safeHTML"] = struct{}{}
+	return "safeHTML " + wrap(action)
+}
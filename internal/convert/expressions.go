@@ -12,10 +12,81 @@ import (
 
 var numberLiteralRe = regexp.MustCompile(`^-?\d+(\.\d+)?$`)
 
+// datetimeParseHelpers maps ?date, ?time and ?datetime to their helpers.
+var datetimeParseHelpers = map[string]string{
+	"date":     "parseDate",
+	"time":     "parseTime",
+	"datetime": "parseDatetime",
+}
+
+// textHelpers lists helpers whose result is already formatted text, so
+// interpolations of their results skip FreeMarker value formatting.
+var textHelpers = map[string]struct{}{
+	"computerString": {},
+	"formatBoolean":  {},
+	"formatNumeric":  {},
+	"formatPrice":    {},
+	"loopParity":     {},
+	"loopParityCap":  {},
+	"replace":        {},
+	"safeHTML":       {},
+	"substring":      {},
+	"templateName":   {},
+	"toString":       {},
+	"trim":           {},
+}
+
+// missingSensitiveHelpers lists the helpers, plus Go's len, that fail on a
+// missing argument and so need guarding inside a parenthesized ! or ??.
+var missingSensitiveHelpers = func() map[string]struct{} {
+	tolerant := map[string]struct{}{
+		"default": {}, "defaultEmpty": {}, "exists": {}, "ftlSettings": {}, "hasContent": {},
+		"now": {}, "optional": {}, "safeAccess": {}, "safeAccessLast": {},
+	}
+	names := map[string]struct{}{"len": {}}
+	for name := range NewFuncMap(FuncMapOptions{}) {
+		if _, ok := tolerant[name]; !ok {
+			names[name] = struct{}{}
+		}
+	}
+	return names
+}()
+
+// producesText reports whether a mapped expression always yields text.
+func producesText(mapped string) bool {
+	if strings.HasPrefix(mapped, `"`) {
+		return true
+	}
+	name, _, _ := strings.Cut(mapped, " ")
+	_, ok := textHelpers[name]
+	return ok
+}
+
 // expressionMapper rewrites FreeMarker expressions to Go template expressions.
 type expressionMapper struct {
-	locals  map[string]struct{}
-	helpers map[string]struct{}
+	locals       map[string]struct{}
+	helpers      map[string]struct{}
+	settings     settingsState
+	loopLengths  map[string]struct{}
+	templateName string
+	// lenient is set while mapping the operand of a parenthesized ! or ??,
+	// where a missing value at any step yields the default.
+	lenient bool
+	// sequence is set while mapping a list source, where a trailing
+	// ?matches lists the matches instead of testing the whole string.
+	sequence bool
+	// strictBooleans checks that the operands of &&, || and ! are
+	// booleans, through the asBool helper.
+	strictBooleans bool
+	// inDefine is set inside capture, attempt and compress bodies, where $
+	// is the captureScope and the data model is $.Root.
+	inDefine bool
+	// errorVar names the attempt result read by .error inside a recover
+	// block.
+	errorVar string
+	// matchVars holds the variables assigned a whole-string ?matches
+	// result, which is a boolean rather than a match sequence.
+	matchVars map[string]struct{}
 }
 
 func newExpressionMapper(locals map[string]struct{}) *expressionMapper {
@@ -24,9 +95,35 @@ func newExpressionMapper(locals map[string]struct{}) *expressionMapper {
 		cp[k] = struct{}{}
 	}
 	return &expressionMapper{
-		locals:  cp,
-		helpers: map[string]struct{}{},
+		locals:      cp,
+		helpers:     map[string]struct{}{},
+		loopLengths: map[string]struct{}{},
+	}
+}
+
+// checkMatchResult rejects a variable holding a ?matches result where a
+// match sequence is needed, since the variable only holds the boolean.
+func (m *expressionMapper) checkMatchResult(mapped string, use string) error {
+	name, ok := strings.CutPrefix(mapped, "$")
+	if !ok {
+		return nil
+	}
+	if _, isMatch := m.matchVars[name]; isMatch {
+		return matchResultError{name: name, use: use}
+	}
+	return nil
+}
+
+// loopItem returns the list item variable behind a mapped loop builtin receiver.
+func (m *expressionMapper) loopItem(current string, builtin string) (string, error) {
+	if !strings.HasPrefix(current, "$") || strings.ContainsAny(current[1:], ".[ ") {
+		return "", fmt.Errorf("?%s is only supported on loop item variables", builtin)
+	}
+	item := strings.TrimPrefix(current, "$")
+	if _, ok := m.locals[item+"_index"]; !ok {
+		return "", fmt.Errorf("?%s is only supported on loop item variables", builtin)
 	}
+	return item, nil
 }
 
 // setLocal records a variable for future local identifier resolution.
@@ -113,6 +210,9 @@ func (m *expressionMapper) resolveIdentifier(expr string) (string, error) {
 	if strings.HasPrefix(expr, "$") {
 		return expr, nil
 	}
+	if strings.HasPrefix(expr, ".") {
+		return m.resolveSpecialVariable(expr)
+	}
 	first, rest, ok := splitPath(expr)
 	if !ok {
 		return "", fmt.Errorf("unsupported identifier expression %q", expr)
@@ -121,6 +221,21 @@ func (m *expressionMapper) resolveIdentifier(expr string) (string, error) {
 	if _, exists := m.locals[first]; exists {
 		current = "$" + first
 	}
+	return m.resolvePathRest(current, rest, expr)
+}
+
+// boolOperand wraps the mapped operand of a logical operator in asBool when
+// strict booleans are enabled, since Go's and, or and not accept any value.
+func (m *expressionMapper) boolOperand(mapped string) string {
+	if !m.strictBooleans || strings.HasPrefix(mapped, "asBool ") {
+		return mapped
+	}
+	m.helpers["asBool"] = struct{}{}
+	return "asBool " + wrap(mapped)
+}
+
+// resolvePathRest appends dotted and bracketed path segments to a resolved root.
+func (m *expressionMapper) resolvePathRest(current string, rest string, expr string) (string, error) {
 	for i := 0; i < len(rest); {
 		switch rest[i] {
 		case '.':
@@ -136,7 +251,11 @@ func (m *expressionMapper) resolveIdentifier(expr string) (string, error) {
 			if j == i+1 {
 				return "", fmt.Errorf("unsupported identifier expression %q", expr)
 			}
-			current = wrap(current) + rest[i:j]
+			if current == "$" {
+				current += rest[i:j]
+			} else {
+				current = wrap(current) + rest[i:j]
+			}
 			i = j
 		case '[':
 			end := findMatchingBracket(rest, i)
@@ -160,6 +279,91 @@ func (m *expressionMapper) resolveIdentifier(expr string) (string, error) {
 	return current, nil
 }
 
+// resolveSpecialVariable maps FreeMarker special variables such as .now.
+func (m *expressionMapper) resolveSpecialVariable(expr string) (string, error) {
+	name, rest, ok := splitPath(expr[1:])
+	if !ok {
+		return "", fmt.Errorf("unsupported identifier expression %q", expr)
+	}
+	switch name {
+	case "vars":
+		return m.resolveVarsLookup(expr, rest)
+	case "data_model":
+		root := "$"
+		if m.inDefine {
+			root = "$.Root"
+		}
+		return m.resolvePathRest(root, rest, expr)
+	}
+	if rest != "" {
+		return "", fmt.Errorf("special variable .%s does not support path access", name)
+	}
+
+	locale := m.settings["locale"]
+	if locale == "" {
+		locale = defaultLocale
+	}
+	switch name {
+	case "now":
+		m.helpers["now"] = struct{}{}
+		return "now", nil
+	case "locale":
+		return strconv.Quote(locale), nil
+	case "lang":
+		lang, _, _ := strings.Cut(locale, "_")
+		return strconv.Quote(lang), nil
+	case "template_name", "current_template_name", "main_template_name":
+		m.helpers["templateName"] = struct{}{}
+		return "templateName " + strconv.Quote(m.templateName), nil
+	case "error":
+		if m.errorVar == "" {
+			return "", fmt.Errorf("special variable .error is only available inside <#recover>")
+		}
+		return "$" + m.errorVar + ".Message", nil
+	default:
+		return "", fmt.Errorf("unsupported special variable .%s", name)
+	}
+}
+
+// resolveVarsLookup maps .vars["name"] and .vars.name to the named variable.
+func (m *expressionMapper) resolveVarsLookup(expr string, rest string) (string, error) {
+	var name string
+	switch {
+	case strings.HasPrefix(rest, "."):
+		first, tail, ok := splitPath(rest[1:])
+		if !ok {
+			return "", fmt.Errorf("unsupported identifier expression %q", expr)
+		}
+		name, rest = first, tail
+	case strings.HasPrefix(rest, "["):
+		end := findMatchingBracket(rest, 0)
+		if end < 0 {
+			return "", fmt.Errorf("unsupported identifier expression %q", expr)
+		}
+		key := strings.TrimSpace(rest[1:end])
+		normalized, isString, err := normalizeStringLiteral(key)
+		if err != nil {
+			return "", err
+		}
+		if !isString || !isLiteral(key) {
+			return "", fmt.Errorf(".vars lookups require a string literal key, got %q", key)
+		}
+		name, err = strconv.Unquote(normalized)
+		if err != nil {
+			return "", err
+		}
+		rest = rest[end+1:]
+	default:
+		return "", fmt.Errorf(".vars must be followed by a variable name")
+	}
+
+	if first, tail, ok := splitPath(name); !ok || tail != "" || first != name {
+		m.helpers["safeAccess"] = struct{}{}
+		return m.resolvePathRest("(safeAccess . "+strconv.Quote(name)+")", rest, expr)
+	}
+	return m.resolveIdentifier(name + rest)
+}
+
 // helperList returns sorted helper names required by mapped expressions.
 func (m *expressionMapper) helperList() []string {
 	out := make([]string, 0, len(m.helpers))
@@ -173,6 +377,8 @@ func (m *expressionMapper) helperList() []string {
 type builtinCall struct {
 	name string
 	args string
+	// path holds [key] lookups applied to the builtin's result.
+	path string
 }
 
 // firstTopLevelQuestion returns the first top-level question-mark index.
@@ -292,7 +498,15 @@ func parseBuiltinChain(expr string) (string, []builtinCall, bool) {
 			args = strings.TrimSpace(expr[i+1 : end])
 			i = end + 1
 		}
-		calls = append(calls, builtinCall{name: name, args: args})
+		pathStart := i
+		for i < len(expr) && expr[i] == '[' {
+			end := findMatchingBracket(expr, i)
+			if end < 0 {
+				return "", nil, false
+			}
+			i = end + 1
+		}
+		calls = append(calls, builtinCall{name: name, args: args, path: expr[pathStart:i]})
 	}
 
 	return base, calls, true
@@ -367,6 +581,73 @@ func parseFunctionCall(expr string) (string, []string, bool) {
 	return name, splitArgs(rawArgs), true
 }
 
+// parseMethodCall splits a Java method call such as user.getFullName() or
+// items.get(0) into its receiver, method name and raw arguments. The call
+// must end the expression, so a.getB().getC() has the receiver a.getB().
+func parseMethodCall(expr string) (string, string, []string, bool) {
+	expr = strings.TrimSpace(expr)
+	if !strings.HasSuffix(expr, ")") {
+		return "", "", nil, false
+	}
+	open := -1
+	quote := byte(0)
+	escaped := false
+	for i := 0; i < len(expr); i++ {
+		ch := expr[i]
+		if quote != 0 {
+			if escaped {
+				escaped = false
+			} else if ch == '\\' {
+				escaped = true
+			} else if ch == quote {
+				quote = 0
+			}
+			continue
+		}
+		if ch == '"' || ch == '\'' {
+			quote = ch
+			continue
+		}
+		if ch != '(' {
+			continue
+		}
+		end := findMatchingParen(expr, i)
+		if end < 0 {
+			return "", "", nil, false
+		}
+		if end == len(expr)-1 {
+			open = i
+			break
+		}
+		i = end
+	}
+	if open < 0 {
+		return "", "", nil, false
+	}
+	dot := strings.LastIndexByte(expr[:open], '.')
+	if dot <= 0 {
+		return "", "", nil, false
+	}
+	name := expr[dot+1 : open]
+	if name == "" || !unicode.IsLetter(rune(name[0])) {
+		return "", "", nil, false
+	}
+	for i := 0; i < len(name); i++ {
+		if ch := name[i]; ch != '_' && !unicode.IsLetter(rune(ch)) && !unicode.IsDigit(rune(ch)) {
+			return "", "", nil, false
+		}
+	}
+	receiver := strings.TrimSpace(expr[:dot])
+	if receiver == "" {
+		return "", "", nil, false
+	}
+	rawArgs := strings.TrimSpace(expr[open+1 : len(expr)-1])
+	if rawArgs == "" {
+		return receiver, name, nil, true
+	}
+	return receiver, name, splitArgs(rawArgs), true
+}
+
 func splitTopLevel(expr string, sep string) []string {
 	var parts []string
 	start := 0
@@ -415,7 +696,28 @@ func splitTopLevel(expr string, sep string) []string {
 	return parts
 }
 
+// comparisonKeywords maps FreeMarker's keyword and entity comparison forms,
+// which avoid a bare > inside tags, to their symbolic operators.
+var comparisonKeywords = []struct {
+	word string
+	op   string
+}{
+	{"&gt;=", ">="},
+	{"&gt;", ">"},
+	{"&lt;=", "<="},
+	{"&lt;", "<"},
+	{"gte", ">="},
+	{"gt", ">"},
+	{"lte", "<="},
+	{"lt", "<"},
+}
+
 func splitTopLevelCompare(expr string) (string, string, string, bool) {
+	for _, kw := range comparisonKeywords {
+		if lhs, rhs, ok := splitTopLevelWord(expr, kw.word); ok {
+			return lhs, rhs, kw.op, true
+		}
+	}
 	for _, op := range []string{"==", "!=", ">=", "<=", ">", "<", "="} {
 		parts := splitTopLevel(expr, op)
 		if len(parts) == 2 {
@@ -428,7 +730,10 @@ func splitTopLevelCompare(expr string) (string, string, string, bool) {
 	return "", "", "", false
 }
 
-func hasTopLevelArithmetic(expr string) bool {
+// splitTopLevelWord splits expr around the first top-level occurrence of an
+// operator word. Alphanumeric words such as "gt" only match when they are not
+// part of a longer name.
+func splitTopLevelWord(expr string, word string) (string, string, bool) {
 	depth := 0
 	quote := byte(0)
 	escaped := false
@@ -453,37 +758,52 @@ func hasTopLevelArithmetic(expr string) bool {
 			continue
 		}
 		switch ch {
-		case '(':
+		case '(', '[', '{':
 			depth++
-		case ')':
+			continue
+		case ')', ']', '}':
 			if depth > 0 {
 				depth--
 			}
-		case '+', '*', '/':
-			if depth == 0 {
-				return true
-			}
-		case '-':
-			if depth == 0 {
-				prev := byte(0)
-				next := byte(0)
-				if i > 0 {
-					prev = expr[i-1]
-				}
-				if i+1 < len(expr) {
-					next = expr[i+1]
-				}
-				if (unicode.IsLetter(rune(prev)) || unicode.IsDigit(rune(prev)) || prev == ')' || prev == '"') &&
-					(unicode.IsLetter(rune(next)) || unicode.IsDigit(rune(next)) || next == '(' || next == '"') {
-					return true
-				}
-			}
+			continue
 		}
+		if depth != 0 || !strings.HasPrefix(expr[i:], word) {
+			continue
+		}
+		end := i + len(word)
+		if isNameByte(word[0]) && ((i > 0 && isNameByte(expr[i-1])) || (end < len(expr) && isNameByte(expr[end]))) {
+			continue
+		}
+		left := strings.TrimSpace(expr[:i])
+		right := strings.TrimSpace(expr[end:])
+		if left == "" || right == "" {
+			continue
+		}
+		return left, right, true
 	}
-	return false
+	return "", "", false
 }
 
-func splitTopLevelDefault(expr string) (string, string, bool) {
+// isNameByte reports whether ch can appear in a variable name or path.
+func isNameByte(ch byte) bool {
+	return ch == '_' || ch == '.' || ch == '?' || ch == '$' ||
+		(ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9')
+}
+
+// arithmeticHelpers maps FreeMarker's binary arithmetic operators to helpers.
+var arithmeticHelpers = map[byte]string{
+	'+': "add",
+	'-': "subtract",
+	'*': "multiply",
+	'/': "divide",
+	'%': "modulo",
+}
+
+// splitTopLevelArithmetic splits expr at the binary arithmetic operator that
+// binds loosest: the rightmost top-level + or -, else the rightmost *, / or %,
+// which keeps FreeMarker's left associativity.
+func splitTopLevelArithmetic(expr string) (string, string, byte, bool) {
+	additive, multiplicative := -1, -1
 	depth := 0
 	quote := byte(0)
 	escaped := false
@@ -508,30 +828,87 @@ func splitTopLevelDefault(expr string) (string, string, bool) {
 			continue
 		}
 		switch ch {
-		case '(':
+		case '(', '[', '{':
 			depth++
-		case ')':
+		case ')', ']', '}':
+			if depth > 0 {
+				depth--
+			}
+		case '+', '-':
+			if depth == 0 && endsOperand(expr[:i]) {
+				additive = i
+			}
+		case '*', '/', '%':
+			if depth == 0 && endsOperand(expr[:i]) {
+				multiplicative = i
+			}
+		}
+	}
+	at := additive
+	if at < 0 {
+		at = multiplicative
+	}
+	if at < 0 {
+		return "", "", 0, false
+	}
+	left := strings.TrimSpace(expr[:at])
+	right := strings.TrimSpace(expr[at+1:])
+	if right == "" {
+		return "", "", 0, false
+	}
+	return left, right, expr[at], true
+}
+
+// endsOperand reports whether prefix ends with a complete operand, so that an
+// operator following it is binary rather than a unary sign.
+func endsOperand(prefix string) bool {
+	prefix = strings.TrimRight(prefix, " \t\r\n")
+	if prefix == "" {
+		return false
+	}
+	prev := rune(prefix[len(prefix)-1])
+	return unicode.IsLetter(prev) || unicode.IsDigit(prev) || strings.ContainsRune("_)]}\"'", prev)
+}
+
+// defaultOperatorIndex returns the position of the first top-level ! used
+// as the default value operator, or -1. A ! directly after an operand is a
+// default operator; anywhere else it negates, and != compares.
+func defaultOperatorIndex(expr string) int {
+	depth := 0
+	quote := byte(0)
+	escaped := false
+	for i := 0; i < len(expr); i++ {
+		ch := expr[i]
+		if quote != 0 {
+			if escaped {
+				escaped = false
+				continue
+			}
+			if ch == '\\' {
+				escaped = true
+				continue
+			}
+			if ch == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch ch {
+		case '"', '\'':
+			quote = ch
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
 			if depth > 0 {
 				depth--
 			}
 		case '!':
-			if depth == 0 {
-				next := byte(0)
-				if i+1 < len(expr) {
-					next = expr[i+1]
-				}
-				if next == '=' {
-					continue
-				}
-				left := strings.TrimSpace(expr[:i])
-				right := strings.TrimSpace(expr[i+1:])
-				if left != "" && right != "" {
-					return left, right, true
-				}
+			if depth == 0 && !strings.HasPrefix(expr[i:], "!=") && endsOperand(expr[:i]) {
+				return i
 			}
 		}
 	}
-	return "", "", false
+	return -1
 }
 
 func stripOuterParen(expr string) (string, bool) {
@@ -569,7 +946,13 @@ func isLiteral(expr string) bool {
 			return true
 		}
 	}
-	return false
+	return isRawStringLiteral(expr)
+}
+
+// isRawStringLiteral reports whether expr is a raw string such as r"\d+",
+// whose backslashes are not escapes.
+func isRawStringLiteral(expr string) bool {
+	return len(expr) >= 3 && expr[0] == 'r' && (expr[1] == '"' || expr[1] == '\'') && expr[len(expr)-1] == expr[1]
 }
 
 func unescapeSingleQuotedString(expr string) (string, error) {
@@ -617,6 +1000,9 @@ func normalizeStringLiteral(expr string) (string, bool, error) {
 	if len(expr) < 2 {
 		return expr, false, nil
 	}
+	if isRawStringLiteral(expr) {
+		return strconv.Quote(expr[2 : len(expr)-1]), true, nil
+	}
 	if expr[0] == '"' && expr[len(expr)-1] == '"' {
 		return expr, true, nil
 	}
@@ -638,7 +1024,7 @@ func joinWrapped(parts []string) string {
 	return strings.Join(out, " ")
 }
 
-func (m *expressionMapper) mapSafeAccessIdentifierPath(expr string) (string, bool, error) {
+func (m *expressionMapper) mapSafeAccessIdentifierPath(expr string, lenient bool) (string, bool, error) {
 	expr = strings.TrimSpace(expr)
 	if expr == "" || expr == "." {
 		return "", false, nil
@@ -724,12 +1110,40 @@ func (m *expressionMapper) mapSafeAccessIdentifierPath(expr string) (string, boo
 		return "", false, nil
 	}
 
-	m.helpers["safeAccess"] = struct{}{}
-	return "safeAccess " + strings.Join(parts, " "), true, nil
+	helper := "safeAccess"
+	if !lenient && len(parts) > 2 {
+		helper = "safeAccessLast"
+	}
+	m.helpers[helper] = struct{}{}
+	return helper + " " + strings.Join(parts, " "), true, nil
 }
 
+// guardMissing makes a mapped helper call yield a missing value instead of
+// failing when one of its arguments is missing, by routing it through the
+// optional helper. Calls to helpers that accept missing values are kept.
+func (m *expressionMapper) guardMissing(mapped string) string {
+	name, args, ok := strings.Cut(mapped, " ")
+	if !ok {
+		return mapped
+	}
+	if _, guarded := missingSensitiveHelpers[name]; !guarded {
+		return mapped
+	}
+	m.helpers["optional"] = struct{}{}
+	return "optional " + strconv.Quote(name) + " " + args
+}
+
+// mapMissingOperand maps the left operand of ! or ??. A parenthesized operand
+// may go missing at any step, including inside builtins applied to it, while
+// a bare path may only miss its last step.
 func (m *expressionMapper) mapMissingOperand(expr string) (string, error) {
-	if mapped, ok, err := m.mapSafeAccessIdentifierPath(expr); err != nil {
+	if inner, ok := stripOuterParen(expr); ok {
+		prev := m.lenient
+		m.lenient = true
+		defer func() { m.lenient = prev }()
+		return m.mapExpr(inner)
+	}
+	if mapped, ok, err := m.mapSafeAccessIdentifierPath(expr, m.lenient); err != nil {
 		return "", err
 	} else if ok {
 		return mapped, nil
@@ -739,69 +1153,74 @@ func (m *expressionMapper) mapMissingOperand(expr string) (string, error) {
 
 // mapExpr converts one FreeMarker expression into its Go template equivalent.
 func (m *expressionMapper) mapExpr(expr string) (string, error) {
+	mapped, err := m.mapExprUnguarded(expr)
+	if err != nil || !m.lenient {
+		return mapped, err
+	}
+	return m.guardMissing(mapped), nil
+}
+
+// mapExprUnguarded maps expr without guarding its outermost helper call
+// against missing arguments; mapExpr adds that guard in lenient mode.
+func (m *expressionMapper) mapExprUnguarded(expr string) (string, error) {
 	expr = strings.TrimSpace(expr)
 	if expr == "" {
 		return "", fmt.Errorf("empty expression")
 	}
+	sequence := m.sequence
+	m.sequence = false
 
-	if strings.HasPrefix(expr, "!") && !strings.HasPrefix(expr, "!=") {
-		inner, err := m.mapExpr(strings.TrimSpace(expr[1:]))
-		if err != nil {
-			return "", err
-		}
-		m.helpers["not"] = struct{}{}
-		return "not " + wrap(inner), nil
+	// The right operand of a default operator extends to the end of the
+	// expression, so x!1 + y means x!(1 + y): binary operators are only
+	// searched left of it, and its left operand binds tighter than any of them.
+	bang := defaultOperatorIndex(expr)
+	scope, tail := expr, ""
+	if bang >= 0 {
+		scope, tail = expr[:bang], expr[bang:]
 	}
 
-	if lhs, rhs, ok := splitTopLevelDefault(expr); ok {
-		left, err := m.mapMissingOperand(lhs)
-		if err != nil {
-			return "", err
-		}
-		right, err := m.mapExpr(rhs)
-		if err != nil {
-			return "", err
-		}
-		m.helpers["default"] = struct{}{}
-		return "default " + wrap(right) + " " + wrap(left), nil
-	}
-
-	if strings.HasSuffix(expr, "??") {
-		base := strings.TrimSpace(strings.TrimSuffix(expr, "??"))
-		mapped, err := m.mapMissingOperand(base)
-		if err != nil {
-			return "", err
-		}
-		m.helpers["exists"] = struct{}{}
-		return "exists " + wrap(mapped), nil
-	}
-
-	if parts := splitTopLevel(expr, "||"); len(parts) > 1 {
-		m.helpers["or"] = struct{}{}
+	if parts := splitTopLevel(scope, "||"); len(parts) > 1 {
+		parts[len(parts)-1] += tail
 		mapped := make([]string, 0, len(parts))
 		for _, p := range parts {
 			sub, err := m.mapExpr(p)
 			if err != nil {
 				return "", err
 			}
-			mapped = append(mapped, wrap(sub))
+			mapped = append(mapped, wrap(m.boolOperand(sub)))
 		}
 		return "or " + strings.Join(mapped, " "), nil
 	}
-	if parts := splitTopLevel(expr, "&&"); len(parts) > 1 {
-		m.helpers["and"] = struct{}{}
+	if parts := splitTopLevel(scope, "&&"); len(parts) > 1 {
+		parts[len(parts)-1] += tail
 		mapped := make([]string, 0, len(parts))
 		for _, p := range parts {
 			sub, err := m.mapExpr(p)
 			if err != nil {
 				return "", err
 			}
-			mapped = append(mapped, wrap(sub))
+			mapped = append(mapped, wrap(m.boolOperand(sub)))
 		}
 		return "and " + strings.Join(mapped, " "), nil
 	}
 
-	if lhs, rhs, op, ok := splitTopLevelCompare(expr); ok {
+	if lhs, rhs, op, ok := splitTopLevelCompare(scope); ok {
+		rhs += tail
+		left, err := m.mapExpr(lhs)
+		if err != nil {
+			return "", err
+		}
+		right, err := m.mapExpr(rhs)
+		if err != nil {
+			return "", err
+		}
+		helper := comparisonHelpers[op]
+		m.helpers[helper] = struct{}{}
+		return helper + " " + wrap(left) + " " + wrap(right), nil
+	}
+
+	if lhs, rhs, op, ok := splitTopLevelArithmetic(scope); ok {
+		rhs += tail
 		left, err := m.mapExpr(lhs)
 		if err != nil {
 			return "", err
@@ -810,22 +1229,59 @@ func (m *expressionMapper) mapExpr(expr string) (string, error) {
 		if err != nil {
 			return "", err
 		}
-		switch op {
-		case "==":
-			return "eq " + wrap(left) + " " + wrap(right), nil
-		case "!=":
-			return "ne " + wrap(left) + " " + wrap(right), nil
-		case "=":
-			return "eq " + wrap(left) + " " + wrap(right), nil
-		case ">":
-			return "gt " + wrap(left) + " " + wrap(right), nil
-		case "<":
-			return "lt " + wrap(left) + " " + wrap(right), nil
-		case ">=":
-			return "ge " + wrap(left) + " " + wrap(right), nil
-		case "<=":
-			return "le " + wrap(left) + " " + wrap(right), nil
+		helper := arithmeticHelpers[op]
+		m.helpers[helper] = struct{}{}
+		mapped := helper + " " + wrap(left) + " " + wrap(right)
+		if settings := m.settings.helperArg(); op == '+' && settings != "" {
+			m.helpers["ftlSettings"] = struct{}{}
+			mapped += " " + settings
 		}
+		return mapped, nil
+	}
+
+	if strings.HasPrefix(expr, "!") && !strings.HasPrefix(expr, "!=") {
+		inner, err := m.mapExpr(strings.TrimSpace(expr[1:]))
+		if err != nil {
+			return "", err
+		}
+		return "not " + wrap(m.boolOperand(inner)), nil
+	}
+
+	if strings.HasPrefix(expr, "-") && !isLiteral(expr) {
+		inner, err := m.mapExpr(strings.TrimSpace(expr[1:]))
+		if err != nil {
+			return "", err
+		}
+		m.helpers["negate"] = struct{}{}
+		return "negate " + wrap(inner), nil
+	}
+
+	if bang >= 0 {
+		left, err := m.mapMissingOperand(strings.TrimSpace(expr[:bang]))
+		if err != nil {
+			return "", err
+		}
+		rhs := strings.TrimSpace(expr[bang+1:])
+		if rhs == "" {
+			m.helpers["defaultEmpty"] = struct{}{}
+			return "defaultEmpty " + wrap(left), nil
+		}
+		right, err := m.mapExpr(rhs)
+		if err != nil {
+			return "", err
+		}
+		m.helpers["default"] = struct{}{}
+		return "default " + wrap(right) + " " + wrap(left), nil
+	}
+
+	if strings.HasSuffix(expr, "??") {
+		base := strings.TrimSpace(strings.TrimSuffix(expr, "??"))
+		mapped, err := m.mapMissingOperand(base)
+		if err != nil {
+			return "", err
+		}
+		m.helpers["exists"] = struct{}{}
+		return "exists " + wrap(mapped), nil
 	}
 
 	if strings.HasPrefix(expr, "(") && strings.HasSuffix(expr, ")") {
@@ -845,7 +1301,8 @@ func (m *expressionMapper) mapExpr(expr string) (string, error) {
 			return "", err
 		}
 		current := mapped
-		for _, call := range calls {
+		for i := 0; i < len(calls); i++ {
+			call := calls[i]
 			args := make([]string, 0)
 			rawArgs := strings.TrimSpace(call.args)
 			if rawArgs != "" {
@@ -892,41 +1349,184 @@ func (m *expressionMapper) mapExpr(expr string) (string, error) {
 			case "trim":
 				m.helpers["trim"] = struct{}{}
 				current = "trim " + wrap(current)
-			case "index":
+			case "index", "counter", "is_first", "has_next", "is_last",
+				"is_odd_item", "is_even_item", "item_parity", "item_parity_cap":
 				if len(args) != 0 {
-					return "", fmt.Errorf("?index expects no arguments")
+					return "", fmt.Errorf("?%s expects no arguments", call.name)
 				}
-				if !strings.HasPrefix(current, "$") || strings.ContainsAny(current[1:], ".[") {
-					return "", fmt.Errorf("?index is only supported on loop item variables")
+				item, err := m.loopItem(current, call.name)
+				if err != nil {
+					return "", err
 				}
-				indexVar := strings.TrimPrefix(current, "$") + "_index"
-				if _, ok := m.locals[indexVar]; !ok {
-					return "", fmt.Errorf("?index is only supported on loop item variables")
+				indexVar := "$" + item + "_index"
+				lengthVar := "$" + item + "_length"
+				switch call.name {
+				case "index":
+					current = indexVar
+				case "counter":
+					m.helpers["loopCounter"] = struct{}{}
+					current = "loopCounter " + indexVar
+				case "is_first":
+					current = "eq " + indexVar + " 0"
+				case "has_next":
+					m.helpers["loopHasNext"] = struct{}{}
+					m.loopLengths[item] = struct{}{}
+					current = "loopHasNext " + indexVar + " " + lengthVar
+				case "is_last":
+					m.helpers["loopHasNext"] = struct{}{}
+					m.loopLengths[item] = struct{}{}
+					current = "not (loopHasNext " + indexVar + " " + lengthVar + ")"
+				case "is_odd_item":
+					m.helpers["loopIsOdd"] = struct{}{}
+					current = "loopIsOdd " + indexVar
+				case "is_even_item":
+					m.helpers["loopIsOdd"] = struct{}{}
+					current = "not (loopIsOdd " + indexVar + ")"
+				case "item_parity":
+					m.helpers["loopParity"] = struct{}{}
+					current = "loopParity " + indexVar
+				case "item_parity_cap":
+					m.helpers["loopParityCap"] = struct{}{}
+					current = "loopParityCap " + indexVar
 				}
-				current = "$" + indexVar
+			case "item_cycle":
+				if len(args) == 0 {
+					return "", fmt.Errorf("?item_cycle expects at least one argument")
+				}
+				item, err := m.loopItem(current, call.name)
+				if err != nil {
+					return "", err
+				}
+				m.helpers["loopCycle"] = struct{}{}
+				current = "loopCycle $" + item + "_index " + joinWrapped(args)
 			case "number":
 				m.helpers["toNumber"] = struct{}{}
 				current = "toNumber " + wrap(current)
 			case "number_to_datetime":
 				m.helpers["numberToDatetime"] = struct{}{}
 				current = "numberToDatetime " + wrap(current)
+			case "date", "time", "datetime":
+				if len(args) > 1 {
+					return "", fmt.Errorf("?%s expects at most one argument", call.name)
+				}
+				helper := datetimeParseHelpers[call.name]
+				m.helpers[helper] = struct{}{}
+				current = helper + " " + wrap(current)
+				if len(args) == 1 {
+					current += " " + joinWrapped(args)
+				}
+				if settings := m.settings.helperArg(); settings != "" {
+					m.helpers["ftlSettings"] = struct{}{}
+					current += " " + settings
+				}
 			case "string":
-				m.helpers["toString"] = struct{}{}
-				if len(args) == 0 {
+				switch len(args) {
+				case 0:
+					m.helpers["toString"] = struct{}{}
 					current = "toString " + wrap(current)
-				} else {
+					if settings := m.settings.helperArg(); settings != "" {
+						m.helpers["ftlSettings"] = struct{}{}
+						current += " " + settings
+					}
+				case 1:
+					m.helpers["toString"] = struct{}{}
 					current = "toString " + wrap(current) + " " + joinWrapped(args)
+					if settings := m.settings.helperArg(); settings != "" {
+						m.helpers["ftlSettings"] = struct{}{}
+						current += " " + settings
+					}
+				case 2:
+					m.helpers["formatBoolean"] = struct{}{}
+					current = "formatBoolean " + wrap(current) + " " + joinWrapped(args)
+				default:
+					return "", fmt.Errorf("?string expects at most two arguments")
 				}
+			case "c":
+				if len(args) != 0 {
+					return "", fmt.Errorf("?c expects no arguments")
+				}
+				m.helpers["computerString"] = struct{}{}
+				current = "computerString " + wrap(current)
 			case "no_esc":
 				m.helpers["safeHTML"] = struct{}{}
 				current = "safeHTML " + wrap(current)
+			case "matches":
+				if len(args) < 1 || len(args) > 2 {
+					return "", fmt.Errorf("?matches expects one or two arguments")
+				}
+				if err := validateRegexArgs(call.name, call.args, true); err != nil {
+					return "", err
+				}
+				helper := "matches"
+				switch {
+				case i+1 < len(calls) && calls[i+1].name == "groups" && calls[i+1].args == "" && call.path == "":
+					helper = "groups"
+					i++
+					call = calls[i]
+				case sequence && i == len(calls)-1:
+					helper = "matchAll"
+				}
+				m.helpers[helper] = struct{}{}
+				current = helper + " " + wrap(current) + " " + joinWrapped(args)
+			case "groups":
+				if len(args) != 0 {
+					return "", fmt.Errorf("?groups expects no arguments")
+				}
+				if err := m.checkMatchResult(current, "a ?groups receiver"); err != nil {
+					return "", err
+				}
+				m.helpers["groups"] = struct{}{}
+				current = "groups " + wrap(current)
+			case "replace":
+				if len(args) < 2 || len(args) > 3 {
+					return "", fmt.Errorf("?replace expects two or three arguments")
+				}
+				if err := validateRegexArgs(call.name, call.args, false); err != nil {
+					return "", err
+				}
+				m.helpers["replace"] = struct{}{}
+				current = "replace " + wrap(current) + " " + joinWrapped(args)
+			case "split":
+				if len(args) < 1 || len(args) > 2 {
+					return "", fmt.Errorf("?split expects one or two arguments")
+				}
+				if err := validateRegexArgs(call.name, call.args, false); err != nil {
+					return "", err
+				}
+				m.helpers["split"] = struct{}{}
+				current = "split " + wrap(current) + " " + joinWrapped(args)
 			default:
 				return "", fmt.Errorf("unsupported builtin ?%s", call.name)
 			}
+			if call.path != "" {
+				if current, err = m.resolvePathRest(current, call.path, expr); err != nil {
+					return "", err
+				}
+			}
+			if m.lenient {
+				current = m.guardMissing(current)
+			}
 		}
 		return current, nil
 	}
 
+	if receiver, name, rawArgs, ok := parseMethodCall(expr); ok {
+		mappedReceiver, err := m.mapExpr(receiver)
+		if err != nil {
+			return "", err
+		}
+		mapped := []string{"callMethod", wrap(mappedReceiver), strconv.Quote(name)}
+		for _, rawArg := range rawArgs {
+			sub, err := m.mapExpr(rawArg)
+			if err != nil {
+				return "", err
+			}
+			mapped = append(mapped, wrap(sub))
+		}
+		m.helpers["callMethod"] = struct{}{}
+		return strings.Join(mapped, " "), nil
+	}
+
 	if name, rawArgs, ok := parseFunctionCall(expr); ok {
 		mappedArgs := make([]string, 0, len(rawArgs))
 		for _, rawArg := range rawArgs {
@@ -958,9 +1558,12 @@ func (m *expressionMapper) mapExpr(expr string) (string, error) {
 		return expr, nil
 	}
 
-	if hasTopLevelArithmetic(expr) {
-		return "", fmt.Errorf("unsupported arithmetic expression %q", expr)
+	if m.lenient {
+		if mapped, ok, err := m.mapSafeAccessIdentifierPath(expr, true); err != nil {
+			return "", err
+		} else if ok {
+			return mapped, nil
+		}
 	}
-
 	return m.resolveIdentifier(expr)
 }
@@ -11,41 +11,41 @@ func TestMapExprBuiltins(t *testing.T) {
 	m := newExpressionMapper(map[string]struct{}{})
 	got, err := m.mapExpr(`ad.price!''`)
 	require.NoError(t, err)
-	want := `default "" (safeAccess . "ad" "price")`
+	want := `default "" (safeAccessLast . "ad" "price")`
 	require.Equal(t, want, got)
 
 	helpers := m.helperList()
-	require.True(t, slices.Equal(helpers, []string{"default", "safeAccess"}))
+	require.True(t, slices.Equal(helpers, []string{"default", "safeAccessLast"}))
 }
 
 func TestMapExprFormatPriceCall(t *testing.T) {
 	m := newExpressionMapper(map[string]struct{}{})
 	got, err := m.mapExpr(`formatPrice(ad.price!'')`)
 	require.NoError(t, err)
-	require.Equal(t, `formatPrice (default "" (safeAccess . "ad" "price"))`, got)
+	require.Equal(t, `formatPrice (default "" (safeAccessLast . "ad" "price"))`, got)
 
 	helpers := m.helperList()
-	require.True(t, slices.Equal(helpers, []string{"default", "formatPrice", "safeAccess"}))
+	require.True(t, slices.Equal(helpers, []string{"default", "formatPrice", "safeAccessLast"}))
 }
 
 func TestMapExprExistsBuiltinUsesSafeAccess(t *testing.T) {
 	m := newExpressionMapper(map[string]struct{}{})
 	got, err := m.mapExpr(`user.name??`)
 	require.NoError(t, err)
-	require.Equal(t, `exists (safeAccess . "user" "name")`, got)
+	require.Equal(t, `exists (safeAccessLast . "user" "name")`, got)
 
 	helpers := m.helperList()
-	require.True(t, slices.Equal(helpers, []string{"exists", "safeAccess"}))
+	require.True(t, slices.Equal(helpers, []string{"exists", "safeAccessLast"}))
 }
 
 func TestMapExprExistsBuiltinUsesSafeAccessWithBracketPath(t *testing.T) {
 	m := newExpressionMapper(map[string]struct{}{})
 	got, err := m.mapExpr(`user.metadata["userType"]??`)
 	require.NoError(t, err)
-	require.Equal(t, `exists (safeAccess . "user" "metadata" "userType")`, got)
+	require.Equal(t, `exists (safeAccessLast . "user" "metadata" "userType")`, got)
 
 	helpers := m.helperList()
-	require.True(t, slices.Equal(helpers, []string{"exists", "safeAccess"}))
+	require.True(t, slices.Equal(helpers, []string{"exists", "safeAccessLast"}))
 }
 
 func TestMapExprDefaultBuiltinNonIdentifierPathUnchanged(t *testing.T) {
@@ -224,3 +224,260 @@ func TestMapExprBracketAccess(t *testing.T) {
 		})
 	}
 }
+
+func TestMapExprBooleanFormatting(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings settingsState
+		expr     string
+		want     string
+		helpers  []string
+	}{
+		{
+			name:    "two argument string",
+			expr:    `flag?string("Yes", "No")`,
+			want:    `formatBoolean .flag "Yes" "No"`,
+			helpers: []string{"formatBoolean"},
+		},
+		{
+			name:    "computer format",
+			expr:    `flag?c`,
+			want:    `computerString .flag`,
+			helpers: []string{"computerString"},
+		},
+		{
+			name:     "string uses boolean_format setting",
+			settings: settingsState{"boolean_format": "oui,non"},
+			expr:     `flag?string`,
+			want:     `toString .flag (ftlSettings "boolean_format" "oui,non")`,
+			helpers:  []string{"ftlSettings", "toString"},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			m := newExpressionMapper(map[string]struct{}{})
+			m.settings = tc.settings
+			got, err := m.mapExpr(tc.expr)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+			require.Equal(t, tc.helpers, m.helperList())
+		})
+	}
+
+	m := newExpressionMapper(map[string]struct{}{})
+	_, err := m.mapExpr(`flag?string("a", "b", "c")`)
+	require.ErrorContains(t, err, "?string expects at most two arguments")
+}
+
+func TestMapExprLoopBuiltins(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    string
+		lengths []string
+	}{
+		{expr: `item?counter`, want: `loopCounter $item_index`},
+		{expr: `item?is_first`, want: `eq $item_index 0`},
+		{expr: `item?has_next`, want: `loopHasNext $item_index $item_length`, lengths: []string{"item"}},
+		{expr: `item?is_last`, want: `not (loopHasNext $item_index $item_length)`, lengths: []string{"item"}},
+		{expr: `item?is_odd_item`, want: `loopIsOdd $item_index`},
+		{expr: `item?is_even_item`, want: `not (loopIsOdd $item_index)`},
+		{expr: `item?item_parity`, want: `loopParity $item_index`},
+		{expr: `item?item_parity_cap`, want: `loopParityCap $item_index`},
+		{expr: `item?item_cycle("a", "b")`, want: `loopCycle $item_index "a" "b"`},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.expr, func(t *testing.T) {
+			m := newExpressionMapper(map[string]struct{}{"item": {}, "item_index": {}})
+			got, err := m.mapExpr(tc.expr)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+
+			lengths := make([]string, 0, len(m.loopLengths))
+			for item := range m.loopLengths {
+				lengths = append(lengths, item)
+			}
+			require.ElementsMatch(t, tc.lengths, lengths)
+		})
+	}
+
+	m := newExpressionMapper(map[string]struct{}{})
+	_, err := m.mapExpr(`item?has_next`)
+	require.ErrorContains(t, err, "?has_next is only supported on loop item variables")
+
+	m = newExpressionMapper(map[string]struct{}{"item": {}, "item_index": {}})
+	_, err = m.mapExpr(`item?item_cycle`)
+	require.ErrorContains(t, err, "?item_cycle expects at least one argument")
+}
+
+func TestMapExprSpecialVariables(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings settingsState
+		expr     string
+		want     string
+	}{
+		{name: "now", expr: `.now`, want: `now`},
+		{name: "now with builtin", expr: `.now?string("yyyy")`, want: `toString now "yyyy"`},
+		{name: "default locale", expr: `.locale`, want: `"en_US"`},
+		{name: "locale setting", settings: settingsState{"locale": "fr_FR"}, expr: `.lang`, want: `"fr"`},
+		{name: "template name", expr: `.template_name`, want: `templateName "mail.ftl"`},
+		{name: "current template name", expr: `.current_template_name`, want: `templateName "mail.ftl"`},
+		{name: "vars data lookup", expr: `.vars["user"].name`, want: `.user.name`},
+		{name: "vars local lookup", expr: `.vars.total`, want: `$total`},
+		{name: "vars non identifier key", expr: `.vars["user-id"]`, want: `(safeAccess . "user-id")`},
+		{name: "data model", expr: `.data_model.user`, want: `$.user`},
+		{name: "data model root", expr: `.data_model`, want: `$`},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			m := newExpressionMapper(map[string]struct{}{"total": {}})
+			m.settings = tc.settings
+			m.templateName = "mail.ftl"
+			got, err := m.mapExpr(tc.expr)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+
+	for _, expr := range []string{`.vars[key]`, `.now.year`, `.unknown`} {
+		m := newExpressionMapper(map[string]struct{}{})
+		_, err := m.mapExpr(expr)
+		require.Error(t, err, expr)
+	}
+}
+
+func TestMapExprDateParsingBuiltins(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings settingsState
+		expr     string
+		want     string
+		helpers  []string
+	}{
+		{name: "date with pattern", expr: `order.date?date("yyyy-MM-dd")`, want: `parseDate .order.date "yyyy-MM-dd"`, helpers: []string{"parseDate"}},
+		{name: "time without pattern", expr: `slot?time`, want: `parseTime .slot`, helpers: []string{"parseTime"}},
+		{
+			name:     "datetime with settings",
+			settings: settingsState{"locale": "fr_FR"},
+			expr:     `sentAt?datetime("d MMMM yyyy HH:mm")?string("dd/MM")`,
+			want:     `toString (parseDatetime .sentAt ("d MMMM yyyy HH:mm") (ftlSettings "locale" "fr_FR")) "dd/MM" (ftlSettings "locale" "fr_FR")`,
+			helpers:  []string{"ftlSettings", "parseDatetime", "toString"},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			m := newExpressionMapper(map[string]struct{}{})
+			m.settings = tc.settings
+			got, err := m.mapExpr(tc.expr)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+			require.Equal(t, tc.helpers, m.helperList())
+		})
+	}
+
+	m := newExpressionMapper(map[string]struct{}{})
+	_, err := m.mapExpr(`d?date("a", "b")`)
+	require.ErrorContains(t, err, "?date expects at most one argument")
+}
+
+func TestMapExprArithmetic(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings settingsState
+		expr     string
+		want     string
+		helpers  []string
+	}{
+		{name: "product", expr: `item.price * item.qty`, want: `multiply .item.price .item.qty`, helpers: []string{"multiply"}},
+		{name: "left associative", expr: `a - b - c`, want: `subtract (subtract .a .b) .c`, helpers: []string{"subtract"}},
+		{name: "precedence", expr: `a + b * 2 % 3`, want: `add .a (modulo (multiply .b 2) 3)`, helpers: []string{"add", "modulo", "multiply"}},
+		{name: "parentheses", expr: `(a + b) / 2`, want: `divide ((add .a .b)) 2`, helpers: []string{"add", "divide"}},
+		{name: "unary minus", expr: `-total + -1`, want: `add (negate .total) -1`, helpers: []string{"add", "negate"}},
+		{name: "builtin operand", expr: `items?size - 1`, want: `subtract (len .items) 1`, helpers: []string{"subtract"}},
+		{name: "operators in strings", expr: `"a-b" + name`, want: `add "a-b" .name`, helpers: []string{"add"}},
+		{
+			name:     "concatenation with settings",
+			settings: settingsState{"locale": "de_DE"},
+			expr:     `"Total: " + total`,
+			want:     `add ("Total: ") .total (ftlSettings "locale" "de_DE")`,
+			helpers:  []string{"add", "ftlSettings"},
+		},
+		{name: "comparison binds looser", expr: `a + 1 > b`, want: `greaterThan (add .a 1) .b`, helpers: []string{"add", "greaterThan"}},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			m := newExpressionMapper(map[string]struct{}{})
+			m.settings = tc.settings
+			got, err := m.mapExpr(tc.expr)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+			require.Equal(t, tc.helpers, m.helperList())
+		})
+	}
+}
+
+func TestMapExprComparisonKeywords(t *testing.T) {
+	tests := map[string]string{
+		`a gt b`:              `greaterThan .a .b`,
+		`a gte 1.5`:           `greaterOrEqual .a 1.5`,
+		`user.age lt 18`:      `lessThan .user.age 18`,
+		`items?size lte 3`:    `lessOrEqual (len .items) 3`,
+		`a &gt; b`:            `greaterThan .a .b`,
+		`a &gt;= b`:           `greaterOrEqual .a .b`,
+		`a &lt; b`:            `lessThan .a .b`,
+		`a &lt;= b`:           `lessOrEqual .a .b`,
+		`(a > b)`:             `(greaterThan .a .b)`,
+		`weight gt height`:    `greaterThan .weight .height`,
+		`label == "a gt b"`:   `equals .label ("a gt b")`,
+		`total - 1 gte limit`: `greaterOrEqual (subtract .total 1) .limit`,
+	}
+	for expr, want := range tests {
+		m := newExpressionMapper(map[string]struct{}{})
+		got, err := m.mapExpr(expr)
+		require.NoError(t, err, expr)
+		require.Equal(t, want, got, expr)
+	}
+}
+
+func TestMapExprDefaultAndExistsOperands(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    string
+		helpers []string
+	}{
+		{`color!"x"`, `default "x" (safeAccess . "color")`, []string{"default", "safeAccess"}},
+		{`product.color!"x"`, `default "x" (safeAccessLast . "product" "color")`, []string{"default", "safeAccessLast"}},
+		{`(product.color)!"x"`, `default "x" (safeAccess . "product" "color")`, []string{"default", "safeAccess"}},
+		{`(product.color)??`, `exists (safeAccess . "product" "color")`, []string{"exists", "safeAccess"}},
+		{`(a?trim)!""`, `default "" (optional "trim" (safeAccess . "a"))`, []string{"default", "optional", "safeAccess", "trim"}},
+		{`(a.b?trim?index_of("x"))??`, `exists (optional "indexOf" (optional "trim" (safeAccess . "a" "b")) "x")`, []string{"exists", "indexOf", "optional", "safeAccess", "trim"}},
+		{`(order.items?size)!0`, `default 0 (optional "len" (safeAccess . "order" "items"))`, []string{"default", "optional", "safeAccess"}},
+		{`(price * qty)!0`, `default 0 (optional "multiply" (safeAccess . "price") (safeAccess . "qty"))`, []string{"default", "multiply", "optional", "safeAccess"}},
+		{`user.name!`, `defaultEmpty (safeAccessLast . "user" "name")`, []string{"defaultEmpty", "safeAccessLast"}},
+		{`(user.name)!`, `defaultEmpty (safeAccess . "user" "name")`, []string{"defaultEmpty", "safeAccess"}},
+		{`a + b!1 + c`, `add .a (default (add 1 .c) (safeAccess . "b"))`, []string{"add", "default", "safeAccess"}},
+		{`x!1 > 2`, `default (greaterThan 1 2) (safeAccess . "x")`, []string{"default", "greaterThan", "safeAccess"}},
+		{`ok && flag!false`, `and .ok (default false (safeAccess . "flag"))`, []string{"default", "safeAccess"}},
+		{`!flag!false`, `not (default false (safeAccess . "flag"))`, []string{"default", "safeAccess"}},
+		{`!a == b`, `equals (not .a) .b`, []string{"equals"}},
+		{`a != b`, `notEquals .a .b`, []string{"notEquals"}},
+		{`a?trim!"x"`, `default "x" (trim .a)`, []string{"default", "trim"}},
+	}
+	for _, tc := range tests {
+		m := newExpressionMapper(map[string]struct{}{})
+		got, err := m.mapExpr(tc.expr)
+		require.NoError(t, err, tc.expr)
+		require.Equal(t, tc.want, got, tc.expr)
+		require.Equal(t, tc.helpers, m.helperList(), tc.expr)
+	}
+}
@@ -7,7 +7,9 @@ import (
 	"github.com/cruffinoni/ftl2gotpl/internal/ast"
 )
 
-func detectFeatures(doc ast.Document, helpers []string) []string {
+// detectFeatures lists the FreeMarker constructs and helpers a template uses.
+// Comments only count when preserveComments keeps them in the output.
+func detectFeatures(doc ast.Document, helpers []string, preserveComments bool) []string {
 	set := map[string]struct{}{}
 
 	var walk func(nodes []ast.Node)
@@ -16,6 +18,10 @@ func detectFeatures(doc ast.Document, helpers []string) []string {
 			switch t := n.(type) {
 			case ast.TextNode:
 				set["node:text"] = struct{}{}
+			case ast.CommentNode:
+				if preserveComments && !isPragmaComment(t.Text) {
+					set["node:comment"] = struct{}{}
+				}
 			case ast.InterpolationNode:
 				set["node:interpolation"] = struct{}{}
 			case ast.IfNode:
@@ -35,11 +41,41 @@ func detectFeatures(doc ast.Document, helpers []string) []string {
 				set["directive:list"] = struct{}{}
 				walk(t.Body)
 			case ast.AssignNode:
-				if t.Local {
+				switch {
+				case t.Local:
 					set["directive:local"] = struct{}{}
-				} else {
+				case t.Global:
+					set["directive:global"] = struct{}{}
+				default:
 					set["directive:assign"] = struct{}{}
 				}
+				if t.Capture {
+					set["assign:capture"] = struct{}{}
+				}
+				walk(t.Body)
+			case ast.AttemptNode:
+				set["directive:attempt"] = struct{}{}
+				walk(t.Body)
+				walk(t.Recover)
+			case ast.EscapeNode:
+				set["directive:escape"] = struct{}{}
+				walk(t.Body)
+			case ast.NoEscapeNode:
+				set["directive:noescape"] = struct{}{}
+				walk(t.Body)
+			case ast.OutputFormatNode:
+				set["directive:outputformat"] = struct{}{}
+				walk(t.Body)
+			case ast.AutoEscNode:
+				if t.Enabled {
+					set["directive:autoesc"] = struct{}{}
+				} else {
+					set["directive:noautoesc"] = struct{}{}
+				}
+				walk(t.Body)
+			case ast.CompressNode:
+				set["directive:compress"] = struct{}{}
+				walk(t.Body)
 			case ast.SettingNode:
 				set["directive:setting"] = struct{}{}
 			case ast.FunctionNode:
@@ -2,6 +2,8 @@
 package convert
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"math"
@@ -9,6 +11,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/cruffinoni/ftl2gotpl/internal/decimal"
 )
 
 func isNilLike(v any) bool {
@@ -53,6 +57,16 @@ func normalizeFloatNumber(v float64) (any, error) {
 	return v, nil
 }
 
+// normalizeDecimal returns integral decimals within range as int64.
+func normalizeDecimal(d decimal.Decimal) any {
+	if i, ok := d.Int64(); ok {
+		return i
+	}
+	return d
+}
+
+// toNumber normalizes a numeric value to int64, float64 or decimal.Decimal.
+// Numeric strings and json.Number values are read exactly as decimals.
 func toNumber(v any) (any, error) {
 	v = indirect(v)
 	if v == nil {
@@ -90,6 +104,14 @@ func toNumber(v any) (any, error) {
 		return normalizeFloatNumber(float64(t))
 	case float64:
 		return normalizeFloatNumber(t)
+	case decimal.Decimal:
+		return normalizeDecimal(t), nil
+	case json.Number:
+		d, err := decimal.Parse(string(t))
+		if err != nil {
+			return nil, fmt.Errorf("invalid numeric string %q", string(t))
+		}
+		return normalizeDecimal(d), nil
 	case bool:
 		return nil, fmt.Errorf("boolean value is not numeric")
 	case string:
@@ -100,14 +122,8 @@ func toNumber(v any) (any, error) {
 		if i, err := strconv.ParseInt(raw, 10, 64); err == nil {
 			return i, nil
 		}
-		if u, err := strconv.ParseUint(raw, 10, 64); err == nil {
-			if u > uint64(^uint64(0)>>1) {
-				return nil, fmt.Errorf("integral number %s is out of int64 range", raw)
-			}
-			return int64(u), nil
-		}
-		if f, err := strconv.ParseFloat(raw, 64); err == nil {
-			return normalizeFloatNumber(f)
+		if d, err := decimal.Parse(raw); err == nil {
+			return normalizeDecimal(d), nil
 		}
 		return nil, fmt.Errorf("invalid numeric string %q", raw)
 	default:
@@ -165,6 +181,16 @@ func toInt(v any) (int, error) {
 			return 0, fmt.Errorf("integer %v is out of int64 range", t)
 		}
 		i64 = int64(t)
+	case decimal.Decimal, json.Number:
+		n, err := toNumber(t)
+		if err != nil {
+			return 0, err
+		}
+		i, ok := n.(int64)
+		if !ok {
+			return 0, fmt.Errorf("integer value required")
+		}
+		i64 = i
 	default:
 		return 0, fmt.Errorf("integer type required, got %T", v)
 	}
@@ -179,6 +205,9 @@ func toInt(v any) (int, error) {
 
 func strictString(v any, name string) (string, error) {
 	v = indirect(v)
+	if _, ok := v.(emptyValue); ok {
+		return "", nil
+	}
 	s, ok := v.(string)
 	if !ok {
 		return "", fmt.Errorf("%s must be a string, got %T", name, v)
@@ -186,249 +215,358 @@ func strictString(v any, name string) (string, error) {
 	return s, nil
 }
 
-type numericFormatPattern struct {
-	prefix       string
-	suffix       string
-	minIntDigits int
-	minFrac      int
-	maxFrac      int
-	useGrouping  bool
-}
-
-func parseNumericFormatPattern(pattern string) (numericFormatPattern, error) {
-	first := strings.IndexAny(pattern, "0#")
-	last := strings.LastIndexAny(pattern, "0#")
-	if first < 0 || last < 0 || first > last {
-		return numericFormatPattern{}, fmt.Errorf("unsupported format pattern %q", pattern)
+// formatValueWithPattern formats a number or datetime with an explicit
+// pattern using the symbols and names of the given locale; datetimes are
+// shown in zone.
+func formatValueWithPattern(value any, pattern string, loc *localeData, zone *time.Location) (string, error) {
+	if pattern == "" {
+		return "", fmt.Errorf("format pattern cannot be empty")
 	}
 
-	core := pattern[first : last+1]
-	for _, r := range core {
-		switch r {
-		case '0', '#', ',', '.':
-		default:
-			return numericFormatPattern{}, fmt.Errorf("unsupported numeric format pattern %q", pattern)
-		}
+	if t, kind, ok := asDatetime(value); ok {
+		return formatDatetime(t.In(zone), pattern, kind, loc)
 	}
 
-	if strings.Count(core, ".") > 1 {
-		return numericFormatPattern{}, fmt.Errorf("unsupported numeric format pattern %q", pattern)
+	numericFormat, err := parseDecimalFormat(pattern, loc)
+	if err != nil {
+		return "", err
 	}
-
-	parts := strings.Split(core, ".")
-	intPart := parts[0]
-	if intPart == "" {
-		return numericFormatPattern{}, fmt.Errorf("unsupported numeric format pattern %q", pattern)
+	n, err := toNumber(value)
+	if err != nil {
+		return "", fmt.Errorf("numeric format requires a numeric value: %w", err)
 	}
+	return numericFormat.format(n)
+}
 
-	if strings.Contains(intPart, ",") {
-		groups := strings.Split(intPart, ",")
-		if len(groups) < 2 {
-			return numericFormatPattern{}, fmt.Errorf("unsupported numeric format pattern %q", pattern)
+// formatSettings carries FreeMarker formatting settings into helpers.
+type formatSettings struct {
+	booleanFormat  string
+	numberFormat   string
+	dateFormat     string
+	timeFormat     string
+	datetimeFormat string
+	locale         string
+	timeZone       string
+	sqlTimeZone    string
+}
+
+// newFormatSettings builds formatting settings from key/value argument pairs.
+func newFormatSettings(pairs ...any) (*formatSettings, error) {
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("ftlSettings expects key/value pairs")
+	}
+	settings := &formatSettings{}
+	for i := 0; i < len(pairs); i += 2 {
+		key, err := strictString(pairs[i], "ftlSettings key")
+		if err != nil {
+			return nil, err
 		}
-		for i, group := range groups {
-			if group == "" {
-				return numericFormatPattern{}, fmt.Errorf("unsupported numeric format pattern %q", pattern)
-			}
-			if i > 0 && len(group) != 3 {
-				return numericFormatPattern{}, fmt.Errorf("unsupported numeric format pattern %q", pattern)
-			}
+		value, err := strictString(pairs[i+1], "ftlSettings value")
+		if err != nil {
+			return nil, err
 		}
-	}
-
-	fractionPart := ""
-	if len(parts) == 2 {
-		fractionPart = parts[1]
-		if fractionPart == "" || strings.Contains(fractionPart, ",") {
-			return numericFormatPattern{}, fmt.Errorf("unsupported numeric format pattern %q", pattern)
+		switch key {
+		case "boolean_format":
+			settings.booleanFormat = value
+		case "number_format":
+			settings.numberFormat = value
+		case "date_format":
+			settings.dateFormat = value
+		case "time_format":
+			settings.timeFormat = value
+		case "datetime_format":
+			settings.datetimeFormat = value
+		case "locale":
+			settings.locale = value
+		case "time_zone":
+			settings.timeZone = value
+		case "sql_date_and_time_time_zone":
+			settings.sqlTimeZone = value
+		default:
+			return nil, fmt.Errorf("unsupported ftlSettings key %q", key)
 		}
 	}
-
-	return numericFormatPattern{
-		prefix:       pattern[:first],
-		suffix:       pattern[last+1:],
-		minIntDigits: strings.Count(intPart, "0"),
-		minFrac:      strings.Count(fractionPart, "0"),
-		maxFrac:      len(fractionPart),
-		useGrouping:  strings.Contains(intPart, ","),
-	}, nil
+	return settings, nil
 }
 
-func groupThousands(s string) string {
-	if len(s) <= 3 {
-		return s
+// datetimeFormatFor returns the format setting for a datetime value type,
+// defaulting to the locale's medium style.
+func (s *formatSettings) datetimeFormatFor(kind datetimeKind) string {
+	format := s.datetimeFormat
+	switch kind {
+	case kindDate:
+		format = s.dateFormat
+	case kindTime:
+		format = s.timeFormat
 	}
-	head := len(s) % 3
-	if head == 0 {
-		head = 3
+	if format == "" {
+		return "medium"
 	}
-	var b strings.Builder
-	b.WriteString(s[:head])
-	for i := head; i < len(s); i += 3 {
-		b.WriteByte(',')
-		b.WriteString(s[i : i+3])
+	return format
+}
+
+// zoneForKind returns the zone used for a datetime value type: date-only and
+// time-only values use sql_date_and_time_time_zone when it is set, the way
+// FreeMarker treats java.sql.Date and java.sql.Time, and everything else
+// uses time_zone.
+func (s *formatSettings) zoneForKind(kind datetimeKind) (*time.Location, error) {
+	if kind != kindDatetime && s.sqlTimeZone != "" {
+		return resolveTimeZone(s.sqlTimeZone)
 	}
-	return b.String()
+	return resolveTimeZone(s.timeZone)
 }
 
-func formatNumericWithPattern(v float64, pattern numericFormatPattern) string {
-	negative := v < 0
-	if negative {
-		v = -v
+// zoneFor returns the zone for formatting v; non-datetime values get UTC.
+func (s *formatSettings) zoneFor(v any) (*time.Location, error) {
+	_, kind, ok := asDatetime(v)
+	if !ok {
+		return time.UTC, nil
 	}
+	return s.zoneForKind(kind)
+}
 
-	var rounded float64
-	if pattern.maxFrac == 0 {
-		rounded = math.Round(v)
-	} else {
-		pow := math.Pow10(pattern.maxFrac)
-		rounded = math.Round(v*pow) / pow
+// parseDatetimeValue implements ?date, ?time and ?datetime: strings are
+// parsed with the given format (or the matching format setting), while
+// datetimes are retagged with the requested type.
+func parseDatetimeValue(v any, kind datetimeKind, now time.Time, args ...any) (datetimeValue, error) {
+	args, settings := splitSettingsArg(args)
+	if settings == nil {
+		settings = &formatSettings{}
 	}
-	if rounded == 0 {
-		negative = false
+	if len(args) > 1 {
+		return datetimeValue{}, fmt.Errorf("date parsing expects at most one format argument")
 	}
-
-	numberText := strconv.FormatFloat(rounded, 'f', pattern.maxFrac, 64)
-	intPart := numberText
-	fracPart := ""
-	if dot := strings.IndexByte(numberText, '.'); dot >= 0 {
-		intPart = numberText[:dot]
-		fracPart = numberText[dot+1:]
+	if t, _, ok := asDatetime(v); ok {
+		return datetimeValue{at: t, kind: kind}, nil
 	}
 
-	for len(intPart) < pattern.minIntDigits {
-		intPart = "0" + intPart
+	text, err := strictString(v, "date parsing value")
+	if err != nil {
+		return datetimeValue{}, err
+	}
+	format := settings.datetimeFormatFor(kind)
+	if len(args) == 1 {
+		format, err = strictString(args[0], "date parsing format")
+		if err != nil {
+			return datetimeValue{}, err
+		}
 	}
-	if pattern.useGrouping {
-		intPart = groupThousands(intPart)
+	loc, err := lookupLocale(settings.locale)
+	if err != nil {
+		return datetimeValue{}, err
 	}
-	for len(fracPart) > pattern.minFrac && strings.HasSuffix(fracPart, "0") {
-		fracPart = fracPart[:len(fracPart)-1]
+	zone, err := settings.zoneForKind(kind)
+	if err != nil {
+		return datetimeValue{}, err
 	}
+	t, err := parseDatetime(text, format, kind, loc, zone, now)
+	if err != nil {
+		return datetimeValue{}, err
+	}
+	return datetimeValue{at: t, kind: kind}, nil
+}
 
-	var b strings.Builder
-	if negative {
-		b.WriteByte('-')
+// resolveNumberFormat maps a number_format value to a pattern in loc,
+// reporting true when FreeMarker's computer format applies instead.
+func resolveNumberFormat(format string, loc *localeData) (decimalFormat, bool, error) {
+	switch format {
+	case "c", "computer":
+		return decimalFormat{}, true, nil
+	case "number", "":
+		format = "#,##0.###"
+	case "currency":
+		format = loc.currencyPattern
+	case "percent":
+		format = loc.percentPattern
 	}
-	b.WriteString(pattern.prefix)
-	b.WriteString(intPart)
-	if fracPart != "" {
-		b.WriteByte('.')
-		b.WriteString(fracPart)
+	pattern, err := parseDecimalFormat(format, loc)
+	if err != nil {
+		return decimalFormat{}, false, err
 	}
-	b.WriteString(pattern.suffix)
-	return b.String()
+	return pattern, false, nil
 }
 
-func parseDatetimeLayout(pattern string) (string, error) {
-	if pattern == "" {
-		return "", fmt.Errorf("unsupported format pattern %q", pattern)
+// formatNumberValue renders a normalized number with a number_format value.
+func formatNumberValue(n any, format string, loc *localeData) (string, error) {
+	pattern, computer, err := resolveNumberFormat(format, loc)
+	if err != nil {
+		return "", err
+	}
+	if computer {
+		return computerString(n)
 	}
+	return pattern.format(n)
+}
 
-	var b strings.Builder
-	hasToken := false
-
-	for i := 0; i < len(pattern); {
-		if pattern[i] == '\'' {
-			i++
-			closed := false
-			for i < len(pattern) {
-				if pattern[i] == '\'' {
-					if i+1 < len(pattern) && pattern[i+1] == '\'' {
-						b.WriteByte('\'')
-						i += 2
-						continue
-					}
-					i++
-					closed = true
-					break
-				}
-				b.WriteByte(pattern[i])
-				i++
-			}
-			if !closed {
-				return "", fmt.Errorf("unterminated quoted literal in pattern %q", pattern)
-			}
-			continue
+// formatWithSettings renders a value the way FreeMarker prints it under the
+// given settings; nil settings select FreeMarker's defaults. Numbers always go
+// through number_format and datetimes through the date_format, time_format or
+// datetime_format matching their type (the locale's medium style when unset),
+// while other values are returned unchanged.
+func formatWithSettings(v any, settings *formatSettings) (any, error) {
+	v = indirect(v)
+	if v == nil {
+		return v, nil
+	}
+	if settings == nil {
+		settings = &formatSettings{}
+	}
+	loc, err := lookupLocale(settings.locale)
+	if err != nil {
+		return nil, err
+	}
+	if _, kind, ok := asDatetime(v); ok {
+		zone, err := settings.zoneForKind(kind)
+		if err != nil {
+			return nil, err
 		}
-
-		switch {
-		case strings.HasPrefix(pattern[i:], "yyyy"):
-			b.WriteString("2006")
-			i += 4
-			hasToken = true
-		case strings.HasPrefix(pattern[i:], "MM"):
-			b.WriteString("01")
-			i += 2
-			hasToken = true
-		case strings.HasPrefix(pattern[i:], "dd"):
-			b.WriteString("02")
-			i += 2
-			hasToken = true
-		case strings.HasPrefix(pattern[i:], "HH"):
-			b.WriteString("15")
-			i += 2
-			hasToken = true
-		case strings.HasPrefix(pattern[i:], "mm"):
-			b.WriteString("04")
-			i += 2
-			hasToken = true
-		case strings.HasPrefix(pattern[i:], "ss"):
-			b.WriteString("05")
-			i += 2
-			hasToken = true
-		default:
-			ch := pattern[i]
-			if (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') {
-				return "", fmt.Errorf("unsupported datetime token in pattern %q", pattern)
-			}
-			b.WriteByte(ch)
-			i++
+		return formatValueWithPattern(v, settings.datetimeFormatFor(kind), loc, zone)
+	}
+	switch t := v.(type) {
+	case bool:
+		// FreeMarker only prints booleans once boolean_format is set.
+		if settings.booleanFormat == "" {
+			return nil, fmt.Errorf("cannot print a boolean without boolean_format; use ?c or ?string(\"yes\", \"no\")")
 		}
+		return formatBooleanValue(t, settings.booleanFormat)
+	case string:
+		return t, nil
 	}
-
-	if !hasToken {
-		return "", fmt.Errorf("unsupported format pattern %q", pattern)
+	n, err := toNumber(v)
+	if err != nil {
+		return v, nil
 	}
-	return b.String(), nil
+	return formatNumberValue(n, settings.numberFormat, loc)
 }
 
-func formatValueWithPattern(value any, pattern string) (string, error) {
-	if pattern == "" {
-		return "", fmt.Errorf("format pattern cannot be empty")
+// splitSettingsArg separates a trailing *formatSettings argument from helper args.
+func splitSettingsArg(args []any) ([]any, *formatSettings) {
+	if len(args) == 0 {
+		return args, nil
 	}
+	if settings, ok := args[len(args)-1].(*formatSettings); ok {
+		return args[:len(args)-1], settings
+	}
+	return args, nil
+}
 
-	if strings.ContainsAny(pattern, "0#") {
-		numericPattern, err := parseNumericFormatPattern(pattern)
-		if err != nil {
-			return "", err
-		}
-		n, err := toNumber(value)
-		if err != nil {
-			return "", fmt.Errorf("numeric format requires a numeric value: %w", err)
-		}
+// formatBooleanValue renders a boolean using FreeMarker's boolean_format rules.
+func formatBooleanValue(b bool, booleanFormat string) (string, error) {
+	if booleanFormat == "" || booleanFormat == "c" {
+		return strconv.FormatBool(b), nil
+	}
+	parts := strings.Split(booleanFormat, ",")
+	if len(parts) != 2 {
+		return "", fmt.Errorf("boolean_format must be \"true_text,false_text\", got %q", booleanFormat)
+	}
+	if b {
+		return parts[0], nil
+	}
+	return parts[1], nil
+}
 
-		switch t := n.(type) {
-		case int64:
-			return formatNumericWithPattern(float64(t), numericPattern), nil
-		case float64:
-			return formatNumericWithPattern(t, numericPattern), nil
-		default:
-			return "", fmt.Errorf("numeric format requires a numeric value")
-		}
+// formatBoolean implements the two-argument ?string built-in on booleans.
+func formatBoolean(v any, trueText any, falseText any) (string, error) {
+	b, ok := indirect(v).(bool)
+	if !ok {
+		return "", fmt.Errorf("?string with two arguments requires a boolean value, got %T", indirect(v))
+	}
+	whenTrue, err := strictString(trueText, "formatBoolean true text")
+	if err != nil {
+		return "", err
+	}
+	whenFalse, err := strictString(falseText, "formatBoolean false text")
+	if err != nil {
+		return "", err
 	}
+	if b {
+		return whenTrue, nil
+	}
+	return whenFalse, nil
+}
 
-	layout, err := parseDatetimeLayout(pattern)
+// formatNumeric implements #{expr; mXMY}: the number is printed in the
+// computer-language format, without grouping, keeping between minFrac and
+// maxFrac fraction digits.
+func formatNumeric(v any, minFrac any, maxFrac any) (string, error) {
+	if !isNumeric(v) {
+		return "", fmt.Errorf("#{...} requires a numeric value, got %s", valueTypeName(v))
+	}
+	n, err := toNumber(v)
 	if err != nil {
 		return "", err
 	}
+	lo, err := toInt(minFrac)
+	if err != nil {
+		return "", fmt.Errorf("formatNumeric minimum fraction digits: %w", err)
+	}
+	hi, err := toInt(maxFrac)
+	if err != nil {
+		return "", fmt.Errorf("formatNumeric maximum fraction digits: %w", err)
+	}
+	if lo < 0 || lo > hi || hi > maxNumericFractionDigits {
+		return "", fmt.Errorf("formatNumeric fraction digits must satisfy 0 <= min <= max <= %d", maxNumericFractionDigits)
+	}
+	pattern := "0"
+	if hi > 0 {
+		pattern += "." + strings.Repeat("0", lo) + strings.Repeat("#", hi-lo)
+	}
+	f, err := parseDecimalFormat(pattern, locales[defaultLocale])
+	if err != nil {
+		return "", err
+	}
+	return f.format(n)
+}
 
-	value = indirect(value)
-	t, ok := value.(time.Time)
-	if !ok {
-		return "", fmt.Errorf("datetime format requires time.Time value, got %T", value)
+// ErrNonBooleanCondition is wrapped by asBool when a condition is not a
+// boolean, so render checks can report it distinctly.
+var ErrNonBooleanCondition = errors.New("non-boolean condition")
+
+// ErrStopped is wrapped by the stop helper, which implements <#stop>, so
+// callers can tell a deliberate stop from a rendering failure.
+var ErrStopped = errors.New("template processing stopped")
+
+// stopTemplate implements <#stop> by failing the execution, with the
+// optional reason in the error.
+func stopTemplate(reason ...any) (string, error) {
+	if len(reason) == 0 {
+		return "", ErrStopped
+	}
+	if len(reason) > 1 {
+		return "", fmt.Errorf("stop expects at most one reason")
+	}
+	return "", fmt.Errorf("%w: %v", ErrStopped, reason[0])
+}
+
+// asBool implements FreeMarker's strict condition semantics: only booleans
+// are accepted, where Go would treat empty strings, zero and nil as false.
+func asBool(v any) (bool, error) {
+	if b, ok := indirect(v).(bool); ok {
+		return b, nil
+	}
+	return false, fmt.Errorf("%w: expected a boolean, got %s", ErrNonBooleanCondition, valueTypeName(v))
+}
+
+// computerString implements ?c for booleans and numbers.
+func computerString(v any) (string, error) {
+	v = indirect(v)
+	if b, ok := v.(bool); ok {
+		return strconv.FormatBool(b), nil
+	}
+	n, err := toNumber(v)
+	if err != nil {
+		return "", fmt.Errorf("?c requires a boolean or numeric value: %w", err)
+	}
+	switch t := n.(type) {
+	case int64:
+		return strconv.FormatInt(t, 10), nil
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), nil
+	case decimal.Decimal:
+		return t.StripTrailingZeros().String(), nil
+	default:
+		return "", fmt.Errorf("?c requires a boolean or numeric value")
 	}
-	return t.UTC().Format(layout), nil
 }
 
 func numberToDatetime(v any) (time.Time, error) {
@@ -443,6 +581,124 @@ func numberToDatetime(v any) (time.Time, error) {
 	return time.Unix(0, ms*int64(time.Millisecond)).UTC(), nil
 }
 
+// loopCounter converts a zero-based loop index into FreeMarker's ?counter.
+func loopCounter(index any) (int, error) {
+	i, err := toInt(index)
+	if err != nil {
+		return 0, fmt.Errorf("loop index must be an integer: %w", err)
+	}
+	return i + 1, nil
+}
+
+// loopHasNext reports whether a loop index is followed by another item.
+func loopHasNext(index any, length any) (bool, error) {
+	i, err := toInt(index)
+	if err != nil {
+		return false, fmt.Errorf("loop index must be an integer: %w", err)
+	}
+	n, err := toInt(length)
+	if err != nil {
+		return false, fmt.Errorf("loop length must be an integer: %w", err)
+	}
+	return i+1 < n, nil
+}
+
+// loopIsOdd reports whether the 1-based loop counter is odd.
+func loopIsOdd(index any) (bool, error) {
+	i, err := toInt(index)
+	if err != nil {
+		return false, fmt.Errorf("loop index must be an integer: %w", err)
+	}
+	return i%2 == 0, nil
+}
+
+// loopCycle picks the cycle value for a loop index, as ?item_cycle does.
+func loopCycle(index any, values ...any) (any, error) {
+	if len(values) == 0 {
+		return nil, fmt.Errorf("item_cycle expects at least one value")
+	}
+	i, err := toInt(index)
+	if err != nil {
+		return nil, fmt.Errorf("loop index must be an integer: %w", err)
+	}
+	return values[i%len(values)], nil
+}
+
+// safeAccess walks path from root through maps, struct fields and sequence
+// indexes, returning nil as soon as any step is missing.
+func safeAccess(root any, path ...any) any {
+	current := root
+	for _, segment := range path {
+		current = indirect(current)
+		if current == nil {
+			return nil
+		}
+
+		rv := reflect.ValueOf(current)
+		switch rv.Kind() {
+		case reflect.Map:
+			key, ok := mapKeyFrom(segment, rv.Type().Key())
+			if !ok {
+				return nil
+			}
+			next := rv.MapIndex(key)
+			if !next.IsValid() {
+				return nil
+			}
+			current = next.Interface()
+		case reflect.Struct:
+			fieldName, ok := indirect(segment).(string)
+			if !ok || fieldName == "" {
+				return nil
+			}
+			field := rv.FieldByName(fieldName)
+			if !field.IsValid() || !field.CanInterface() {
+				return nil
+			}
+			current = field.Interface()
+		case reflect.Slice, reflect.Array:
+			idx, err := toInt(segment)
+			if err != nil || idx < 0 || idx >= rv.Len() {
+				return nil
+			}
+			current = rv.Index(idx).Interface()
+		default:
+			return nil
+		}
+	}
+	return current
+}
+
+// safeAccessLast walks path like safeAccess but only lets the last step be
+// missing, which is FreeMarker's rule for an unparenthesized a.b.c!default or
+// a.b.c??; a missing earlier step is an error.
+func safeAccessLast(root any, path ...any) (any, error) {
+	if len(path) == 0 {
+		return root, nil
+	}
+	parent := safeAccess(root, path[:len(path)-1]...)
+	if isNilLike(parent) {
+		return nil, fmt.Errorf("%s is missing; only the last step before ! or ?? may be missing", describePath(path[:len(path)-1]))
+	}
+	return safeAccess(parent, path[len(path)-1]), nil
+}
+
+// describePath renders path segments as a FreeMarker-like path for errors.
+func describePath(path []any) string {
+	var b strings.Builder
+	for _, segment := range path {
+		if name, ok := indirect(segment).(string); ok {
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(name)
+			continue
+		}
+		fmt.Fprintf(&b, "[%v]", indirect(segment))
+	}
+	return b.String()
+}
+
 func mapKeyFrom(v any, keyType reflect.Type) (reflect.Value, bool) {
 	v = indirect(v)
 	if v == nil {
@@ -464,12 +720,32 @@ func mapKeyFrom(v any, keyType reflect.Type) (reflect.Value, bool) {
 	return reflect.Value{}, false
 }
 
+// FuncMapOptions configures runtime hooks of the converted template helpers.
+type FuncMapOptions struct {
+	// Now returns the current time for .now; it defaults to time.Now.
+	Now func() time.Time
+	// Methods adds or replaces entries of the method table callMethod uses
+	// for Java method calls the data does not implement, such as size() on
+	// a JSON array.
+	Methods map[string]MethodFunc
+}
+
 // StubFuncMap returns helpers used by converted templates.
 //
 // The helpers are hardened for mixed runtime data (JSON-decoded maps, slices,
 // numbers and nil values) and try to stay close to expected FreeMarker behavior
 // for the built-ins currently supported by this converter.
 func StubFuncMap() template.FuncMap {
+	return NewFuncMap(FuncMapOptions{})
+}
+
+// NewFuncMap returns the StubFuncMap helpers configured with opts.
+func NewFuncMap(opts FuncMapOptions) template.FuncMap {
+	now := opts.Now
+	if now == nil {
+		now = time.Now
+	}
+
 	appendEuro := func(raw string) string {
 		part := strings.TrimSpace(raw)
 		part = strings.TrimSuffix(part, "€")
@@ -480,7 +756,7 @@ func StubFuncMap() template.FuncMap {
 		return part + " €"
 	}
 
-	return template.FuncMap{
+	funcs := template.FuncMap{
 		"hasContent": func(v any) bool {
 			v = indirect(v)
 			if v == nil {
@@ -491,7 +767,7 @@ func StubFuncMap() template.FuncMap {
 				return len(t) > 0
 			case bool:
 				return true
-			case time.Time:
+			case time.Time, datetimeValue, decimal.Decimal:
 				return true
 			}
 
@@ -590,8 +866,82 @@ func StubFuncMap() template.FuncMap {
 			return strings.TrimSpace(s), nil
 		},
 		"toNumber":         toNumber,
+		"add":              addValues,
+		"subtract":         subtractValues,
+		"multiply":         multiplyValues,
+		"divide":           divideValues,
+		"modulo":           moduloValues,
+		"negate":           negateValue,
+		"equals":           comparisonHelper("==", func(order int) bool { return order == 0 }),
+		"notEquals":        comparisonHelper("!=", func(order int) bool { return order != 0 }),
+		"lessThan":         comparisonHelper("<", func(order int) bool { return order < 0 }),
+		"lessOrEqual":      comparisonHelper("<=", func(order int) bool { return order <= 0 }),
+		"greaterThan":      comparisonHelper(">", func(order int) bool { return order > 0 }),
+		"greaterOrEqual":   comparisonHelper(">=", func(order int) bool { return order >= 0 }),
 		"numberToDatetime": numberToDatetime,
+		"ftlSettings":      newFormatSettings,
+		"interpolate": func(v any, settings ...*formatSettings) (any, error) {
+			if len(settings) > 1 {
+				return nil, fmt.Errorf("interpolate expects at most one settings argument")
+			}
+			if len(settings) == 0 {
+				return formatWithSettings(v, nil)
+			}
+			return formatWithSettings(v, settings[0])
+		},
+		"parseDate": func(v any, args ...any) (datetimeValue, error) {
+			return parseDatetimeValue(v, kindDate, now(), args...)
+		},
+		"parseTime": func(v any, args ...any) (datetimeValue, error) {
+			return parseDatetimeValue(v, kindTime, now(), args...)
+		},
+		"parseDatetime": func(v any, args ...any) (datetimeValue, error) {
+			return parseDatetimeValue(v, kindDatetime, now(), args...)
+		},
+		"now": func() time.Time {
+			return now()
+		},
+		"loopCounter": loopCounter,
+		"loopHasNext": loopHasNext,
+		"loopIsOdd":   loopIsOdd,
+		"loopCycle":   loopCycle,
+		"loopParity": func(index any) (string, error) {
+			odd, err := loopIsOdd(index)
+			if err != nil {
+				return "", err
+			}
+			if odd {
+				return "odd", nil
+			}
+			return "even", nil
+		},
+		"loopParityCap": func(index any) (string, error) {
+			odd, err := loopIsOdd(index)
+			if err != nil {
+				return "", err
+			}
+			if odd {
+				return "Odd", nil
+			}
+			return "Even", nil
+		},
+		"formatBoolean":  formatBoolean,
+		"computerString": computerString,
+		"asBool":         asBool,
+		"formatNumeric":  formatNumeric,
+		"callMethod":     newMethodCaller(opts.Methods).call,
+		"capture":        unboundCapture,
+		"tryTemplate":    unboundTryTemplate,
+		"compress":       compressOutput,
+		"stop":           stopTemplate,
+		"captureScope":   newCaptureScope,
+		"matches":        regexMatches,
+		"matchAll":       regexMatchAll,
+		"groups":         regexGroups,
+		"replace":        regexReplace,
+		"split":          regexSplit,
 		"toString": func(args ...any) (string, error) {
+			args, settings := splitSettingsArg(args)
 			if len(args) == 0 {
 				return "", nil
 			}
@@ -603,11 +953,23 @@ func StubFuncMap() template.FuncMap {
 				if value == nil {
 					return "", nil
 				}
-				return fmt.Sprint(value), nil
+				// A bare ?string on a boolean uses the "true,false" default.
+				if b, ok := value.(bool); ok {
+					booleanFormat := ""
+					if settings != nil {
+						booleanFormat = settings.booleanFormat
+					}
+					return formatBooleanValue(b, booleanFormat)
+				}
+				formatted, err := formatWithSettings(value, settings)
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprint(formatted), nil
 			}
 
-			if len(formatArgs) > 2 {
-				return "", fmt.Errorf("toString expects at most two format arguments")
+			if len(formatArgs) > 3 {
+				return "", fmt.Errorf("toString expects at most three format arguments")
 			}
 
 			pattern, ok := indirect(formatArgs[0]).(string)
@@ -618,63 +980,47 @@ func StubFuncMap() template.FuncMap {
 				return "", fmt.Errorf("toString format argument 1 cannot be empty")
 			}
 
-			if len(formatArgs) == 2 {
-				locale, ok := indirect(formatArgs[1]).(string)
+			if settings == nil {
+				settings = &formatSettings{}
+			}
+			localeName := settings.locale
+			zoneName := ""
+			for i, rawArg := range formatArgs[1:] {
+				arg, ok := indirect(rawArg).(string)
 				if !ok {
-					return "", fmt.Errorf("toString format argument 2 must be a string")
+					return "", fmt.Errorf("toString format argument %d must be a string", i+2)
 				}
-				if locale == "" {
-					return "", fmt.Errorf("toString format argument 2 cannot be empty")
+				if arg == "" {
+					return "", fmt.Errorf("toString format argument %d cannot be empty", i+2)
 				}
-				if locale != "UTC" && locale != "en_US" {
-					return "", fmt.Errorf("unsupported toString locale/timezone %q", locale)
+				switch {
+				case isKnownLocale(arg):
+					localeName = arg
+				case isKnownTimeZone(arg):
+					zoneName = arg
+				default:
+					return "", fmt.Errorf("unsupported toString locale/timezone %q", arg)
 				}
 			}
-
-			return formatValueWithPattern(value, pattern)
-		},
-		"safeAccess": func(root any, path ...any) any {
-			current := root
-			for _, segment := range path {
-				current = indirect(current)
-				if current == nil {
-					return nil
-				}
-
-				rv := reflect.ValueOf(current)
-				switch rv.Kind() {
-				case reflect.Map:
-					key, ok := mapKeyFrom(segment, rv.Type().Key())
-					if !ok {
-						return nil
-					}
-					next := rv.MapIndex(key)
-					if !next.IsValid() {
-						return nil
-					}
-					current = next.Interface()
-				case reflect.Struct:
-					fieldName, ok := indirect(segment).(string)
-					if !ok || fieldName == "" {
-						return nil
-					}
-					field := rv.FieldByName(fieldName)
-					if !field.IsValid() || !field.CanInterface() {
-						return nil
-					}
-					current = field.Interface()
-				case reflect.Slice, reflect.Array:
-					idx, err := toInt(segment)
-					if err != nil || idx < 0 || idx >= rv.Len() {
-						return nil
-					}
-					current = rv.Index(idx).Interface()
-				default:
-					return nil
+			loc, err := lookupLocale(localeName)
+			if err != nil {
+				return "", err
+			}
+			zone, err := settings.zoneFor(value)
+			if err != nil {
+				return "", err
+			}
+			if zoneName != "" {
+				if zone, err = resolveTimeZone(zoneName); err != nil {
+					return "", err
 				}
 			}
-			return current
+
+			return formatValueWithPattern(value, pattern, loc, zone)
 		},
+		"safeAccess":     safeAccess,
+		"safeAccessLast": safeAccessLast,
+		"defaultEmpty":   defaultEmpty,
 		"exists": func(v any) bool {
 			return !isNilLike(v)
 		},
@@ -727,4 +1073,73 @@ func StubFuncMap() template.FuncMap {
 			return appendEuro(base)
 		},
 	}
+	funcs["optional"] = func(name string, args ...any) (any, error) {
+		return callOptional(funcs, name, args)
+	}
+	return funcs
+}
+
+// emptyValue is the value of a bare x! when x is missing: FreeMarker's empty
+// default, usable as an empty string, sequence or hash.
+type emptyValue []any
+
+// String renders the empty default as an empty string.
+func (emptyValue) String() string {
+	return ""
+}
+
+// defaultEmpty implements the bare x! operator.
+func defaultEmpty(v any) any {
+	if isNilLike(v) {
+		return emptyValue{}
+	}
+	return v
+}
+
+// callOptional calls the helper name with args unless one of them is missing,
+// in which case the result is missing too. It lets a parenthesized
+// (expr)!default or (expr)?? absorb missing values anywhere inside expr.
+func callOptional(funcs template.FuncMap, name string, args []any) (any, error) {
+	for _, arg := range args {
+		if isNilLike(arg) {
+			return nil, nil
+		}
+	}
+	if name == "len" {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("len expects one argument")
+		}
+		rv := reflect.ValueOf(indirect(args[0]))
+		switch rv.Kind() {
+		case reflect.Array, reflect.Chan, reflect.Map, reflect.Slice, reflect.String:
+			return rv.Len(), nil
+		}
+		return nil, fmt.Errorf("len of type %T", args[0])
+	}
+	fn, ok := funcs[name]
+	if !ok {
+		return nil, fmt.Errorf("optional: unknown helper %q", name)
+	}
+	fv := reflect.ValueOf(fn)
+	ft := fv.Type()
+	if (!ft.IsVariadic() && len(args) != ft.NumIn()) || (ft.IsVariadic() && len(args) < ft.NumIn()-1) {
+		return nil, fmt.Errorf("optional: wrong number of arguments for %s", name)
+	}
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		want := ft.In(min(i, ft.NumIn()-1))
+		if ft.IsVariadic() && i >= ft.NumIn()-1 {
+			want = want.Elem()
+		}
+		av := reflect.ValueOf(arg)
+		if !av.Type().AssignableTo(want) {
+			return nil, fmt.Errorf("optional: argument %d of %s must be %s, got %T", i+1, name, want, arg)
+		}
+		in[i] = av
+	}
+	out := fv.Call(in)
+	if len(out) == 2 && !out[1].IsNil() {
+		return nil, out[1].Interface().(error)
+	}
+	return out[0].Interface(), nil
 }
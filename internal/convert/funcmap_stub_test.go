@@ -1,10 +1,13 @@
 package convert
 
 import (
+	"encoding/json"
+	"fmt"
 	"html/template"
 	"testing"
 	"time"
 
+	"github.com/cruffinoni/ftl2gotpl/internal/decimal"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -151,13 +154,29 @@ func TestStubFuncMapNumberAndDatetime(t *testing.T) {
 
 	gotNum, err = toNumber("3.5")
 	assert.NoError(t, err)
-	assert.Equal(t, float64(3.5), gotNum)
+	assert.Equal(t, decimal.MustParse("3.5"), gotNum)
+
+	gotNum, err = toNumber("18446744073709551616")
+	assert.NoError(t, err)
+	assert.Equal(t, decimal.MustParse("18446744073709551616"), gotNum)
+
+	gotNum, err = toNumber(json.Number("19.990"))
+	assert.NoError(t, err)
+	assert.Equal(t, decimal.MustParse("19.990"), gotNum)
+
+	gotNum, err = toNumber(decimal.MustParse("12.00"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12), gotNum)
+
+	gotNum, err = toNumber(3.5)
+	assert.NoError(t, err)
+	assert.Equal(t, 3.5, gotNum)
 
 	gotNum, err = toNumber(12.0)
 	assert.NoError(t, err)
 	assert.Equal(t, int64(12), gotNum)
 
-	for _, invalid := range []any{nil, "", "   ", "oops", true, struct{}{}, []any{1}} {
+	for _, invalid := range []any{nil, "", "   ", "oops", "NaN", "0x10", true, struct{}{}, []any{1}} {
 		_, err = toNumber(invalid)
 		assert.Error(t, err)
 	}
@@ -189,6 +208,10 @@ func TestStubFuncMapToStringStrict(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "12", got)
 
+	got, err = toString(12345)
+	assert.NoError(t, err)
+	assert.Equal(t, "12,345", got)
+
 	got, err = toString(12345, "#,###")
 	assert.NoError(t, err)
 	assert.Equal(t, "12,345", got)
@@ -225,7 +248,11 @@ func TestStubFuncMapToStringStrict(t *testing.T) {
 	_, err = toString(ts, "#,###")
 	assert.Error(t, err)
 
-	_, err = toString(ts, "yyyy-MM-dd", "fr_FR")
+	got, err = toString(ts, "yyyy-MM-dd", "fr_FR")
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-05-20", got)
+
+	_, err = toString(ts, "yyyy-MM-dd", "xx_YY")
 	assert.Error(t, err)
 
 	_, err = toString(12, "#,##0", "#,##0", "#,##0")
@@ -301,3 +328,394 @@ func TestStubFuncMapTemplateName(t *testing.T) {
 	assert.Equal(t, "", templateName(nil))
 	assert.Equal(t, "welcome-email", templateName("  welcome-email  "))
 }
+
+func TestStubFuncMapBooleanFormatting(t *testing.T) {
+	fm := StubFuncMap()
+	formatBoolean := fm["formatBoolean"].(func(any, any, any) (string, error))
+	computerString := fm["computerString"].(func(any) (string, error))
+	ftlSettings := fm["ftlSettings"].(func(...any) (*formatSettings, error))
+	toString := fm["toString"].(func(...any) (string, error))
+
+	got, err := formatBoolean(true, "Yes", "No")
+	assert.NoError(t, err)
+	assert.Equal(t, "Yes", got)
+
+	got, err = formatBoolean(false, "Yes", "No")
+	assert.NoError(t, err)
+	assert.Equal(t, "No", got)
+
+	_, err = formatBoolean("true", "Yes", "No")
+	assert.Error(t, err)
+
+	got, err = computerString(false)
+	assert.NoError(t, err)
+	assert.Equal(t, "false", got)
+
+	got, err = computerString(1234.5)
+	assert.NoError(t, err)
+	assert.Equal(t, "1234.5", got)
+
+	_, err = computerString("x")
+	assert.Error(t, err)
+
+	settings, err := ftlSettings("boolean_format", "oui,non")
+	assert.NoError(t, err)
+	got, err = toString(true, settings)
+	assert.NoError(t, err)
+	assert.Equal(t, "oui", got)
+
+	got, err = toString(true)
+	assert.NoError(t, err)
+	assert.Equal(t, "true", got)
+
+	_, err = ftlSettings("boolean_format")
+	assert.Error(t, err)
+
+	_, err = ftlSettings("unknown", "x")
+	assert.Error(t, err)
+}
+
+func TestStubFuncMapLoopHelpers(t *testing.T) {
+	fm := StubFuncMap()
+	loopCounter := fm["loopCounter"].(func(any) (int, error))
+	loopHasNext := fm["loopHasNext"].(func(any, any) (bool, error))
+	loopParity := fm["loopParity"].(func(any) (string, error))
+	loopCycle := fm["loopCycle"].(func(any, ...any) (any, error))
+
+	got, err := loopCounter(0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, got)
+
+	hasNext, err := loopHasNext(1, 3)
+	assert.NoError(t, err)
+	assert.True(t, hasNext)
+
+	hasNext, err = loopHasNext(2, 3)
+	assert.NoError(t, err)
+	assert.False(t, hasNext)
+
+	parity, err := loopParity(0)
+	assert.NoError(t, err)
+	assert.Equal(t, "odd", parity)
+
+	parity, err = loopParity(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "even", parity)
+
+	cycled, err := loopCycle(4, "a", "b", "c")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", cycled)
+
+	_, err = loopCycle(0)
+	assert.Error(t, err)
+
+	_, err = loopCounter("1")
+	assert.Error(t, err)
+}
+
+func TestNewFuncMapClockHook(t *testing.T) {
+	fixed := time.Date(2026, 3, 12, 8, 0, 0, 0, time.UTC)
+	fm := NewFuncMap(FuncMapOptions{Now: func() time.Time { return fixed }})
+	now := fm["now"].(func() time.Time)
+
+	assert.Equal(t, fixed, now())
+}
+
+func TestStubFuncMapInterpolateWithSettings(t *testing.T) {
+	fm := StubFuncMap()
+	ftlSettings := fm["ftlSettings"].(func(...any) (*formatSettings, error))
+	interpolate := fm["interpolate"].(func(any, ...*formatSettings) (any, error))
+
+	settings, err := ftlSettings("number_format", "#,##0.00", "boolean_format", "yes,no", "datetime_format", "yyyy-MM-dd")
+	assert.NoError(t, err)
+
+	got, err := interpolate(1234.5, settings)
+	assert.NoError(t, err)
+	assert.Equal(t, "1,234.50", got)
+
+	got, err = interpolate(false, settings)
+	assert.NoError(t, err)
+	assert.Equal(t, "no", got)
+
+	got, err = interpolate(time.Date(2024, 5, 20, 10, 0, 0, 0, time.UTC), settings)
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-05-20", got)
+
+	got, err = interpolate("12", settings)
+	assert.NoError(t, err)
+	assert.Equal(t, "12", got)
+
+	computer, err := ftlSettings("number_format", "computer")
+	assert.NoError(t, err)
+	got, err = interpolate(1234.5, computer)
+	assert.NoError(t, err)
+	assert.Equal(t, "1234.5", got)
+
+	got, err = interpolate(1234.56789)
+	assert.NoError(t, err)
+	assert.Equal(t, "1,234.568", got)
+
+	_, err = interpolate(true)
+	assert.ErrorContains(t, err, "cannot print a boolean without boolean_format")
+
+	yesNo, err := ftlSettings("boolean_format", "yes,no")
+	assert.NoError(t, err)
+	got, err = interpolate(false, yesNo)
+	assert.NoError(t, err)
+	assert.Equal(t, "no", got)
+
+	items := []any{1}
+	got, err = interpolate(items)
+	assert.NoError(t, err)
+	assert.Equal(t, items, got)
+
+	currency, err := ftlSettings("number_format", "currency")
+	assert.NoError(t, err)
+	got, err = interpolate(1, currency)
+	assert.NoError(t, err)
+	assert.Equal(t, "$1.00", got)
+
+	invalid, err := ftlSettings("number_format", "0.0.0")
+	assert.NoError(t, err)
+	_, err = interpolate(1, invalid)
+	assert.Error(t, err)
+}
+
+func TestStubFuncMapLocaleFormatting(t *testing.T) {
+	fm := StubFuncMap()
+	toString := fm["toString"].(func(...any) (string, error))
+	ftlSettings := fm["ftlSettings"].(func(...any) (*formatSettings, error))
+	interpolate := fm["interpolate"].(func(any, ...*formatSettings) (any, error))
+	ts := time.Date(2026, 3, 12, 14, 5, 9, 0, time.UTC)
+
+	got, err := toString(1234.56, "#,##0.00", "fr_FR")
+	assert.NoError(t, err)
+	assert.Equal(t, "1 234,56", got)
+
+	got, err = toString(-1234.5, "#,##0.00", "de_DE")
+	assert.NoError(t, err)
+	assert.Equal(t, "-1.234,50", got)
+
+	got, err = toString(ts, "d. MMMM yyyy", "de_DE")
+	assert.NoError(t, err)
+	assert.Equal(t, "12. März 2026", got)
+
+	got, err = toString(ts, "EEEE d MMM", "fr-fr")
+	assert.NoError(t, err)
+	assert.Equal(t, "jeudi 12 mars", got)
+
+	got, err = toString(ts, "long", "de")
+	assert.NoError(t, err)
+	assert.Equal(t, "12. März 2026, 14:05:09 UTC", got)
+
+	_, err = toString(ts, "long", "de_CH")
+	assert.ErrorContains(t, err, `unsupported toString locale/timezone "de_CH"`)
+
+	got, err = toString(ts, "medium_short")
+	assert.NoError(t, err)
+	assert.Equal(t, "Mar 12, 2026, 2:05 PM", got)
+
+	german, err := ftlSettings("locale", "de_DE")
+	assert.NoError(t, err)
+
+	got, err = toString(1234.5, "#,##0.00", german)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.234,50", got)
+
+	got, err = toString(1234.5, "#,##0.00", "en_US", german)
+	assert.NoError(t, err)
+	assert.Equal(t, "1,234.50", got)
+
+	formatted, err := interpolate(1234.5, german)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.234,5", formatted)
+
+	formatted, err = interpolate(ts, german)
+	assert.NoError(t, err)
+	assert.Equal(t, "12.03.2026, 14:05:09", formatted)
+
+	formatted, err = interpolate(ts)
+	assert.NoError(t, err)
+	assert.Equal(t, "Mar 12, 2026, 2:05:09 PM", formatted)
+
+	_, err = toString(ts, "yyyy-MM-dd Q")
+	assert.Error(t, err)
+}
+
+func TestStubFuncMapDateParsing(t *testing.T) {
+	fm := NewFuncMap(FuncMapOptions{Now: func() time.Time { return time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC) }})
+	parseDate := fm["parseDate"].(func(any, ...any) (datetimeValue, error))
+	parseTime := fm["parseTime"].(func(any, ...any) (datetimeValue, error))
+	ftlSettings := fm["ftlSettings"].(func(...any) (*formatSettings, error))
+	interpolate := fm["interpolate"].(func(any, ...*formatSettings) (any, error))
+	toString := fm["toString"].(func(...any) (string, error))
+	hasContent := fm["hasContent"].(func(any) bool)
+
+	date, err := parseDate("2026-03-12", "yyyy-MM-dd")
+	assert.NoError(t, err)
+	got, err := interpolate(date)
+	assert.NoError(t, err)
+	assert.Equal(t, "Mar 12, 2026", got)
+	assert.True(t, hasContent(date))
+
+	formatted, err := toString(date, "EEEE d MMMM yyyy", "de_DE")
+	assert.NoError(t, err)
+	assert.Equal(t, "Donnerstag 12 März 2026", formatted)
+
+	german, err := ftlSettings("locale", "de_DE", "date_format", "d. MMMM yyyy")
+	assert.NoError(t, err)
+	date, err = parseDate("12. März 2026", german)
+	assert.NoError(t, err)
+	got, err = interpolate(date, german)
+	assert.NoError(t, err)
+	assert.Equal(t, "12. März 2026", got)
+
+	slot, err := parseTime("14:30", "HH:mm")
+	assert.NoError(t, err)
+	got, err = interpolate(slot)
+	assert.NoError(t, err)
+	assert.Equal(t, "2:30:00 PM", got)
+
+	retagged, err := parseDate(time.Date(2026, 3, 12, 14, 30, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	got, err = interpolate(retagged)
+	assert.NoError(t, err)
+	assert.Equal(t, "Mar 12, 2026", got)
+
+	_, err = parseDate("2026-02-30", "yyyy-MM-dd")
+	assert.Error(t, err)
+	_, err = parseDate(12, "yyyy")
+	assert.Error(t, err)
+}
+
+func TestStubFuncMapTimeZones(t *testing.T) {
+	fm := StubFuncMap()
+	toString := fm["toString"].(func(...any) (string, error))
+	ftlSettings := fm["ftlSettings"].(func(...any) (*formatSettings, error))
+	interpolate := fm["interpolate"].(func(any, ...*formatSettings) (any, error))
+	parseDate := fm["parseDate"].(func(any, ...any) (datetimeValue, error))
+	ts := time.Date(2026, 3, 12, 23, 30, 0, 0, time.UTC)
+
+	got, err := toString(ts, "yyyy-MM-dd HH:mm z", "Europe/Paris")
+	assert.NoError(t, err)
+	assert.Equal(t, "2026-03-13 00:30 CET", got)
+
+	got, err = toString(ts, "d MMMM HH:mm XXX", "de_DE", "America/New_York")
+	assert.NoError(t, err)
+	assert.Equal(t, "12 März 19:30 -04:00", got)
+
+	_, err = toString(ts, "HH:mm", "Mars/Olympus")
+	assert.Error(t, err)
+
+	tokyo, err := ftlSettings("time_zone", "Asia/Tokyo", "datetime_format", "yyyy-MM-dd HH:mm")
+	assert.NoError(t, err)
+	formatted, err := interpolate(ts, tokyo)
+	assert.NoError(t, err)
+	assert.Equal(t, "2026-03-13 08:30", formatted)
+
+	got, err = toString(ts, "HH:mm", "UTC", tokyo)
+	assert.NoError(t, err)
+	assert.Equal(t, "23:30", got)
+
+	sqlZone, err := ftlSettings("time_zone", "Asia/Tokyo", "sql_date_and_time_time_zone", "UTC", "date_format", "yyyy-MM-dd")
+	assert.NoError(t, err)
+	date, err := parseDate("2026-03-12", sqlZone)
+	assert.NoError(t, err)
+	formatted, err = interpolate(date, sqlZone)
+	assert.NoError(t, err)
+	assert.Equal(t, "2026-03-12", formatted)
+	formatted, err = interpolate(ts, sqlZone)
+	assert.NoError(t, err)
+	assert.Equal(t, "Mar 13, 2026, 8:30:00 AM", formatted)
+}
+
+func TestStubFuncMapAsBool(t *testing.T) {
+	asBool := StubFuncMap()["asBool"].(func(any) (bool, error))
+
+	got, err := asBool(true)
+	assert.NoError(t, err)
+	assert.True(t, got)
+
+	flag := false
+	got, err = asBool(&flag)
+	assert.NoError(t, err)
+	assert.False(t, got)
+
+	for _, invalid := range []any{nil, "", "true", int64(0), []any{}} {
+		_, err := asBool(invalid)
+		assert.ErrorIs(t, err, ErrNonBooleanCondition, "%v", invalid)
+	}
+}
+
+func TestStubFuncMapFormatNumeric(t *testing.T) {
+	formatNumeric := StubFuncMap()["formatNumeric"].(func(any, any, any) (string, error))
+
+	tests := []struct {
+		value    any
+		min, max int
+		want     string
+	}{
+		{2.582, 0, 2, "2.58"},
+		{int64(4), 0, 2, "4"},
+		{2.582, 2, 2, "2.58"},
+		{int64(4), 2, 2, "4.00"},
+		{int64(4), 1, 2, "4.0"},
+		{int64(1234567), 0, 50, "1234567"},
+		{decimal.MustParse("-0.125"), 0, 2, "-0.12"},
+		{decimal.MustParse("0.1000000000000000000001"), 0, 50, "0.1000000000000000000001"},
+	}
+	for _, tc := range tests {
+		got, err := formatNumeric(tc.value, tc.min, tc.max)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.want, got)
+	}
+
+	_, err := formatNumeric("4", 0, 2)
+	assert.EqualError(t, err, "#{...} requires a numeric value, got string")
+	_, err = formatNumeric(int64(4), 3, 2)
+	assert.Error(t, err)
+}
+
+func TestStubFuncMapMissingValueHelpers(t *testing.T) {
+	fm := StubFuncMap()
+	safeAccessLast := fm["safeAccessLast"].(func(any, ...any) (any, error))
+	defaultEmpty := fm["defaultEmpty"].(func(any) any)
+	optional := fm["optional"].(func(string, ...any) (any, error))
+
+	data := map[string]any{"user": map[string]any{"name": "alice", "tags": []any{"a"}}}
+	got, err := safeAccessLast(data, "user", "name")
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", got)
+	got, err = safeAccessLast(data, "user", "missing")
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+	_, err = safeAccessLast(data, "account", "name")
+	assert.EqualError(t, err, "account is missing; only the last step before ! or ?? may be missing")
+	_, err = safeAccessLast(data, "user", "tags", 3, "label")
+	assert.EqualError(t, err, "user.tags[3] is missing; only the last step before ! or ?? may be missing")
+
+	assert.Equal(t, "x", defaultEmpty("x"))
+	empty := defaultEmpty(nil)
+	assert.Equal(t, "", fmt.Sprint(empty))
+	assert.False(t, fm["hasContent"].(func(any) bool)(empty))
+	equal, err := compareValues("==", empty, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, equal)
+
+	got, err = optional("trim", " a ")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", got)
+	got, err = optional("trim", nil)
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+	got, err = optional("len", []any{1, 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, got)
+	got, err = optional("substring", "abcdef", int64(1), int64(3))
+	assert.NoError(t, err)
+	assert.Equal(t, "bc", got)
+	_, err = optional("substring", "abc", int64(2), int64(1))
+	assert.Error(t, err)
+	_, err = optional("nope", "x")
+	assert.EqualError(t, err, `optional: unknown helper "nope"`)
+}
@@ -0,0 +1,147 @@
+package convert
+
+import "github.com/cruffinoni/ftl2gotpl/internal/ast"
+
+// hoistedVariables returns, in order of first assignment, the variables first
+// assigned inside an if or list block. A Go template variable ends with the
+// block that declares it, while a FreeMarker assignment outlives it, so these
+// variables are declared before the template body and assigned with = inside
+// the blocks.
+//
+// Capture, attempt and compress bodies render through their own define, so
+// the variables they assign are hoisted too and copied back after the define
+// ran. Variables first assigned after a node containing <#return> are hoisted
+// as well, since the rest of the template is then guarded by an if block.
+func hoistedVariables(nodes []ast.Node) []string {
+	seen := map[string]struct{}{}
+	var hoisted []string
+	var walk func(nodes []ast.Node, nested bool)
+	walk = func(nodes []ast.Node, nested bool) {
+		for _, node := range nodes {
+			switch n := node.(type) {
+			case ast.AssignNode:
+				for _, a := range n.Assignments {
+					if _, ok := seen[a.Name]; ok {
+						continue
+					}
+					seen[a.Name] = struct{}{}
+					if nested {
+						hoisted = append(hoisted, a.Name)
+					}
+				}
+				walk(n.Body, true)
+			case ast.IfNode:
+				walk(n.Then, true)
+				for _, alt := range n.ElseIf {
+					walk(alt.Body, true)
+				}
+				walk(n.Else, true)
+			case ast.ListNode:
+				walk(n.Body, true)
+			case ast.AttemptNode:
+				walk(n.Body, true)
+				walk(n.Recover, true)
+			case ast.CompressNode:
+				walk(n.Body, true)
+			case ast.EscapeNode, ast.NoEscapeNode, ast.OutputFormatNode, ast.AutoEscNode:
+				// Escaping blocks emit no Go block of their own.
+				walk(escapingBody(n), nested)
+			}
+			// Nodes after a <#return> are emitted inside a guard block.
+			if containsReturn(node) {
+				nested = true
+			}
+		}
+	}
+	walk(nodes, false)
+	return hoisted
+}
+
+// containsReturn reports whether node is or holds a top-level <#return>,
+// looking into if, list and escaping blocks and recover bodies but not into
+// functions, capture or attempt bodies.
+func containsReturn(node ast.Node) bool {
+	switch n := node.(type) {
+	case ast.BareDirectiveNode:
+		return n.Name == "return"
+	case ast.IfNode:
+		if anyReturns(n.Then) || anyReturns(n.Else) {
+			return true
+		}
+		for _, alt := range n.ElseIf {
+			if anyReturns(alt.Body) {
+				return true
+			}
+		}
+	case ast.ListNode:
+		return anyReturns(n.Body)
+	case ast.AttemptNode:
+		return anyReturns(n.Recover)
+	case ast.EscapeNode, ast.NoEscapeNode, ast.OutputFormatNode, ast.AutoEscNode:
+		return anyReturns(escapingBody(n))
+	}
+	return false
+}
+
+// assignedVariables returns, in order of first assignment, the variables
+// assigned anywhere in nodes.
+func assignedVariables(nodes []ast.Node) []string {
+	seen := map[string]struct{}{}
+	var names []string
+	var walk func(nodes []ast.Node)
+	walk = func(nodes []ast.Node) {
+		for _, node := range nodes {
+			switch n := node.(type) {
+			case ast.AssignNode:
+				for _, a := range n.Assignments {
+					if _, ok := seen[a.Name]; !ok {
+						seen[a.Name] = struct{}{}
+						names = append(names, a.Name)
+					}
+				}
+				walk(n.Body)
+			case ast.IfNode:
+				walk(n.Then)
+				for _, alt := range n.ElseIf {
+					walk(alt.Body)
+				}
+				walk(n.Else)
+			case ast.ListNode:
+				walk(n.Body)
+			case ast.AttemptNode:
+				walk(n.Body)
+				walk(n.Recover)
+			case ast.CompressNode:
+				walk(n.Body)
+			case ast.EscapeNode, ast.NoEscapeNode, ast.OutputFormatNode, ast.AutoEscNode:
+				walk(escapingBody(n))
+			}
+		}
+	}
+	walk(nodes)
+	return names
+}
+
+// escapingBody returns the body of an escaping directive node.
+func escapingBody(node ast.Node) []ast.Node {
+	switch n := node.(type) {
+	case ast.EscapeNode:
+		return n.Body
+	case ast.NoEscapeNode:
+		return n.Body
+	case ast.OutputFormatNode:
+		return n.Body
+	case ast.AutoEscNode:
+		return n.Body
+	}
+	return nil
+}
+
+func anyReturns(nodes []ast.Node) bool {
+	for _, node := range nodes {
+		if containsReturn(node) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,372 @@
+// Package convert transforms FreeMarker templates into Go templates.
+package convert
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// localeData is the CLDR subset used by the number and datetime helpers.
+//
+// Date and time styles are SimpleDateFormat patterns for Java's SHORT, MEDIUM,
+// LONG and FULL styles; dateTime combines a date ({1}) and a time ({0}).
+// firstDayOfWeek and minWeekDays drive the week fields (w, W, Y).
+// zoneNames holds the standard and daylight long names "zzzz" prints for
+// each zoneMetazones group.
+type localeData struct {
+	decimal         string
+	group           string
+	minus           string
+	percent         string
+	perMille        string
+	exponent        string
+	currencySymbol  string
+	currencyCode    string
+	currencyPattern string
+	percentPattern  string
+	months          [12]string
+	monthsShort     [12]string
+	days            [7]string
+	daysShort       [7]string
+	amPm            [2]string
+	eras            [2]string
+	dateStyles      [4]string
+	timeStyles      [4]string
+	dateTime        string
+	firstDayOfWeek  time.Weekday
+	minWeekDays     int
+	zoneNames       map[string][2]string
+}
+
+const (
+	nbsp       = " "
+	narrowNbsp = " "
+)
+
+var (
+	englishMonths      = [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"}
+	englishMonthsShort = [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"}
+	englishDays        = [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+	englishDaysShort   = [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+	englishZoneNames   = map[string][2]string{
+		"UTC":              {"Coordinated Universal Time", "Coordinated Universal Time"},
+		"GMT":              {"Greenwich Mean Time", "British Summer Time"},
+		"Europe_Western":   {"Western European Standard Time", "Western European Summer Time"},
+		"Europe_Central":   {"Central European Standard Time", "Central European Summer Time"},
+		"Europe_Eastern":   {"Eastern European Standard Time", "Eastern European Summer Time"},
+		"America_Eastern":  {"Eastern Standard Time", "Eastern Daylight Time"},
+		"America_Central":  {"Central Standard Time", "Central Daylight Time"},
+		"America_Mountain": {"Mountain Standard Time", "Mountain Daylight Time"},
+		"America_Pacific":  {"Pacific Standard Time", "Pacific Daylight Time"},
+		"China":            {"China Standard Time", "China Daylight Time"},
+		"Japan":            {"Japan Standard Time", "Japan Daylight Time"},
+	}
+)
+
+// zoneMetazones groups IANA zone IDs the way CLDR metazones do, so zones
+// that share long names share a zoneNames entry. Zones missing here print
+// their short name for "zzzz".
+var zoneMetazones = map[string]string{
+	"UTC":                          "UTC",
+	"Etc/UTC":                      "UTC",
+	"GMT":                          "GMT",
+	"Etc/GMT":                      "GMT",
+	"Europe/London":                "GMT",
+	"Europe/Lisbon":                "Europe_Western",
+	"Atlantic/Canary":              "Europe_Western",
+	"Atlantic/Madeira":             "Europe_Western",
+	"Atlantic/Faroe":               "Europe_Western",
+	"Europe/Amsterdam":             "Europe_Central",
+	"Europe/Andorra":               "Europe_Central",
+	"Europe/Belgrade":              "Europe_Central",
+	"Europe/Berlin":                "Europe_Central",
+	"Europe/Bratislava":            "Europe_Central",
+	"Europe/Brussels":              "Europe_Central",
+	"Europe/Budapest":              "Europe_Central",
+	"Europe/Copenhagen":            "Europe_Central",
+	"Europe/Gibraltar":             "Europe_Central",
+	"Europe/Ljubljana":             "Europe_Central",
+	"Europe/Luxembourg":            "Europe_Central",
+	"Europe/Madrid":                "Europe_Central",
+	"Europe/Malta":                 "Europe_Central",
+	"Europe/Monaco":                "Europe_Central",
+	"Europe/Oslo":                  "Europe_Central",
+	"Europe/Paris":                 "Europe_Central",
+	"Europe/Prague":                "Europe_Central",
+	"Europe/Rome":                  "Europe_Central",
+	"Europe/Sarajevo":              "Europe_Central",
+	"Europe/Skopje":                "Europe_Central",
+	"Europe/Stockholm":             "Europe_Central",
+	"Europe/Tirane":                "Europe_Central",
+	"Europe/Vaduz":                 "Europe_Central",
+	"Europe/Vienna":                "Europe_Central",
+	"Europe/Warsaw":                "Europe_Central",
+	"Europe/Zagreb":                "Europe_Central",
+	"Europe/Zurich":                "Europe_Central",
+	"Europe/Athens":                "Europe_Eastern",
+	"Europe/Bucharest":             "Europe_Eastern",
+	"Europe/Chisinau":              "Europe_Eastern",
+	"Europe/Helsinki":              "Europe_Eastern",
+	"Europe/Kiev":                  "Europe_Eastern",
+	"Europe/Kyiv":                  "Europe_Eastern",
+	"Europe/Riga":                  "Europe_Eastern",
+	"Europe/Sofia":                 "Europe_Eastern",
+	"Europe/Tallinn":               "Europe_Eastern",
+	"Europe/Vilnius":               "Europe_Eastern",
+	"Asia/Nicosia":                 "Europe_Eastern",
+	"America/Detroit":              "America_Eastern",
+	"America/Indiana/Indianapolis": "America_Eastern",
+	"America/Nassau":               "America_Eastern",
+	"America/New_York":             "America_Eastern",
+	"America/Toronto":              "America_Eastern",
+	"America/Chicago":              "America_Central",
+	"America/Mexico_City":          "America_Central",
+	"America/Winnipeg":             "America_Central",
+	"America/Boise":                "America_Mountain",
+	"America/Denver":               "America_Mountain",
+	"America/Edmonton":             "America_Mountain",
+	"America/Phoenix":              "America_Mountain",
+	"America/Los_Angeles":          "America_Pacific",
+	"America/Tijuana":              "America_Pacific",
+	"America/Vancouver":            "America_Pacific",
+	"Asia/Shanghai":                "China",
+	"Asia/Tokyo":                   "Japan",
+}
+
+// locales maps normalized locale tags to their formatting data.
+var locales = map[string]*localeData{
+	"en_US": {
+		decimal: ".", group: ",", minus: "-", percent: "%", perMille: "‰", exponent: "E",
+		currencySymbol: "$", currencyCode: "USD",
+		currencyPattern: "¤#,##0.00", percentPattern: "#,##0%",
+		months: englishMonths, monthsShort: englishMonthsShort,
+		days: englishDays, daysShort: englishDaysShort,
+		amPm: [2]string{"AM", "PM"}, eras: [2]string{"BC", "AD"},
+		dateStyles:     [4]string{"M/d/yy", "MMM d, y", "MMMM d, y", "EEEE, MMMM d, y"},
+		timeStyles:     [4]string{"h:mm a", "h:mm:ss a", "h:mm:ss a z", "h:mm:ss a zzzz"},
+		dateTime:       "{1}, {0}",
+		firstDayOfWeek: time.Sunday, minWeekDays: 1,
+		zoneNames: englishZoneNames,
+	},
+	"en_GB": {
+		decimal: ".", group: ",", minus: "-", percent: "%", perMille: "‰", exponent: "E",
+		currencySymbol: "£", currencyCode: "GBP",
+		currencyPattern: "¤#,##0.00", percentPattern: "#,##0%",
+		months: englishMonths, monthsShort: englishMonthsShort,
+		days: englishDays, daysShort: englishDaysShort,
+		amPm: [2]string{"am", "pm"}, eras: [2]string{"BC", "AD"},
+		dateStyles:     [4]string{"dd/MM/y", "d MMM y", "d MMMM y", "EEEE, d MMMM y"},
+		timeStyles:     [4]string{"HH:mm", "HH:mm:ss", "HH:mm:ss z", "HH:mm:ss zzzz"},
+		dateTime:       "{1}, {0}",
+		firstDayOfWeek: time.Monday, minWeekDays: 4,
+		zoneNames: englishZoneNames,
+	},
+	"fr_FR": {
+		decimal: ",", group: narrowNbsp, minus: "-", percent: "%", perMille: "‰", exponent: "E",
+		currencySymbol: "€", currencyCode: "EUR",
+		currencyPattern: "#,##0.00" + nbsp + "¤", percentPattern: "#,##0" + narrowNbsp + "%",
+		months:      [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		monthsShort: [12]string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		days:        [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+		daysShort:   [7]string{"dim.", "lun.", "mar.", "mer.", "jeu.", "ven.", "sam."},
+		amPm:        [2]string{"AM", "PM"}, eras: [2]string{"av. J.-C.", "ap. J.-C."},
+		dateStyles:     [4]string{"dd/MM/y", "d MMM y", "d MMMM y", "EEEE d MMMM y"},
+		timeStyles:     [4]string{"HH:mm", "HH:mm:ss", "HH:mm:ss z", "HH:mm:ss zzzz"},
+		dateTime:       "{1} {0}",
+		firstDayOfWeek: time.Monday, minWeekDays: 4,
+		zoneNames: map[string][2]string{
+			"UTC":              {"temps universel coordonné", "temps universel coordonné"},
+			"GMT":              {"heure moyenne de Greenwich", "heure d’été britannique"},
+			"Europe_Western":   {"heure normale d’Europe de l’Ouest", "heure d’été d’Europe de l’Ouest"},
+			"Europe_Central":   {"heure normale d’Europe centrale", "heure d’été d’Europe centrale"},
+			"Europe_Eastern":   {"heure normale d’Europe de l’Est", "heure d’été d’Europe de l’Est"},
+			"America_Eastern":  {"heure normale de l’Est nord-américain", "heure d’été de l’Est nord-américain"},
+			"America_Central":  {"heure normale du centre nord-américain", "heure d’été du centre nord-américain"},
+			"America_Mountain": {"heure normale des Rocheuses", "heure d’été des Rocheuses"},
+			"America_Pacific":  {"heure normale du Pacifique nord-américain", "heure d’été du Pacifique nord-américain"},
+			"China":            {"heure normale de la Chine", "heure d’été de Chine"},
+			"Japan":            {"heure normale du Japon", "heure d’été du Japon"},
+		},
+	},
+	"de_DE": {
+		decimal: ",", group: ".", minus: "-", percent: "%", perMille: "‰", exponent: "E",
+		currencySymbol: "€", currencyCode: "EUR",
+		currencyPattern: "#,##0.00" + nbsp + "¤", percentPattern: "#,##0" + nbsp + "%",
+		months:      [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		monthsShort: [12]string{"Jan.", "Feb.", "März", "Apr.", "Mai", "Juni", "Juli", "Aug.", "Sept.", "Okt.", "Nov.", "Dez."},
+		days:        [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		daysShort:   [7]string{"So.", "Mo.", "Di.", "Mi.", "Do.", "Fr.", "Sa."},
+		amPm:        [2]string{"AM", "PM"}, eras: [2]string{"v. Chr.", "n. Chr."},
+		dateStyles:     [4]string{"dd.MM.yy", "dd.MM.y", "d. MMMM y", "EEEE, d. MMMM y"},
+		timeStyles:     [4]string{"HH:mm", "HH:mm:ss", "HH:mm:ss z", "HH:mm:ss zzzz"},
+		dateTime:       "{1}, {0}",
+		firstDayOfWeek: time.Monday, minWeekDays: 4,
+		zoneNames: map[string][2]string{
+			"UTC":              {"Koordinierte Weltzeit", "Koordinierte Weltzeit"},
+			"GMT":              {"Mittlere Greenwich-Zeit", "Britische Sommerzeit"},
+			"Europe_Western":   {"Westeuropäische Normalzeit", "Westeuropäische Sommerzeit"},
+			"Europe_Central":   {"Mitteleuropäische Normalzeit", "Mitteleuropäische Sommerzeit"},
+			"Europe_Eastern":   {"Osteuropäische Normalzeit", "Osteuropäische Sommerzeit"},
+			"America_Eastern":  {"Nordamerikanische Ostküsten-Normalzeit", "Nordamerikanische Ostküsten-Sommerzeit"},
+			"America_Central":  {"Nordamerikanische Zentral-Normalzeit", "Nordamerikanische Zentral-Sommerzeit"},
+			"America_Mountain": {"Rocky-Mountain-Normalzeit", "Rocky-Mountain-Sommerzeit"},
+			"America_Pacific":  {"Nordamerikanische Westküsten-Normalzeit", "Nordamerikanische Westküsten-Sommerzeit"},
+			"China":            {"Chinesische Normalzeit", "Chinesische Sommerzeit"},
+			"Japan":            {"Japanische Normalzeit", "Japanische Sommerzeit"},
+		},
+	},
+	"es_ES": {
+		decimal: ",", group: ".", minus: "-", percent: "%", perMille: "‰", exponent: "E",
+		currencySymbol: "€", currencyCode: "EUR",
+		currencyPattern: "#,##0.00" + nbsp + "¤", percentPattern: "#,##0" + nbsp + "%",
+		months:      [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		monthsShort: [12]string{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sept", "oct", "nov", "dic"},
+		days:        [7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+		daysShort:   [7]string{"dom", "lun", "mar", "mié", "jue", "vie", "sáb"},
+		amPm:        [2]string{"a." + nbsp + "m.", "p." + nbsp + "m."}, eras: [2]string{"a. C.", "d. C."},
+		dateStyles:     [4]string{"d/M/yy", "d MMM y", "d 'de' MMMM 'de' y", "EEEE, d 'de' MMMM 'de' y"},
+		timeStyles:     [4]string{"H:mm", "H:mm:ss", "H:mm:ss z", "H:mm:ss (zzzz)"},
+		dateTime:       "{1}, {0}",
+		firstDayOfWeek: time.Monday, minWeekDays: 4,
+		zoneNames: map[string][2]string{
+			"UTC":              {"tiempo universal coordinado", "tiempo universal coordinado"},
+			"GMT":              {"hora del meridiano de Greenwich", "hora de verano británica"},
+			"Europe_Western":   {"hora estándar de Europa occidental", "hora de verano de Europa occidental"},
+			"Europe_Central":   {"hora estándar de Europa central", "hora de verano de Europa central"},
+			"Europe_Eastern":   {"hora estándar de Europa oriental", "hora de verano de Europa oriental"},
+			"America_Eastern":  {"hora estándar oriental", "hora de verano oriental"},
+			"America_Central":  {"hora estándar central", "hora de verano central"},
+			"America_Mountain": {"hora estándar de las Montañas Rocosas", "hora de verano de las Montañas Rocosas"},
+			"America_Pacific":  {"hora estándar del Pacífico", "hora de verano del Pacífico"},
+			"China":            {"hora estándar de China", "hora de verano de China"},
+			"Japan":            {"hora estándar de Japón", "hora de verano de Japón"},
+		},
+	},
+	"it_IT": {
+		decimal: ",", group: ".", minus: "-", percent: "%", perMille: "‰", exponent: "E",
+		currencySymbol: "€", currencyCode: "EUR",
+		currencyPattern: "#,##0.00" + nbsp + "¤", percentPattern: "#,##0%",
+		months:      [12]string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"},
+		monthsShort: [12]string{"gen", "feb", "mar", "apr", "mag", "giu", "lug", "ago", "set", "ott", "nov", "dic"},
+		days:        [7]string{"domenica", "lunedì", "martedì", "mercoledì", "giovedì", "venerdì", "sabato"},
+		daysShort:   [7]string{"dom", "lun", "mar", "mer", "gio", "ven", "sab"},
+		amPm:        [2]string{"AM", "PM"}, eras: [2]string{"a.C.", "d.C."},
+		dateStyles:     [4]string{"dd/MM/yy", "d MMM y", "d MMMM y", "EEEE d MMMM y"},
+		timeStyles:     [4]string{"HH:mm", "HH:mm:ss", "HH:mm:ss z", "HH:mm:ss zzzz"},
+		dateTime:       "{1}, {0}",
+		firstDayOfWeek: time.Monday, minWeekDays: 4,
+		zoneNames: map[string][2]string{
+			"UTC":              {"Tempo coordinato universale", "Tempo coordinato universale"},
+			"GMT":              {"Ora del meridiano di Greenwich", "Ora legale del Regno Unito"},
+			"Europe_Western":   {"Ora standard dell’Europa occidentale", "Ora legale dell’Europa occidentale"},
+			"Europe_Central":   {"Ora standard dell’Europa centrale", "Ora legale dell’Europa centrale"},
+			"Europe_Eastern":   {"Ora standard dell’Europa orientale", "Ora legale dell’Europa orientale"},
+			"America_Eastern":  {"Ora standard orientale USA", "Ora legale orientale USA"},
+			"America_Central":  {"Ora standard centrale USA", "Ora legale centrale USA"},
+			"America_Mountain": {"Ora standard Montagne Rocciose USA", "Ora legale Montagne Rocciose USA"},
+			"America_Pacific":  {"Ora standard del Pacifico USA", "Ora legale del Pacifico USA"},
+			"China":            {"Ora standard della Cina", "Ora legale della Cina"},
+			"Japan":            {"Ora standard del Giappone", "Ora legale del Giappone"},
+		},
+	},
+	"nl_NL": {
+		decimal: ",", group: ".", minus: "-", percent: "%", perMille: "‰", exponent: "E",
+		currencySymbol: "€", currencyCode: "EUR",
+		currencyPattern: "¤" + nbsp + "#,##0.00", percentPattern: "#,##0%",
+		months:      [12]string{"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"},
+		monthsShort: [12]string{"jan.", "feb.", "mrt.", "apr.", "mei", "jun.", "jul.", "aug.", "sep.", "okt.", "nov.", "dec."},
+		days:        [7]string{"zondag", "maandag", "dinsdag", "woensdag", "donderdag", "vrijdag", "zaterdag"},
+		daysShort:   [7]string{"zo", "ma", "di", "wo", "do", "vr", "za"},
+		amPm:        [2]string{"a.m.", "p.m."}, eras: [2]string{"v.Chr.", "n.Chr."},
+		dateStyles:     [4]string{"dd-MM-y", "d MMM y", "d MMMM y", "EEEE d MMMM y"},
+		timeStyles:     [4]string{"HH:mm", "HH:mm:ss", "HH:mm:ss z", "HH:mm:ss zzzz"},
+		dateTime:       "{1} {0}",
+		firstDayOfWeek: time.Monday, minWeekDays: 4,
+		zoneNames: map[string][2]string{
+			"UTC":              {"gecoördineerde wereldtijd", "gecoördineerde wereldtijd"},
+			"GMT":              {"Greenwich Mean Time", "Britse zomertijd"},
+			"Europe_Western":   {"West-Europese standaardtijd", "West-Europese zomertijd"},
+			"Europe_Central":   {"Midden-Europese standaardtijd", "Midden-Europese zomertijd"},
+			"Europe_Eastern":   {"Oost-Europese standaardtijd", "Oost-Europese zomertijd"},
+			"America_Eastern":  {"Eastern-standaardtijd", "Eastern-zomertijd"},
+			"America_Central":  {"Central-standaardtijd", "Central-zomertijd"},
+			"America_Mountain": {"Mountain-standaardtijd", "Mountain-zomertijd"},
+			"America_Pacific":  {"Pacific-standaardtijd", "Pacific-zomertijd"},
+			"China":            {"Chinese standaardtijd", "Chinese zomertijd"},
+			"Japan":            {"Japanse standaardtijd", "Japanse zomertijd"},
+		},
+	},
+	"pt_BR": {
+		decimal: ",", group: ".", minus: "-", percent: "%", perMille: "‰", exponent: "E",
+		currencySymbol: "R$", currencyCode: "BRL",
+		currencyPattern: "¤" + nbsp + "#,##0.00", percentPattern: "#,##0%",
+		months:      [12]string{"janeiro", "fevereiro", "março", "abril", "maio", "junho", "julho", "agosto", "setembro", "outubro", "novembro", "dezembro"},
+		monthsShort: [12]string{"jan.", "fev.", "mar.", "abr.", "mai.", "jun.", "jul.", "ago.", "set.", "out.", "nov.", "dez."},
+		days:        [7]string{"domingo", "segunda-feira", "terça-feira", "quarta-feira", "quinta-feira", "sexta-feira", "sábado"},
+		daysShort:   [7]string{"dom.", "seg.", "ter.", "qua.", "qui.", "sex.", "sáb."},
+		amPm:        [2]string{"AM", "PM"}, eras: [2]string{"a.C.", "d.C."},
+		dateStyles:     [4]string{"dd/MM/y", "d 'de' MMM 'de' y", "d 'de' MMMM 'de' y", "EEEE, d 'de' MMMM 'de' y"},
+		timeStyles:     [4]string{"HH:mm", "HH:mm:ss", "HH:mm:ss z", "HH:mm:ss zzzz"},
+		dateTime:       "{1} {0}",
+		firstDayOfWeek: time.Sunday, minWeekDays: 1,
+		zoneNames: map[string][2]string{
+			"UTC":              {"Horário Universal Coordenado", "Horário Universal Coordenado"},
+			"GMT":              {"Horário do Meridiano de Greenwich", "Horário de Verão Britânico"},
+			"Europe_Western":   {"Horário Padrão da Europa Ocidental", "Horário de Verão da Europa Ocidental"},
+			"Europe_Central":   {"Horário Padrão da Europa Central", "Horário de Verão da Europa Central"},
+			"Europe_Eastern":   {"Horário Padrão da Europa Oriental", "Horário de Verão da Europa Oriental"},
+			"America_Eastern":  {"Horário Padrão do Leste", "Horário de Verão do Leste"},
+			"America_Central":  {"Horário Padrão Central", "Horário de Verão Central"},
+			"America_Mountain": {"Horário Padrão das Montanhas", "Horário de Verão das Montanhas"},
+			"America_Pacific":  {"Horário Padrão do Pacífico", "Horário de Verão do Pacífico"},
+			"China":            {"Horário Padrão da China", "Horário de Verão da China"},
+			"Japan":            {"Horário Padrão do Japão", "Horário de Verão do Japão"},
+		},
+	},
+}
+
+// languageFallbacks maps bare languages to the locale used for them.
+var languageFallbacks = map[string]string{
+	"en": "en_US",
+	"fr": "fr_FR",
+	"de": "de_DE",
+	"es": "es_ES",
+	"it": "it_IT",
+	"nl": "nl_NL",
+	"pt": "pt_BR",
+}
+
+// normalizeLocale converts "fr-fr" style tags to FreeMarker's "fr_FR" form.
+func normalizeLocale(tag string) string {
+	tag = strings.ReplaceAll(strings.TrimSpace(tag), "-", "_")
+	lang, region, found := strings.Cut(tag, "_")
+	if !found {
+		return strings.ToLower(lang)
+	}
+	return strings.ToLower(lang) + "_" + strings.ToUpper(region)
+}
+
+// lookupLocale returns formatting data for a locale. A bare language such as
+// "fr" uses its default region; other regions are rejected rather than
+// borrowing that data, since "de_CH" or "fr_CA" format numbers and dates
+// differently from "de_DE" and "fr_FR".
+func lookupLocale(tag string) (*localeData, error) {
+	if strings.TrimSpace(tag) == "" {
+		return locales[defaultLocale], nil
+	}
+	normalized := normalizeLocale(tag)
+	if data, ok := locales[normalized]; ok {
+		return data, nil
+	}
+	if fallback, ok := languageFallbacks[normalized]; ok {
+		return locales[fallback], nil
+	}
+	return nil, fmt.Errorf("unsupported locale %q", tag)
+}
+
+// isKnownLocale reports whether lookupLocale can resolve a locale tag.
+func isKnownLocale(tag string) bool {
+	_, err := lookupLocale(tag)
+	return err == nil
+}
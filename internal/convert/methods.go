@@ -0,0 +1,333 @@
+package convert
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MethodFunc implements a Java method called from a template on receiver,
+// for data that does not carry the method itself (JSON maps, slices and
+// strings).
+type MethodFunc func(receiver any, args ...any) (any, error)
+
+// defaultMethods covers the non-bean Java methods templates commonly call on
+// collections and strings. FuncMapOptions.Methods extends or overrides it.
+var defaultMethods = map[string]MethodFunc{
+	"size":        javaSize,
+	"length":      javaSize,
+	"isEmpty":     javaIsEmpty,
+	"toString":    javaToString,
+	"equals":      javaEquals,
+	"contains":    javaContains,
+	"containsKey": javaContainsKey,
+	"get":         javaGet,
+	"toUpperCase": stringMethod(func(s string, _ string) any { return strings.ToUpper(s) }, 0),
+	"toLowerCase": stringMethod(func(s string, _ string) any { return strings.ToLower(s) }, 0),
+	"trim":        stringMethod(func(s string, _ string) any { return strings.TrimSpace(s) }, 0),
+	"startsWith":  stringMethod(func(s string, arg string) any { return strings.HasPrefix(s, arg) }, 1),
+	"endsWith":    stringMethod(func(s string, arg string) any { return strings.HasSuffix(s, arg) }, 1),
+}
+
+// methodCaller resolves template method calls against a method table.
+type methodCaller struct {
+	table map[string]MethodFunc
+}
+
+func newMethodCaller(overrides map[string]MethodFunc) methodCaller {
+	table := make(map[string]MethodFunc, len(defaultMethods)+len(overrides))
+	for name, fn := range defaultMethods {
+		table[name] = fn
+	}
+	for name, fn := range overrides {
+		table[name] = fn
+	}
+	return methodCaller{table: table}
+}
+
+// call implements receiver.name(args...). A Go method on the receiver wins,
+// under the Java name or the bean property name ("GetFullName" or
+// "FullName" for getFullName); then the method table applies; finally a
+// getter reads the bean property as a struct field or map key, so
+// getFullName() and isPaid() read "fullName" and "paid".
+func (c methodCaller) call(receiver any, name string, args ...any) (any, error) {
+	if isNilLike(receiver) {
+		return nil, fmt.Errorf("can't call %s() on a missing value", name)
+	}
+	property, isGetter := beanProperty(name)
+	candidates := []string{exportedName(name)}
+	if isGetter {
+		candidates = append(candidates, exportedName(property))
+	}
+	for _, candidate := range candidates {
+		if method, ok := findMethod(receiver, candidate); ok {
+			return callMethodValue(method, name, args)
+		}
+	}
+	if fn, ok := c.table[name]; ok {
+		return fn(indirect(receiver), args...)
+	}
+	if isGetter && len(args) == 0 {
+		if value, ok := lookupProperty(indirect(receiver), property); ok {
+			return value, nil
+		}
+	}
+	return nil, fmt.Errorf("%s has no method %s() and no matching bean property", valueTypeName(receiver), name)
+}
+
+// beanProperty returns the property a JavaBeans getter reads: getFullName
+// and isPaid read fullName and paid, and getURL reads URL as
+// java.beans.Introspector decapitalizes it.
+func beanProperty(name string) (string, bool) {
+	for _, prefix := range []string{"get", "is"} {
+		rest, ok := strings.CutPrefix(name, prefix)
+		if !ok || rest == "" {
+			continue
+		}
+		first, size := utf8.DecodeRuneInString(rest)
+		if !unicode.IsUpper(first) {
+			continue
+		}
+		if second, _ := utf8.DecodeRuneInString(rest[size:]); len(rest) > size && unicode.IsUpper(second) {
+			return rest, true
+		}
+		return string(unicode.ToLower(first)) + rest[size:], true
+	}
+	return "", false
+}
+
+// exportedName upper-cases the first letter of a Java name for Go lookups.
+func exportedName(name string) string {
+	first, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(first)) + name[size:]
+}
+
+// findMethod looks a method up on v, or on its pointer or pointee.
+func findMethod(v any, name string) (reflect.Value, bool) {
+	rv := reflect.ValueOf(v)
+	if method := rv.MethodByName(name); method.IsValid() {
+		return method, true
+	}
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		if method := rv.Elem().MethodByName(name); method.IsValid() {
+			return method, true
+		}
+	} else if rv.Kind() != reflect.Pointer {
+		ptr := reflect.New(rv.Type())
+		ptr.Elem().Set(rv)
+		if method := ptr.MethodByName(name); method.IsValid() {
+			return method, true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// callMethodValue calls a Go method with template arguments, converting
+// numbers to the integer or float parameter types the method declares.
+func callMethodValue(method reflect.Value, name string, args []any) (any, error) {
+	mt := method.Type()
+	if (!mt.IsVariadic() && len(args) != mt.NumIn()) || (mt.IsVariadic() && len(args) < mt.NumIn()-1) {
+		return nil, fmt.Errorf("%s() expects %d arguments, got %d", name, mt.NumIn(), len(args))
+	}
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		want := mt.In(min(i, mt.NumIn()-1))
+		if mt.IsVariadic() && i >= mt.NumIn()-1 {
+			want = want.Elem()
+		}
+		value, err := methodArgument(arg, want)
+		if err != nil {
+			return nil, fmt.Errorf("%s() argument %d: %w", name, i+1, err)
+		}
+		in[i] = value
+	}
+	out := method.Call(in)
+	switch {
+	case len(out) == 0:
+		return nil, nil
+	case len(out) == 2 && mt.Out(1) == reflect.TypeFor[error]():
+		if !out[1].IsNil() {
+			return nil, out[1].Interface().(error)
+		}
+	case len(out) > 1:
+		return nil, fmt.Errorf("%s() returns %d values", name, len(out))
+	}
+	return out[0].Interface(), nil
+}
+
+func methodArgument(arg any, want reflect.Type) (reflect.Value, error) {
+	if arg == nil {
+		switch want.Kind() {
+		case reflect.Interface, reflect.Pointer, reflect.Map, reflect.Slice:
+			return reflect.Zero(want), nil
+		}
+		return reflect.Value{}, fmt.Errorf("missing value for %s parameter", want)
+	}
+	value := reflect.ValueOf(arg)
+	if value.Type().AssignableTo(want) {
+		return value, nil
+	}
+	switch want.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := toInt(arg)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(i).Convert(want), nil
+	case reflect.Float32, reflect.Float64:
+		d, err := toDecimal(arg)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(d.Float64()).Convert(want), nil
+	}
+	return reflect.Value{}, fmt.Errorf("%s is not assignable to %s", valueTypeName(arg), want)
+}
+
+// lookupProperty reads a bean property from a map key or an exported field.
+func lookupProperty(v any, property string) (any, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		key, ok := mapKeyFrom(property, rv.Type().Key())
+		if !ok {
+			return nil, false
+		}
+		value := rv.MapIndex(key)
+		if !value.IsValid() {
+			return nil, false
+		}
+		return value.Interface(), true
+	case reflect.Struct:
+		field := rv.FieldByName(exportedName(property))
+		if !field.IsValid() || !field.CanInterface() {
+			return nil, false
+		}
+		return field.Interface(), true
+	}
+	return nil, false
+}
+
+func javaSize(receiver any, args ...any) (any, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("size() expects no arguments")
+	}
+	if s, ok := textValue(receiver); ok {
+		return int64(utf8.RuneCountInString(s)), nil
+	}
+	rv := reflect.ValueOf(receiver)
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice:
+		return int64(rv.Len()), nil
+	}
+	return nil, fmt.Errorf("size() is not supported on %s", valueTypeName(receiver))
+}
+
+func javaIsEmpty(receiver any, args ...any) (any, error) {
+	n, err := javaSize(receiver, args...)
+	if err != nil {
+		return nil, err
+	}
+	return n.(int64) == 0, nil
+}
+
+func javaToString(receiver any, args ...any) (any, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("toString() expects no arguments")
+	}
+	if isNumeric(receiver) {
+		return computerString(receiver)
+	}
+	return fmt.Sprint(receiver), nil
+}
+
+// javaEquals follows Object.equals, which is false rather than an error for
+// values of unrelated types.
+func javaEquals(receiver any, args ...any) (any, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("equals() expects one argument")
+	}
+	order, err := compareValues("==", receiver, args[0])
+	return err == nil && order == 0, nil
+}
+
+func javaContains(receiver any, args ...any) (any, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("contains() expects one argument")
+	}
+	if s, ok := textValue(receiver); ok {
+		needle, err := strictString(args[0], "contains() argument")
+		if err != nil {
+			return nil, err
+		}
+		return strings.Contains(s, needle), nil
+	}
+	rv := reflect.ValueOf(receiver)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("contains() is not supported on %s", valueTypeName(receiver))
+	}
+	for i := 0; i < rv.Len(); i++ {
+		if equal, _ := javaEquals(rv.Index(i).Interface(), args[0]); equal.(bool) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func javaContainsKey(receiver any, args ...any) (any, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("containsKey() expects one argument")
+	}
+	rv := reflect.ValueOf(receiver)
+	if rv.Kind() != reflect.Map {
+		return nil, fmt.Errorf("containsKey() is not supported on %s", valueTypeName(receiver))
+	}
+	key, ok := mapKeyFrom(args[0], rv.Type().Key())
+	return ok && rv.MapIndex(key).IsValid(), nil
+}
+
+func javaGet(receiver any, args ...any) (any, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("get() expects one argument")
+	}
+	rv := reflect.ValueOf(receiver)
+	switch rv.Kind() {
+	case reflect.Map:
+		return safeAccess(receiver, args[0]), nil
+	case reflect.Slice, reflect.Array:
+		i, err := toInt(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("get() index: %w", err)
+		}
+		if i < 0 || i >= rv.Len() {
+			return nil, fmt.Errorf("get() index %d is out of bounds for length %d", i, rv.Len())
+		}
+		return rv.Index(i).Interface(), nil
+	}
+	return nil, fmt.Errorf("get() is not supported on %s", valueTypeName(receiver))
+}
+
+// stringMethod adapts a string operation taking arity (0 or 1) string
+// arguments to a MethodFunc.
+func stringMethod(fn func(s string, arg string) any, arity int) MethodFunc {
+	return func(receiver any, args ...any) (any, error) {
+		s, ok := textValue(receiver)
+		if !ok {
+			return nil, fmt.Errorf("method requires a string receiver, got %s", valueTypeName(receiver))
+		}
+		if len(args) != arity {
+			return nil, fmt.Errorf("method expects %d arguments, got %d", arity, len(args))
+		}
+		arg := ""
+		if arity == 1 {
+			text, err := strictString(args[0], "method argument")
+			if err != nil {
+				return nil, err
+			}
+			arg = text
+		}
+		return fn(s, arg), nil
+	}
+}
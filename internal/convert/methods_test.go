@@ -0,0 +1,102 @@
+package convert
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type beanUser struct {
+	First, Last string
+	Admin       bool
+}
+
+func (u beanUser) GetFullName() string { return u.First + " " + u.Last }
+
+func (u *beanUser) Initials(sep string) string { return u.First[:1] + sep + u.Last[:1] }
+
+func TestMapExprMethodCalls(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{`user.getFullName()`, `callMethod .user "getFullName"`},
+		{`order.isPaid()`, `callMethod .order "isPaid"`},
+		{`items.get(i + 1)`, `callMethod .items "get" (add .i 1)`},
+		{`user.getAddress().getCity()`, `callMethod (callMethod .user "getAddress") "getCity"`},
+		{`list.size() > 2`, `greaterThan (callMethod .list "size") 2`},
+		{`name.toUpperCase()?trim`, `trim (callMethod .name "toUpperCase")`},
+		{`name?trim.toLowerCase()`, `callMethod (trim .name) "toLowerCase"`},
+	}
+	for _, tc := range tests {
+		m := newExpressionMapper(map[string]struct{}{})
+		got, err := m.mapExpr(tc.expr)
+		require.NoError(t, err, tc.expr)
+		require.Equal(t, tc.want, got, tc.expr)
+		require.Contains(t, m.helperList(), "callMethod", tc.expr)
+	}
+
+	m := newExpressionMapper(map[string]struct{}{})
+	_, err := m.mapExpr(`lookup(user)`)
+	require.ErrorContains(t, err, `unsupported function call "lookup"`)
+}
+
+func TestCallMethod(t *testing.T) {
+	call := StubFuncMap()["callMethod"].(func(any, string, ...any) (any, error))
+	user := beanUser{First: "Ada", Last: "Lovelace", Admin: true}
+	data := map[string]any{"fullName": "Grace Hopper", "paid": false, "URL": "https://x", "items": []any{"a", "b"}}
+
+	tests := []struct {
+		name     string
+		receiver any
+		method   string
+		args     []any
+		want     any
+	}{
+		{"go method", user, "getFullName", nil, "Ada Lovelace"},
+		{"pointer method", user, "initials", []any{"."}, "A.L"},
+		{"struct field getter", user, "isAdmin", nil, true},
+		{"map getter", data, "getFullName", nil, "Grace Hopper"},
+		{"map is getter", data, "isPaid", nil, false},
+		{"acronym getter", data, "getURL", nil, "https://x"},
+		{"size", data["items"], "size", nil, int64(2)},
+		{"string length", "héllo", "length", nil, int64(5)},
+		{"isEmpty", []any{}, "isEmpty", nil, true},
+		{"get index", data["items"], "get", []any{int64(1)}, "b"},
+		{"containsKey", data, "containsKey", []any{"paid"}, true},
+		{"contains", data["items"], "contains", []any{"a"}, true},
+		{"equals across types", int64(1), "equals", []any{"1"}, false},
+		{"toString", 1.5, "toString", nil, "1.5"},
+		{"toUpperCase", "abc", "toUpperCase", nil, "ABC"},
+	}
+	for _, tc := range tests {
+		got, err := call(tc.receiver, tc.method, tc.args...)
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, tc.want, got, tc.name)
+	}
+
+	_, err := call(data, "getMissing")
+	assert.ErrorContains(t, err, "no method getMissing()")
+	_, err = call(nil, "size")
+	assert.ErrorContains(t, err, "missing value")
+	_, err = call(data["items"], "get", int64(5))
+	assert.ErrorContains(t, err, "out of bounds")
+}
+
+func TestCallMethodTableOverrides(t *testing.T) {
+	funcs := NewFuncMap(FuncMapOptions{Methods: map[string]MethodFunc{
+		"getTotal": func(receiver any, args ...any) (any, error) {
+			return fmt.Sprintf("total of %v", receiver), nil
+		},
+		"size": func(any, ...any) (any, error) { return int64(-1), nil },
+	}})
+	tmpl := template.Must(template.New("m").Funcs(funcs).Parse(
+		`{{callMethod .order "getTotal"}} {{callMethod .items "size"}} {{callMethod .items "isEmpty"}}`))
+	var out bytes.Buffer
+	require.NoError(t, tmpl.Execute(&out, map[string]any{"order": "o1", "items": []any{}}))
+	assert.Equal(t, "total of o1 -1 true", out.String())
+}
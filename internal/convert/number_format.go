@@ -0,0 +1,497 @@
+package convert
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/cruffinoni/ftl2gotpl/internal/decimal"
+)
+
+// decimalFormat is a java.text.DecimalFormat pattern bound to a locale.
+//
+// Rounding is HALF_EVEN on the exact value of the number, which is what
+// FreeMarker gets from DecimalFormat's default rounding mode.
+type decimalFormat struct {
+	posPrefix    string
+	posSuffix    string
+	negPrefix    string
+	negSuffix    string
+	multiplier   int64
+	minInt       int
+	maxInt       int
+	minFrac      int
+	maxFrac      int
+	groupingSize int
+	alwaysDot    bool
+	scientific   bool
+	minExpDigits int
+	loc          *localeData
+}
+
+// decimalSubpattern is one side of a "positive;negative" pattern.
+type decimalSubpattern struct {
+	prefix     string
+	suffix     string
+	multiplier int64
+	integer    string
+	fraction   string
+	hasDecimal bool
+	expDigits  int
+}
+
+// parseDecimalFormat parses a DecimalFormat pattern, resolving the percent,
+// per-mille, currency and minus symbols against loc.
+func parseDecimalFormat(pattern string, loc *localeData) (decimalFormat, error) {
+	subpatterns, err := splitDecimalSubpatterns(pattern)
+	if err != nil {
+		return decimalFormat{}, err
+	}
+	positive, err := parseDecimalSubpattern(pattern, subpatterns[0], loc)
+	if err != nil {
+		return decimalFormat{}, err
+	}
+
+	f := decimalFormat{
+		posPrefix:  positive.prefix,
+		posSuffix:  positive.suffix,
+		negPrefix:  loc.minus + positive.prefix,
+		negSuffix:  positive.suffix,
+		multiplier: positive.multiplier,
+		loc:        loc,
+	}
+	if len(subpatterns) == 2 {
+		negative, err := parseDecimalSubpattern(pattern, subpatterns[1], loc)
+		if err != nil {
+			return decimalFormat{}, err
+		}
+		f.negPrefix = negative.prefix
+		f.negSuffix = negative.suffix
+	}
+
+	integer := positive.integer
+	if comma := strings.LastIndexByte(integer, ','); comma >= 0 {
+		f.groupingSize = len(integer) - comma - 1
+		if f.groupingSize == 0 {
+			return decimalFormat{}, fmt.Errorf("numeric format pattern %q has an empty grouping", pattern)
+		}
+		if positive.expDigits > 0 {
+			return decimalFormat{}, fmt.Errorf("numeric format pattern %q mixes grouping and an exponent", pattern)
+		}
+		integer = strings.ReplaceAll(integer, ",", "")
+	}
+	if strings.Contains(integer, "0") && strings.Contains(integer[strings.IndexByte(integer, '0'):], "#") {
+		return decimalFormat{}, fmt.Errorf("unexpected '#' after '0' in numeric format pattern %q", pattern)
+	}
+	if strings.Contains(positive.fraction, "#") && strings.Contains(positive.fraction[strings.IndexByte(positive.fraction, '#'):], "0") {
+		return decimalFormat{}, fmt.Errorf("unexpected '0' after '#' in numeric format pattern %q", pattern)
+	}
+
+	f.minInt = strings.Count(integer, "0")
+	f.maxInt = math.MaxInt32
+	f.minFrac = strings.Count(positive.fraction, "0")
+	f.maxFrac = len(positive.fraction)
+	f.alwaysDot = positive.hasDecimal && positive.fraction == ""
+	if positive.expDigits > 0 {
+		f.scientific = true
+		f.minExpDigits = positive.expDigits
+		f.maxInt = len(integer)
+	}
+	return f, nil
+}
+
+// splitDecimalSubpatterns splits a pattern on its unquoted ';'.
+func splitDecimalSubpatterns(pattern string) ([]string, error) {
+	var parts []string
+	inQuote := false
+	start := 0
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\'':
+			inQuote = !inQuote
+		case ';':
+			if !inQuote {
+				parts = append(parts, pattern[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if inQuote {
+		return nil, fmt.Errorf("unterminated quoted literal in pattern %q", pattern)
+	}
+	parts = append(parts, pattern[start:])
+	if len(parts) > 2 {
+		return nil, fmt.Errorf("numeric format pattern %q has more than one ';'", pattern)
+	}
+	return parts, nil
+}
+
+// parseDecimalSubpattern reads the prefix, number and suffix of a subpattern.
+func parseDecimalSubpattern(pattern string, sub string, loc *localeData) (decimalSubpattern, error) {
+	out := decimalSubpattern{multiplier: 1}
+	runes := []rune(sub)
+
+	const (
+		inPrefix = iota
+		inNumber
+		inSuffix
+	)
+	phase := inPrefix
+	var affix, integer, fraction strings.Builder
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		if phase == inNumber {
+			switch {
+			case r == '0' || r == '#' || (r == ',' && !out.hasDecimal):
+				if out.hasDecimal {
+					fraction.WriteRune(r)
+				} else {
+					integer.WriteRune(r)
+				}
+				i++
+				continue
+			case r == '.' && !out.hasDecimal:
+				out.hasDecimal = true
+				i++
+				continue
+			case r == 'E':
+				i++
+				for i < len(runes) && runes[i] == '0' {
+					out.expDigits++
+					i++
+				}
+				if out.expDigits == 0 {
+					return decimalSubpattern{}, fmt.Errorf("exponent in numeric format pattern %q needs at least one '0'", pattern)
+				}
+			}
+			out.prefix = affix.String()
+			affix.Reset()
+			phase = inSuffix
+			continue
+		}
+
+		switch r {
+		case '0', '#', ',', '.':
+			if phase == inSuffix {
+				return decimalSubpattern{}, fmt.Errorf("unquoted special character %q in numeric format pattern %q", r, pattern)
+			}
+			phase = inNumber
+			continue
+		case '\'':
+			i++
+			if i < len(runes) && runes[i] == '\'' {
+				affix.WriteRune('\'')
+				i++
+				continue
+			}
+			for i < len(runes) {
+				if runes[i] == '\'' {
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						affix.WriteRune('\'')
+						i += 2
+						continue
+					}
+					break
+				}
+				affix.WriteRune(runes[i])
+				i++
+			}
+			if i >= len(runes) {
+				return decimalSubpattern{}, fmt.Errorf("unterminated quoted literal in pattern %q", pattern)
+			}
+		case '%', '‰':
+			if out.multiplier != 1 {
+				return decimalSubpattern{}, fmt.Errorf("numeric format pattern %q has more than one percent or per-mille sign", pattern)
+			}
+			if r == '%' {
+				out.multiplier = 100
+				affix.WriteString(loc.percent)
+			} else {
+				out.multiplier = 1000
+				affix.WriteString(loc.perMille)
+			}
+		case '¤':
+			if i+1 < len(runes) && runes[i+1] == '¤' {
+				affix.WriteString(loc.currencyCode)
+				i++
+			} else {
+				affix.WriteString(loc.currencySymbol)
+			}
+		case '-':
+			affix.WriteString(loc.minus)
+		default:
+			affix.WriteRune(r)
+		}
+		i++
+	}
+
+	switch phase {
+	case inPrefix:
+		return decimalSubpattern{}, fmt.Errorf("numeric format pattern %q has no digits", pattern)
+	case inNumber:
+		out.prefix = affix.String()
+	default:
+		out.suffix = affix.String()
+	}
+	out.integer = integer.String()
+	out.fraction = fraction.String()
+	if out.integer == "" && out.fraction == "" {
+		return decimalSubpattern{}, fmt.Errorf("numeric format pattern %q has no digits", pattern)
+	}
+	return out, nil
+}
+
+// format renders an int64, float64 or decimal.Decimal with the pattern.
+func (f decimalFormat) format(n any) (string, error) {
+	switch t := n.(type) {
+	case decimal.Decimal:
+		return f.formatRat(t.Mul(decimal.FromInt64(f.multiplier)).Rat(), t.Sign() < 0), nil
+	case int64:
+		r := new(big.Rat).SetInt64(t)
+		r.Mul(r, new(big.Rat).SetInt64(f.multiplier))
+		return f.formatRat(r, t < 0), nil
+	case float64:
+		negative := math.Signbit(t)
+		if math.IsNaN(t) {
+			return "NaN", nil
+		}
+		// DecimalFormat scales doubles by the multiplier in floating point
+		// before rounding, so 0.0125 with "0‰" prints "12‰".
+		t *= float64(f.multiplier)
+		if math.IsInf(t, 0) {
+			return f.affixed("∞", negative), nil
+		}
+		return f.formatRat(new(big.Rat).SetFloat64(t), negative), nil
+	default:
+		return "", fmt.Errorf("numeric format requires a numeric value")
+	}
+}
+
+// formatRat renders an already scaled exact value; negative is passed
+// separately so that negative zero keeps its sign the way DecimalFormat
+// prints "-0".
+func (f decimalFormat) formatRat(r *big.Rat, negative bool) string {
+	r = new(big.Rat).Abs(r)
+	if f.scientific {
+		return f.affixed(f.scientificDigits(r), negative)
+	}
+	return f.affixed(f.fixedDigits(r), negative)
+}
+
+func (f decimalFormat) affixed(body string, negative bool) string {
+	if negative {
+		return f.negPrefix + body + f.negSuffix
+	}
+	return f.posPrefix + body + f.posSuffix
+}
+
+// fixedDigits renders the digits of a non-scientific pattern.
+func (f decimalFormat) fixedDigits(r *big.Rat) string {
+	q := roundHalfEven(new(big.Rat).Mul(r, pow10Rat(f.maxFrac)))
+	digits := q.String()
+	for len(digits) < f.maxFrac+1 {
+		digits = "0" + digits
+	}
+	intPart := strings.TrimLeft(digits[:len(digits)-f.maxFrac], "0")
+	fracPart := digits[len(digits)-f.maxFrac:]
+	for len(fracPart) > f.minFrac && strings.HasSuffix(fracPart, "0") {
+		fracPart = fracPart[:len(fracPart)-1]
+	}
+	for len(intPart) < f.minInt {
+		intPart = "0" + intPart
+	}
+	if intPart == "" && fracPart == "" {
+		intPart = "0"
+	}
+	if f.groupingSize > 0 {
+		intPart = groupDigits(intPart, f.groupingSize, f.loc.group)
+	}
+	if fracPart == "" && !f.alwaysDot {
+		return intPart
+	}
+	return intPart + f.loc.decimal + fracPart
+}
+
+// scientificDigits renders the mantissa and exponent of an "E" pattern,
+// following DecimalFormat: when the maximum integer digits exceed the minimum
+// the exponent is a multiple of the maximum (engineering notation), otherwise
+// the mantissa shows exactly the minimum integer digits.
+func (f decimalFormat) scientificDigits(r *big.Rat) string {
+	digits, decimalAt := significantDigits(r, f.maxInt+f.maxFrac)
+
+	minIntDigits := f.minInt
+	exponent := 0
+	if digits != "" {
+		exponent = decimalAt
+		if f.maxInt > 1 && f.maxInt > f.minInt {
+			if exponent >= 1 {
+				exponent = ((exponent - 1) / f.maxInt) * f.maxInt
+			} else {
+				exponent = ((exponent - f.maxInt) / f.maxInt) * f.maxInt
+			}
+			minIntDigits = 1
+		} else {
+			exponent -= minIntDigits
+		}
+	}
+
+	integerDigits := minIntDigits
+	if digits != "" {
+		integerDigits = decimalAt - exponent
+	}
+	minimumDigits := f.minInt + f.minFrac
+	if minimumDigits < integerDigits {
+		minimumDigits = integerDigits
+	}
+	totalDigits := len(digits)
+	if totalDigits < minimumDigits {
+		totalDigits = minimumDigits
+	}
+
+	var b strings.Builder
+	for i := 0; i < totalDigits; i++ {
+		if i == integerDigits {
+			b.WriteString(f.loc.decimal)
+		}
+		if i < len(digits) {
+			b.WriteByte(digits[i])
+		} else {
+			b.WriteByte('0')
+		}
+	}
+	if b.Len() == 0 {
+		b.WriteByte('0')
+	}
+
+	b.WriteString(f.loc.exponent)
+	if exponent < 0 {
+		b.WriteString(f.loc.minus)
+		exponent = -exponent
+	}
+	b.WriteString(padNumber(exponent, f.minExpDigits))
+	return b.String()
+}
+
+// significantDigits rounds r to at most n significant digits and returns them
+// without trailing zeros, with decimalAt such that r = 0.digits × 10^decimalAt.
+// A zero value returns no digits.
+func significantDigits(r *big.Rat, n int) (string, int) {
+	if r.Sign() == 0 {
+		return "", 0
+	}
+	decimalAt := 0
+	if whole := new(big.Int).Quo(r.Num(), r.Denom()); whole.Sign() > 0 {
+		decimalAt = len(whole.String())
+	} else {
+		one := big.NewRat(1, 1)
+		for new(big.Rat).Mul(r, pow10Rat(1-decimalAt)).Cmp(one) < 0 {
+			decimalAt--
+		}
+	}
+
+	q := roundHalfEven(new(big.Rat).Mul(r, pow10Rat(n-decimalAt)))
+	digits := q.String()
+	if len(digits) > n {
+		decimalAt++
+		digits = digits[:n]
+	}
+	return strings.TrimRight(digits, "0"), decimalAt
+}
+
+// roundHalfEven rounds a non-negative rational to the nearest integer, with
+// ties going to the even neighbour.
+func roundHalfEven(r *big.Rat) *big.Int {
+	q, rem := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	twice := rem.Lsh(rem, 1)
+	switch twice.Cmp(r.Denom()) {
+	case 1:
+		q.Add(q, big.NewInt(1))
+	case 0:
+		if q.Bit(0) == 1 {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q
+}
+
+// pow10Rat returns 10^exp for positive and negative exponents.
+func pow10Rat(exp int) *big.Rat {
+	if exp >= 0 {
+		return new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil))
+	}
+	return new(big.Rat).SetFrac(big.NewInt(1), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-exp)), nil))
+}
+
+// groupDigits inserts sep every size digits from the right.
+func groupDigits(s string, size int, sep string) string {
+	if len(s) <= size {
+		return s
+	}
+	head := len(s) % size
+	if head == 0 {
+		head = size
+	}
+	var b strings.Builder
+	b.WriteString(s[:head])
+	for i := head; i < len(s); i += size {
+		b.WriteString(sep)
+		b.WriteString(s[i : i+size])
+	}
+	return b.String()
+}
+
+// maxNumericFractionDigits is the most fraction digits a #{expr; mXMY}
+// interpolation may request, and the default maximum when none is given.
+const maxNumericFractionDigits = 50
+
+// parseNumericFormat parses the "mXMY" suffix of a #{expr; mXMY}
+// interpolation. A lone mX also caps the fraction at X digits, and a lone MY
+// leaves the minimum at zero.
+func parseNumericFormat(spec string) (int, int, error) {
+	spec = strings.TrimSpace(spec)
+	minFrac, maxFrac := -1, -1
+	for i := 0; i < len(spec); {
+		kind := spec[i]
+		if kind != 'm' && kind != 'M' {
+			return 0, 0, fmt.Errorf("invalid numeric format %q", spec)
+		}
+		j := i + 1
+		for j < len(spec) && spec[j] >= '0' && spec[j] <= '9' {
+			j++
+		}
+		if j == i+1 {
+			return 0, 0, fmt.Errorf("invalid numeric format %q", spec)
+		}
+		digits, err := strconv.Atoi(spec[i+1 : j])
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid numeric format %q", spec)
+		}
+		target := &minFrac
+		if kind == 'M' {
+			target = &maxFrac
+		}
+		if *target != -1 {
+			return 0, 0, fmt.Errorf("invalid numeric format %q: %c is given twice", spec, kind)
+		}
+		*target = digits
+		i = j
+	}
+	switch {
+	case minFrac == -1 && maxFrac == -1:
+		return 0, 0, fmt.Errorf("invalid numeric format %q: at least one of m and M must be specified", spec)
+	case maxFrac == -1:
+		maxFrac = minFrac
+	case minFrac == -1:
+		minFrac = 0
+	}
+	if minFrac > maxFrac {
+		return 0, 0, fmt.Errorf("invalid numeric format %q: m cannot be greater than M", spec)
+	}
+	if maxFrac > maxNumericFractionDigits {
+		return 0, 0, fmt.Errorf("invalid numeric format %q: at most %d fraction digits are allowed", spec, maxNumericFractionDigits)
+	}
+	return minFrac, maxFrac, nil
+}
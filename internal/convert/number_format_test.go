@@ -0,0 +1,96 @@
+package convert
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecimalFormatMatchesJava(t *testing.T) {
+	tests := []struct {
+		pattern string
+		locale  string
+		in      any
+		want    string
+	}{
+		{pattern: "#,##0.###", in: int64(1234567), want: "1,234,567"},
+		{pattern: "#,##0.###", in: 1234.5678, want: "1,234.568"},
+		{pattern: "#,##0.00", in: -1234.5, want: "-1,234.50"},
+		{pattern: "#,##0.00;(#,##0.00)", in: -1234.5, want: "(1,234.50)"},
+		{pattern: "#,##0.00;(#,##0.00)", in: 1234.5, want: "1,234.50"},
+		{pattern: "0.#%", in: 0.256, want: "25.6%"},
+		{pattern: "0%", in: 0.125, want: "12%"},
+		{pattern: "0‰", in: 0.0125, want: "12‰"},
+		{pattern: "0.###E0", in: int64(12345), want: "1.234E4"},
+		{pattern: "0.###E0", in: 0.00123, want: "1.23E-3"},
+		{pattern: "00.###E0", in: int64(12345), want: "12.345E3"},
+		{pattern: "##0.##E0", in: int64(12345), want: "12.345E3"},
+		{pattern: "##0.##E0", in: 0.00012345, want: "123.45E-6"},
+		{pattern: "0.0E00", in: int64(0), want: "0.0E00"},
+		{pattern: "¤#,##0.00", in: 1234.5, want: "$1,234.50"},
+		{pattern: "¤¤ #,##0.00", in: 1234.5, want: "USD 1,234.50"},
+		{pattern: "#,##0.00 ¤", locale: "fr_FR", in: 1234.5, want: "1\u202f234,50 €"},
+		{pattern: "'#'0", in: int64(7), want: "#7"},
+		{pattern: "0%", in: int64(-3), want: "-300%"},
+		{pattern: "0 o''clock", in: int64(5), want: "5 o'clock"},
+		{pattern: "0.00", in: 0.125, want: "0.12"},
+		{pattern: "0.00", in: 0.135, want: "0.14"},
+		{pattern: "0.00", in: 1.005, want: "1.00"},
+		{pattern: "0", in: 2.5, want: "2"},
+		{pattern: "0", in: 3.5, want: "4"},
+		{pattern: "0.00", in: -0.001, want: "-0.00"},
+		{pattern: "#.##", in: 0.5, want: ".5"},
+		{pattern: "#.##", in: int64(0), want: "0"},
+		{pattern: "000", in: int64(7), want: "007"},
+		{pattern: "#,##,##0", in: int64(1234567), want: "1,234,567"},
+		{pattern: "#,####", in: int64(123456789), want: "1,2345,6789"},
+		{pattern: "#,##0.", in: int64(12), want: "12."},
+		{pattern: "0.00", in: 9.999, want: "10.00"},
+		{pattern: "0.#", in: math.Inf(-1), want: "-∞"},
+	}
+
+	for _, tc := range tests {
+		locale := tc.locale
+		if locale == "" {
+			locale = defaultLocale
+		}
+		loc, err := lookupLocale(locale)
+		require.NoError(t, err)
+		f, err := parseDecimalFormat(tc.pattern, loc)
+		require.NoError(t, err, tc.pattern)
+		got, err := f.format(tc.in)
+		require.NoError(t, err, tc.pattern)
+		assert.Equal(t, tc.want, got, "%s with %v", tc.pattern, tc.in)
+	}
+}
+
+func TestDecimalFormatRejectsInvalidPatterns(t *testing.T) {
+	for _, pattern := range []string{"", "%", "0.0.0", "#,##0.0#0", "0#", "0.###E", "0;0;0", "'0", "0 0", "#,##0E0", "#,"} {
+		_, err := parseDecimalFormat(pattern, locales[defaultLocale])
+		assert.Error(t, err, pattern)
+	}
+}
+
+func TestParseNumericFormat(t *testing.T) {
+	tests := map[string][2]int{
+		"M2":    {0, 2},
+		"m2":    {2, 2},
+		"m1M2":  {1, 2},
+		"M3m1":  {1, 3},
+		" m0 ":  {0, 0},
+		"M50":   {0, 50},
+		"m2M10": {2, 10},
+	}
+	for spec, want := range tests {
+		minFrac, maxFrac, err := parseNumericFormat(spec)
+		require.NoError(t, err, spec)
+		assert.Equal(t, want, [2]int{minFrac, maxFrac}, spec)
+	}
+
+	for _, spec := range []string{"", "m", "x2", "m2m3", "m3M2", "M51", "m1 M2"} {
+		_, _, err := parseNumericFormat(spec)
+		assert.Error(t, err, spec)
+	}
+}
@@ -0,0 +1,669 @@
+package convert
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// regexError reports a Java regular expression construct RE2 cannot express,
+// such as lookaround or a backreference.
+type regexError struct {
+	pattern string
+	reason  string
+}
+
+func (e regexError) Error() string {
+	return fmt.Sprintf("regular expression %q: %s", e.pattern, e.reason)
+}
+
+// matchResultError reports a variable assigned from ?matches used as a list
+// source or a ?groups receiver. The variable holds the whole-string test,
+// so only a ?matches written in place can list matches or read groups.
+type matchResultError struct {
+	name string
+	use  string
+}
+
+func (e matchResultError) Error() string {
+	return fmt.Sprintf("variable %q holds a ?matches result, which cannot be used as %s; apply ?matches where the matches are used", e.name, e.use)
+}
+
+// posixClasses maps Java's \p{Name} POSIX classes to RE2 ASCII classes.
+var posixClasses = map[string]string{
+	"Lower": "lower", "Upper": "upper", "ASCII": "ascii", "Alpha": "alpha",
+	"Digit": "digit", "Alnum": "alnum", "Punct": "punct", "Graph": "graph",
+	"Print": "print", "Blank": "blank", "Cntrl": "cntrl", "XDigit": "xdigit",
+	"Space": "space",
+}
+
+// translateJavaRegex rewrites a java.util.regex pattern into RE2 syntax.
+// Named groups (?<name>...) become (?P<name>...), Java-only inline flags are
+// dropped or applied (x strips whitespace and comments), and escapes RE2
+// lacks (\uXXXX, \0oct, \cX, \e, \h, \R, POSIX \p{Alpha}) are spelled out.
+// Lookaround, atomic groups, backreferences, possessive quantifiers and
+// class intersections have no RE2 equivalent and fail with a regexError.
+func translateJavaRegex(pattern string) (string, error) {
+	t := regexTranslator{src: pattern}
+	if err := t.run(); err != nil {
+		return "", err
+	}
+	return t.out.String(), nil
+}
+
+type regexTranslator struct {
+	src      string
+	i        int
+	out      strings.Builder
+	extended bool
+	inClass  int
+}
+
+func (t *regexTranslator) fail(format string, args ...any) error {
+	return regexError{pattern: t.src, reason: fmt.Sprintf(format, args...)}
+}
+
+func (t *regexTranslator) run() error {
+	for t.i < len(t.src) {
+		ch := t.src[t.i]
+		switch {
+		case ch == '\\':
+			if err := t.escape(); err != nil {
+				return err
+			}
+		case t.inClass > 0:
+			if err := t.classByte(ch); err != nil {
+				return err
+			}
+		case t.extended && (ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == '\f'):
+			t.i++
+		case t.extended && ch == '#':
+			for t.i < len(t.src) && t.src[t.i] != '\n' {
+				t.i++
+			}
+		case ch == '[':
+			t.inClass++
+			t.out.WriteByte(ch)
+			t.i++
+			if strings.HasPrefix(t.src[t.i:], "^") {
+				t.out.WriteByte('^')
+				t.i++
+			}
+			// A leading ] is a literal in both dialects.
+			if strings.HasPrefix(t.src[t.i:], "]") {
+				t.out.WriteString(`\]`)
+				t.i++
+			}
+		case ch == '(':
+			if err := t.group(); err != nil {
+				return err
+			}
+		case ch == '*' || ch == '+' || ch == '?' || ch == '}':
+			t.out.WriteByte(ch)
+			t.i++
+			if t.i < len(t.src) && t.src[t.i] == '+' {
+				return t.fail("possessive quantifiers are not supported by Go regular expressions")
+			}
+		default:
+			t.out.WriteByte(ch)
+			t.i++
+		}
+	}
+	if t.inClass > 0 {
+		return t.fail("unclosed character class")
+	}
+	return nil
+}
+
+// classByte copies one byte inside a character class.
+func (t *regexTranslator) classByte(ch byte) error {
+	switch {
+	case ch == ']':
+		t.inClass--
+	case ch == '[':
+		if strings.HasPrefix(t.src[t.i:], "[:") {
+			t.out.WriteString(`\[`)
+			t.i++
+			return nil
+		}
+		return t.fail("nested character classes are not supported by Go regular expressions")
+	case ch == '&' && strings.HasPrefix(t.src[t.i:], "&&"):
+		return t.fail("character class intersections are not supported by Go regular expressions")
+	}
+	t.out.WriteByte(ch)
+	t.i++
+	return nil
+}
+
+// group translates the opening of a group construct.
+func (t *regexTranslator) group() error {
+	rest := t.src[t.i:]
+	switch {
+	case !strings.HasPrefix(rest, "(?"):
+		t.out.WriteByte('(')
+		t.i++
+		return nil
+	case strings.HasPrefix(rest, "(?=") || strings.HasPrefix(rest, "(?!"),
+		strings.HasPrefix(rest, "(?<=") || strings.HasPrefix(rest, "(?<!"):
+		return t.fail("lookaround is not supported by Go regular expressions")
+	case strings.HasPrefix(rest, "(?>"):
+		return t.fail("atomic groups are not supported by Go regular expressions")
+	case strings.HasPrefix(rest, "(?<"):
+		end := strings.IndexByte(rest, '>')
+		if end < 0 {
+			return t.fail("unterminated group name")
+		}
+		t.out.WriteString("(?P<" + rest[3:end] + ">")
+		t.i += end + 1
+		return nil
+	case strings.HasPrefix(rest, "(?:"):
+		t.out.WriteString("(?:")
+		t.i += 3
+		return nil
+	}
+
+	// Inline flags: (?idmsuxU-idmsuxU) or (?flags:...).
+	j := 2
+	for j < len(rest) && strings.IndexByte("idmsuxU-", rest[j]) >= 0 {
+		j++
+	}
+	if j >= len(rest) || (rest[j] != ')' && rest[j] != ':') {
+		return t.fail("unsupported group construct %q", rest[:min(j+1, len(rest))])
+	}
+	var kept strings.Builder
+	negated := false
+	for _, flag := range rest[2:j] {
+		switch flag {
+		case '-':
+			negated = true
+			kept.WriteRune(flag)
+		case 'i', 'm', 's':
+			kept.WriteRune(flag)
+		case 'x':
+			t.extended = !negated
+		}
+		// d (UNIX_LINES), u (UNICODE_CASE) and U (UNICODE_CHARACTER_CLASS)
+		// have no RE2 counterpart; RE2's U means ungreedy, so never copy it.
+	}
+	flags := strings.TrimSuffix(kept.String(), "-")
+	if rest[j] == ':' {
+		if flags == "" {
+			t.out.WriteString("(?:")
+		} else {
+			t.out.WriteString("(?" + flags + ":")
+		}
+	} else if flags != "" {
+		t.out.WriteString("(?" + flags + ")")
+	}
+	t.i += j + 1
+	return nil
+}
+
+// escape translates one backslash escape.
+func (t *regexTranslator) escape() error {
+	if t.i+1 >= len(t.src) {
+		return t.fail("trailing backslash")
+	}
+	ch := t.src[t.i+1]
+	t.i += 2
+	switch ch {
+	case '1', '2', '3', '4', '5', '6', '7', '8', '9', 'k':
+		return t.fail("backreferences are not supported by Go regular expressions")
+	case 'G':
+		return t.fail(`\G is not supported by Go regular expressions`)
+	case 'Z':
+		return t.fail(`\Z is not supported by Go regular expressions; use \z or $`)
+	case 'Q':
+		literal, after, found := strings.Cut(t.src[t.i:], `\E`)
+		t.out.WriteString(regexp.QuoteMeta(literal))
+		t.i = len(t.src)
+		if found {
+			t.i -= len(after)
+		}
+	case 'e':
+		t.out.WriteString(`\x1B`)
+	case 'u':
+		if t.i+4 > len(t.src) {
+			return t.fail(`incomplete \u escape`)
+		}
+		if _, err := strconv.ParseUint(t.src[t.i:t.i+4], 16, 32); err != nil {
+			return t.fail(`invalid \u escape`)
+		}
+		t.out.WriteString(`\x{` + t.src[t.i:t.i+4] + `}`)
+		t.i += 4
+	case '0':
+		j := t.i
+		for j < len(t.src) && j < t.i+3 && t.src[j] >= '0' && t.src[j] <= '7' {
+			j++
+		}
+		if j == t.i {
+			return t.fail(`invalid octal escape \0`)
+		}
+		code, _ := strconv.ParseUint(t.src[t.i:j], 8, 32)
+		if code > 0377 {
+			j--
+			code, _ = strconv.ParseUint(t.src[t.i:j], 8, 32)
+		}
+		fmt.Fprintf(&t.out, `\x{%X}`, code)
+		t.i = j
+	case 'c':
+		if t.i >= len(t.src) {
+			return t.fail(`incomplete \c escape`)
+		}
+		fmt.Fprintf(&t.out, `\x{%X}`, t.src[t.i]^64)
+		t.i++
+	case 'h':
+		return t.class(`\t \x{A0}\x{1680}\x{180E}\x{2000}-\x{200A}\x{202F}\x{205F}\x{3000}`, false)
+	case 'H':
+		return t.class(`\t \x{A0}\x{1680}\x{180E}\x{2000}-\x{200A}\x{202F}\x{205F}\x{3000}`, true)
+	case 'v':
+		return t.class(`\n\x0B\f\r\x{85}\x{2028}\x{2029}`, false)
+	case 'V':
+		return t.class(`\n\x0B\f\r\x{85}\x{2028}\x{2029}`, true)
+	case 'R':
+		if t.inClass > 0 {
+			return t.fail(`\R is not allowed in a character class`)
+		}
+		t.out.WriteString(`(?:\r\n|[\n\x0B\f\r\x{85}\x{2028}\x{2029}])`)
+	case 'p', 'P':
+		return t.property(ch == 'P')
+	default:
+		t.out.WriteByte('\\')
+		t.out.WriteByte(ch)
+	}
+	return nil
+}
+
+// class writes a set of class items, as a class of its own outside one.
+func (t *regexTranslator) class(items string, negated bool) error {
+	switch {
+	case t.inClass > 0 && negated:
+		return t.fail("negated escapes such as \\H are not supported inside a character class")
+	case t.inClass > 0:
+		t.out.WriteString(items)
+	case negated:
+		t.out.WriteString(`[^` + items + `]`)
+	default:
+		t.out.WriteString(`[` + items + `]`)
+	}
+	return nil
+}
+
+// property translates \p{...} and \P{...}.
+func (t *regexTranslator) property(negated bool) error {
+	name := ""
+	if strings.HasPrefix(t.src[t.i:], "{") {
+		end := strings.IndexByte(t.src[t.i:], '}')
+		if end < 0 {
+			return t.fail("unterminated property name")
+		}
+		name = t.src[t.i+1 : t.i+end]
+		t.i += end + 1
+	} else if t.i < len(t.src) {
+		_, size := utf8.DecodeRuneInString(t.src[t.i:])
+		name = t.src[t.i : t.i+size]
+		t.i += size
+	}
+	letter := "p"
+	if negated {
+		letter = "P"
+	}
+	if posix, ok := posixClasses[name]; ok {
+		switch {
+		case t.inClass > 0 && negated:
+			t.out.WriteString("[:^" + posix + ":]")
+		case t.inClass > 0:
+			t.out.WriteString("[:" + posix + ":]")
+		case negated:
+			t.out.WriteString("[^[:" + posix + ":]]")
+		default:
+			t.out.WriteString("[[:" + posix + ":]]")
+		}
+		return nil
+	}
+	switch {
+	case strings.HasPrefix(name, "Is"):
+		name = strings.TrimPrefix(name, "Is")
+	case strings.HasPrefix(name, "script=") || strings.HasPrefix(name, "sc="):
+		_, name, _ = strings.Cut(name, "=")
+	case strings.HasPrefix(name, "general_category=") || strings.HasPrefix(name, "gc="):
+		_, name, _ = strings.Cut(name, "=")
+	case strings.HasPrefix(name, "In") || strings.HasPrefix(name, "block=") || strings.HasPrefix(name, "java"):
+		return t.fail("property \\p{%s} is not supported by Go regular expressions", name)
+	}
+	if _, err := regexp.Compile(`\p{` + name + `}`); err != nil {
+		return t.fail("property \\p{%s} is not supported by Go regular expressions", name)
+	}
+	t.out.WriteString(`\` + letter + `{` + name + `}`)
+	return nil
+}
+
+// translateJavaReplacement rewrites a Matcher.replaceAll replacement, where
+// $n and ${name} name groups and a backslash escapes the next character, to
+// Regexp.Expand syntax.
+func translateJavaReplacement(replacement string) (string, error) {
+	var out strings.Builder
+	for i := 0; i < len(replacement); i++ {
+		switch ch := replacement[i]; ch {
+		case '\\':
+			if i+1 >= len(replacement) {
+				return "", fmt.Errorf("replacement %q ends with a backslash", replacement)
+			}
+			i++
+			if replacement[i] == '$' {
+				out.WriteString("$$")
+			} else {
+				out.WriteByte(replacement[i])
+			}
+		case '$':
+			rest := replacement[i+1:]
+			if strings.HasPrefix(rest, "{") {
+				end := strings.IndexByte(rest, '}')
+				if end < 0 {
+					return "", fmt.Errorf("replacement %q has an unterminated group name", replacement)
+				}
+				out.WriteString("${" + rest[1:end] + "}")
+				i += end + 1
+				continue
+			}
+			j := 0
+			for j < len(rest) && rest[j] >= '0' && rest[j] <= '9' {
+				j++
+			}
+			if j == 0 {
+				return "", fmt.Errorf("replacement %q has an illegal group reference", replacement)
+			}
+			out.WriteString("${" + rest[:j] + "}")
+			i += j
+		default:
+			out.WriteByte(ch)
+		}
+	}
+	return out.String(), nil
+}
+
+// regexFlags holds the parsed flags argument of FreeMarker's string
+// builtins: i, m, s and c set matching modes, r selects regular expressions,
+// f limits ?replace to the first match.
+type regexFlags struct {
+	prefix string
+	regex  bool
+	first  bool
+}
+
+func parseRegexFlags(flags string, builtin string) (regexFlags, error) {
+	var parsed regexFlags
+	var modes strings.Builder
+	for _, flag := range flags {
+		switch flag {
+		case 'i', 'm', 's':
+			modes.WriteRune(flag)
+		case 'c':
+			parsed.prefix = "(?x)"
+		case 'r':
+			parsed.regex = true
+		case 'l':
+			parsed.regex = false
+		case 'f':
+			if builtin != "replace" {
+				return regexFlags{}, fmt.Errorf("?%s does not support the f flag", builtin)
+			}
+			parsed.first = true
+		default:
+			return regexFlags{}, fmt.Errorf("?%s: unknown flag %q", builtin, flag)
+		}
+	}
+	if modes.Len() > 0 {
+		parsed.prefix = "(?" + modes.String() + ")" + parsed.prefix
+	}
+	return parsed, nil
+}
+
+var compiledRegexps sync.Map
+
+// compileJavaRegex translates and compiles pattern with the given flags;
+// literal (non-regex) patterns are quoted so case folding still applies.
+func compileJavaRegex(pattern string, flags regexFlags) (*regexp.Regexp, error) {
+	key := flags.prefix + "\x00" + strconv.FormatBool(flags.regex) + "\x00" + pattern
+	if cached, ok := compiledRegexps.Load(key); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+	source := regexp.QuoteMeta(pattern)
+	if flags.regex {
+		translated, err := translateJavaRegex(flags.prefix + pattern)
+		if err != nil {
+			return nil, err
+		}
+		source = translated
+	} else if flags.prefix != "" {
+		source = strings.ReplaceAll(flags.prefix, "(?x)", "") + source
+	}
+	re, err := regexp.Compile(source)
+	if err != nil {
+		return nil, regexError{pattern: pattern, reason: err.Error()}
+	}
+	compiledRegexps.Store(key, re)
+	return re, nil
+}
+
+// validateRegexArgs checks the literal pattern and flags of a regex builtin
+// call at conversion time, so unsupported Java constructs are reported at the
+// call site; non-literal arguments are only checked at render time.
+func validateRegexArgs(builtin string, rawArgs string, regex bool) error {
+	args := splitArgs(rawArgs)
+	pattern, flags := args[0], ""
+	if builtin == "replace" {
+		args = args[1:]
+	}
+	if len(args) > 1 {
+		flags = args[1]
+	}
+	return validateRegexCall(builtin, pattern, flags, regex)
+}
+
+func validateRegexCall(builtin string, pattern string, flags string, regex bool) error {
+	parsedPattern, isString, err := normalizeStringLiteral(pattern)
+	if err != nil || !isString {
+		return err
+	}
+	flagsText := ""
+	if flags != "" {
+		parsedFlags, isString, err := normalizeStringLiteral(flags)
+		if err != nil || !isString {
+			return err
+		}
+		flagsText, _ = strconv.Unquote(parsedFlags)
+	}
+	parsed, err := parseRegexFlags(flagsText, builtin)
+	if err != nil {
+		return err
+	}
+	parsed.regex = parsed.regex || regex
+	text, err := strconv.Unquote(parsedPattern)
+	if err != nil {
+		return err
+	}
+	_, err = compileJavaRegex(text, parsed)
+	return err
+}
+
+// regexMatch is one match of ?matches used as a sequence; it prints as the
+// matched text and exposes its groups through ?groups.
+type regexMatch struct {
+	text   string
+	groups []string
+}
+
+func (m regexMatch) String() string {
+	return m.text
+}
+
+// regexArgs splits the pattern and optional flags of a regex helper.
+func regexArgs(builtin string, pattern any, rest []any, regex bool) (*regexp.Regexp, regexFlags, error) {
+	text, err := strictString(pattern, "?"+builtin+" pattern")
+	if err != nil {
+		return nil, regexFlags{}, err
+	}
+	flagsText := ""
+	switch len(rest) {
+	case 0:
+	case 1:
+		flagsText, err = strictString(rest[0], "?"+builtin+" flags")
+		if err != nil {
+			return nil, regexFlags{}, err
+		}
+	default:
+		return nil, regexFlags{}, fmt.Errorf("?%s expects at most one flags argument", builtin)
+	}
+	flags, err := parseRegexFlags(flagsText, builtin)
+	if err != nil {
+		return nil, regexFlags{}, err
+	}
+	flags.regex = flags.regex || regex
+	re, err := compileJavaRegex(text, flags)
+	return re, flags, err
+}
+
+func groupStrings(s string, loc []int) []string {
+	groups := make([]string, len(loc)/2)
+	for i := range groups {
+		if loc[2*i] >= 0 {
+			groups[i] = s[loc[2*i]:loc[2*i+1]]
+		}
+	}
+	return groups
+}
+
+// regexMatches implements ?matches as a condition: whether the whole string
+// matches the Java pattern.
+func regexMatches(v any, pattern any, flags ...any) (bool, error) {
+	s, err := strictString(v, "?matches value")
+	if err != nil {
+		return false, err
+	}
+	re, _, err := regexArgs("matches", pattern, flags, true)
+	if err != nil {
+		return false, err
+	}
+	return wholeMatch(re, s) != nil, nil
+}
+
+var anchoredRegexps sync.Map
+
+// wholeMatch returns the submatch indexes of a match spanning all of s, as
+// Java's Matcher.matches requires.
+func wholeMatch(re *regexp.Regexp, s string) []int {
+	anchored, ok := anchoredRegexps.Load(re.String())
+	if !ok {
+		anchored, _ = anchoredRegexps.LoadOrStore(re.String(), regexp.MustCompile(`\A(?:`+re.String()+`)\z`))
+	}
+	return anchored.(*regexp.Regexp).FindStringSubmatchIndex(s)
+}
+
+// regexMatchAll implements ?matches as a list source: every match of the pattern.
+func regexMatchAll(v any, pattern any, flags ...any) ([]regexMatch, error) {
+	s, err := strictString(v, "?matches value")
+	if err != nil {
+		return nil, err
+	}
+	re, _, err := regexArgs("matches", pattern, flags, true)
+	if err != nil {
+		return nil, err
+	}
+	var matches []regexMatch
+	for _, loc := range re.FindAllStringSubmatchIndex(s, -1) {
+		matches = append(matches, regexMatch{text: s[loc[0]:loc[1]], groups: groupStrings(s, loc)})
+	}
+	return matches, nil
+}
+
+// regexGroups implements ?groups, on a match from a listed ?matches result or
+// on the whole-string match of s?matches(pattern); group 0 is the whole match
+// and a string that does not match has no groups.
+func regexGroups(v any, args ...any) ([]string, error) {
+	if match, ok := v.(regexMatch); ok {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("?groups expects no arguments")
+		}
+		return match.groups, nil
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("?groups needs a ?matches result, got %s", valueTypeName(v))
+	}
+	s, err := strictString(v, "?matches value")
+	if err != nil {
+		return nil, err
+	}
+	re, _, err := regexArgs("matches", args[0], args[1:], true)
+	if err != nil {
+		return nil, err
+	}
+	loc := wholeMatch(re, s)
+	if loc == nil {
+		return []string{}, nil
+	}
+	return groupStrings(s, loc), nil
+}
+
+// regexReplace implements ?replace: a literal replacement of every
+// occurrence, or with the r flag a Java regex replacement whose $n group
+// references are honoured; f replaces the first occurrence only.
+func regexReplace(v any, pattern any, replacement any, flags ...any) (string, error) {
+	s, err := strictString(v, "?replace value")
+	if err != nil {
+		return "", err
+	}
+	with, err := strictString(replacement, "?replace replacement")
+	if err != nil {
+		return "", err
+	}
+	re, parsed, err := regexArgs("replace", pattern, flags, false)
+	if err != nil {
+		return "", err
+	}
+	expand := strings.ReplaceAll(with, "$", "$$")
+	if parsed.regex {
+		if expand, err = translateJavaReplacement(with); err != nil {
+			return "", err
+		}
+	}
+	if !parsed.first {
+		return re.ReplaceAllString(s, expand), nil
+	}
+	loc := re.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return s, nil
+	}
+	expanded := re.ExpandString(nil, expand, s, loc)
+	return s[:loc[0]] + string(expanded) + s[loc[1]:], nil
+}
+
+// regexSplit implements ?split: at every literal separator, or with the r
+// flag at every regex match, dropping trailing empty items like Java's
+// Pattern.split.
+func regexSplit(v any, separator any, flags ...any) ([]string, error) {
+	s, err := strictString(v, "?split value")
+	if err != nil {
+		return nil, err
+	}
+	re, parsed, err := regexArgs("split", separator, flags, false)
+	if err != nil {
+		return nil, err
+	}
+	if !parsed.regex && parsed.prefix == "" {
+		sep, _ := strictString(separator, "?split separator")
+		if sep != "" {
+			return strings.Split(s, sep), nil
+		}
+	}
+	parts := re.Split(s, -1)
+	if parsed.regex {
+		for len(parts) > 0 && parts[len(parts)-1] == "" {
+			parts = parts[:len(parts)-1]
+		}
+	}
+	return parts, nil
+}
@@ -0,0 +1,110 @@
+package convert
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranslateJavaRegex(t *testing.T) {
+	tests := []struct {
+		java string
+		want string
+	}{
+		{`(?<year>\d{4})-(?<month>\d{2})`, `(?P<year>\d{4})-(?P<month>\d{2})`},
+		{`(?iu)abc`, `(?i)abc`},
+		{`(?U)\w+`, `\w+`},
+		{`(?i-s:a.b)`, `(?i-s:a.b)`},
+		{`(?x) a b # comment` + "\n" + `[ ]c`, `ab[ ]c`},
+		{`\Qa.b\E+`, `a\.b+`},
+		{`\u00e9\e\0101\cA`, `\x{00e9}\x1B\x{41}\x{1}`},
+		{`\p{Alpha}[\p{Digit}_]\P{Upper}`, `[[:alpha:]][[:digit:]_][^[:upper:]]`},
+		{`\p{IsLatin}\p{Lu}`, `\p{Latin}\p{Lu}`},
+		{`a\Rb`, `a(?:\r\n|[\n\x0B\f\r\x{85}\x{2028}\x{2029}])b`},
+		{`[]a[:]`, `[\]a\[:]`},
+		{`a*?b+c{2,}?`, `a*?b+c{2,}?`},
+	}
+	for _, tc := range tests {
+		got, err := translateJavaRegex(tc.java)
+		require.NoError(t, err, tc.java)
+		assert.Equal(t, tc.want, got, tc.java)
+	}
+
+	unsupported := map[string]string{
+		`a(?=b)`:          "lookaround",
+		`(?<!x)y`:         "lookaround",
+		`(?>a+)b`:         "atomic groups",
+		`(a)\1`:           "backreferences",
+		`(?<n>a)\k<n>`:    "backreferences",
+		`a++`:             "possessive",
+		`[a-z&&[^aeiou]]`: "intersections",
+		`[a[b]]`:          "nested character classes",
+		`\p{InGreek}`:     "not supported",
+		`\Gx`:             `\G`,
+	}
+	for java, reason := range unsupported {
+		_, err := translateJavaRegex(java)
+		var regexErr regexError
+		require.ErrorAs(t, err, &regexErr, java)
+		assert.Contains(t, err.Error(), reason, java)
+	}
+}
+
+func TestTranslateJavaReplacement(t *testing.T) {
+	got, err := translateJavaReplacement(`$1x-${name}\$\\`)
+	require.NoError(t, err)
+	assert.Equal(t, `${1}x-${name}$$\`, got)
+
+	_, err = translateJavaReplacement(`$x`)
+	assert.ErrorContains(t, err, "illegal group reference")
+}
+
+func TestRegexHelpers(t *testing.T) {
+	ok, err := regexMatches("2026-03-12", `\d{4}-\d{2}-\d{2}`)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = regexMatches("on 2026-03-12", `\d{4}-\d{2}-\d{2}`)
+	require.NoError(t, err)
+	assert.False(t, ok, "?matches needs the whole string to match")
+	ok, err = regexMatches("ABC", `abc`, "i")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	groups, err := regexGroups("2026-03", `(?<y>\d+)-(\d+)`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2026-03", "2026", "03"}, groups)
+	groups, err = regexGroups("x", `\d+`)
+	require.NoError(t, err)
+	assert.Empty(t, groups)
+
+	matches, err := regexMatchAll("a1 b22", `([a-z])(\d+)`)
+	require.NoError(t, err)
+	require.Len(t, matches, 2)
+	assert.Equal(t, "b22", matches[1].String())
+	groups, err = regexGroups(matches[1])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b22", "b", "22"}, groups)
+
+	replaced, err := regexReplace("a.b.c", ".", "$")
+	require.NoError(t, err)
+	assert.Equal(t, "a$b$c", replaced)
+	replaced, err = regexReplace("John Smith", `(\w+) (\w+)`, "$2, $1", "r")
+	require.NoError(t, err)
+	assert.Equal(t, "Smith, John", replaced)
+	replaced, err = regexReplace("aAa", "a", "x", "if")
+	require.NoError(t, err)
+	assert.Equal(t, "xAa", replaced)
+
+	parts, err := regexSplit("a,b,,c,", ",")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "", "c", ""}, parts)
+	parts, err = regexSplit("a1b22c333", `\d+`, "r")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, parts)
+
+	_, err = regexMatches("x", `(?=x)`)
+	assert.ErrorContains(t, err, "lookaround")
+	_, err = regexSplit("x", ",", "f")
+	assert.ErrorContains(t, err, "does not support the f flag")
+}
@@ -0,0 +1,151 @@
+// Package convert transforms FreeMarker templates into Go templates.
+package convert
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// defaultLocale is the locale FreeMarker uses when no locale setting is active.
+const defaultLocale = "en_US"
+
+// runtimeSettingKeys lists settings forwarded to helpers through ftlSettings.
+var runtimeSettingKeys = map[string]struct{}{
+	"boolean_format":              {},
+	"date_format":                 {},
+	"datetime_format":             {},
+	"locale":                      {},
+	"number_format":               {},
+	"sql_date_and_time_time_zone": {},
+	"time_format":                 {},
+	"time_zone":                   {},
+}
+
+// ignoredSettingKeys lists settings that have no effect on converted output.
+var ignoredSettingKeys = map[string]struct{}{
+	"attributes":           {},
+	"encoding":             {},
+	"ns_prefixes":          {},
+	"output_encoding":      {},
+	"strip_whitespace":     {},
+	"url_escaping_charset": {},
+}
+
+// datetimeSettingKinds maps the datetime format settings to their value type.
+var datetimeSettingKinds = map[string]datetimeKind{
+	"date_format":     kindDate,
+	"time_format":     kindTime,
+	"datetime_format": kindDatetime,
+}
+
+// rawOutput reports whether the output_format and auto_esc settings turn
+// off escaping, as <#outputformat "plainText"> and <#noautoesc> do.
+func (s settingsState) rawOutput() bool {
+	return s["output_format"] == "plainText" || s["auto_esc"] == "false"
+}
+
+// settingsState stores FreeMarker settings active at the current emission point.
+type settingsState map[string]string
+
+// clone returns an independent copy of the active settings.
+func (s settingsState) clone() settingsState {
+	out := make(settingsState, len(s))
+	for k, v := range s {
+		out[k] = v
+	}
+	return out
+}
+
+// helperArg renders active runtime settings as an ftlSettings call, or "" when
+// all of them keep their FreeMarker defaults.
+func (s settingsState) helperArg() string {
+	keys := make([]string, 0, len(s))
+	for k := range s {
+		if _, ok := runtimeSettingKeys[k]; ok {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, 1+2*len(keys))
+	parts = append(parts, "ftlSettings")
+	for _, k := range keys {
+		parts = append(parts, strconv.Quote(k), strconv.Quote(s[k]))
+	}
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+// settingKey normalizes camelCase setting names to FreeMarker's snake_case form.
+func settingKey(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if unicode.IsUpper(r) {
+			b.WriteByte('_')
+			b.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// settingValue evaluates a literal setting value to its string form.
+func settingValue(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if !isLiteral(raw) || raw == "null" || raw == "nil" {
+		return "", fmt.Errorf("setting values must be literals, got %q", raw)
+	}
+	normalized, isString, err := normalizeStringLiteral(raw)
+	if err != nil {
+		return "", err
+	}
+	if !isString {
+		return raw, nil
+	}
+	return strconv.Unquote(normalized)
+}
+
+// validateSetting checks that the helper runtime can honour a setting value.
+func validateSetting(key string, value string) error {
+	switch key {
+	case "boolean_format":
+		if value != "c" && strings.Count(value, ",") != 1 {
+			return fmt.Errorf("boolean_format must be \"c\" or \"true_text,false_text\", got %q", value)
+		}
+	case "locale":
+		if !isKnownLocale(value) {
+			return fmt.Errorf("locale %q is not supported by the helper runtime", value)
+		}
+	case "number_format":
+		if _, _, err := resolveNumberFormat(value, locales[defaultLocale]); err != nil {
+			return err
+		}
+	case "date_format", "time_format", "datetime_format":
+		if err := validateDatetimeFormat(value, datetimeSettingKinds[key]); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	case "time_zone", "sql_date_and_time_time_zone":
+		if _, err := resolveTimeZone(value); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	case "output_format":
+		switch value {
+		case "HTML", "XHTML", "XML", "plainText":
+		default:
+			return fmt.Errorf("output_format %q cannot be mapped onto html/template escaping", value)
+		}
+	case "auto_esc":
+		if value != "true" && value != "false" {
+			return fmt.Errorf("auto_esc must be true or false, got %q", value)
+		}
+	default:
+		return fmt.Errorf("unsupported setting %q", key)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package convert
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// Embed the IANA database so zone names resolve without system tzdata.
+	_ "time/tzdata"
+)
+
+// resolveTimeZone maps a FreeMarker time zone ID to a location. It accepts
+// IANA names ("Europe/Paris"), "UTC"/"GMT", and Java's custom "GMT+h[h][:mm]"
+// IDs; an empty name selects UTC, the helper runtime's default zone.
+func resolveTimeZone(name string) (*time.Location, error) {
+	switch name {
+	case "", "UTC", "GMT", "Z":
+		return time.UTC, nil
+	case "Local":
+		return nil, fmt.Errorf("time zone %q depends on the host and is not supported", name)
+	}
+	if rest, ok := strings.CutPrefix(name, "GMT"); ok {
+		return customGMTZone(name, rest)
+	}
+	zone, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	return zone, nil
+}
+
+// customGMTZone parses the "+h", "+hh", "+hhmm" or "+hh:mm" offset of a
+// Java custom time zone ID such as "GMT+01:00".
+func customGMTZone(name string, offset string) (*time.Location, error) {
+	invalid := fmt.Errorf("invalid custom time zone %q", name)
+	if offset == "" || (offset[0] != '+' && offset[0] != '-') {
+		return nil, invalid
+	}
+	sign := 1
+	if offset[0] == '-' {
+		sign = -1
+	}
+	hoursText, minutesText, hasColon := strings.Cut(offset[1:], ":")
+	if !hasColon && len(hoursText) == 4 {
+		hoursText, minutesText = hoursText[:2], hoursText[2:]
+	}
+	if len(hoursText) == 0 || len(hoursText) > 2 || (minutesText != "" && len(minutesText) != 2) || (hasColon && minutesText == "") {
+		return nil, invalid
+	}
+	hours, err := strconv.Atoi(hoursText)
+	if err != nil || hours > 23 {
+		return nil, invalid
+	}
+	minutes := 0
+	if minutesText != "" {
+		minutes, err = strconv.Atoi(minutesText)
+		if err != nil || minutes > 59 {
+			return nil, invalid
+		}
+	}
+	seconds := sign * (hours*3600 + minutes*60)
+	if seconds == 0 {
+		return time.UTC, nil
+	}
+	return time.FixedZone("GMT"+zoneOffsetText(seconds, true, false), seconds), nil
+}
+
+// isKnownTimeZone reports whether resolveTimeZone can resolve a zone ID.
+func isKnownTimeZone(name string) bool {
+	_, err := resolveTimeZone(name)
+	return err == nil
+}
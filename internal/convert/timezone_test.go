@@ -0,0 +1,49 @@
+package convert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveTimeZone(t *testing.T) {
+	ref := time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC)
+	tests := map[string]int{
+		"":                 0,
+		"UTC":              0,
+		"GMT":              0,
+		"Europe/Paris":     2 * 3600,
+		"America/New_York": -4 * 3600,
+		"Asia/Kolkata":     5*3600 + 1800,
+		"GMT+1":            3600,
+		"GMT-05:30":        -(5*3600 + 1800),
+		"GMT+0200":         2 * 3600,
+	}
+	for name, want := range tests {
+		zone, err := resolveTimeZone(name)
+		require.NoError(t, err, name)
+		_, offset := ref.In(zone).Zone()
+		assert.Equal(t, want, offset, name)
+	}
+
+	for _, name := range []string{"Local", "Mars/Olympus", "GMT+", "GMT+24", "GMT+1:5", "CEST+1"} {
+		_, err := resolveTimeZone(name)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestParseDatetimeInZone(t *testing.T) {
+	paris, err := resolveTimeZone("Europe/Paris")
+	require.NoError(t, err)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	got, err := parseDatetime("2026-03-12 09:00", "yyyy-MM-dd HH:mm", kindDatetime, locales[defaultLocale], paris, now)
+	require.NoError(t, err)
+	assert.True(t, time.Date(2026, 3, 12, 8, 0, 0, 0, time.UTC).Equal(got), got.String())
+
+	got, err = parseDatetime("2026-03-12 09:00 America/New_York", "yyyy-MM-dd HH:mm z", kindDatetime, locales[defaultLocale], paris, now)
+	require.NoError(t, err)
+	assert.True(t, time.Date(2026, 3, 12, 13, 0, 0, 0, time.UTC).Equal(got), got.String())
+}
@@ -0,0 +1,294 @@
+// Package decimal implements arbitrary-precision decimal numbers for helper arithmetic.
+package decimal
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// RoundingMode selects how Round and Quo discard digits.
+type RoundingMode int
+
+const (
+	// HalfUp rounds ties away from zero, like java.math.RoundingMode.HALF_UP.
+	HalfUp RoundingMode = iota
+	// HalfEven rounds ties to the even neighbour, like RoundingMode.HALF_EVEN.
+	HalfEven
+	// Down truncates towards zero, like RoundingMode.DOWN.
+	Down
+)
+
+// Decimal is an immutable number equal to unscaled × 10^-scale, modelled on
+// java.math.BigDecimal. The zero value is 0.
+type Decimal struct {
+	unscaled *big.Int
+	scale    int32
+}
+
+var (
+	bigOne = big.NewInt(1)
+	bigTen = big.NewInt(10)
+)
+
+// New returns unscaled × 10^-scale.
+func New(unscaled int64, scale int32) Decimal {
+	return Decimal{unscaled: big.NewInt(unscaled), scale: scale}
+}
+
+// FromInt64 returns v with scale 0.
+func FromInt64(v int64) Decimal {
+	return New(v, 0)
+}
+
+// FromFloat64 converts v through its shortest decimal representation, the way
+// BigDecimal is built from Double.toString.
+func FromFloat64(v float64) (Decimal, error) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return Decimal{}, fmt.Errorf("number must be finite")
+	}
+	return Parse(strconv.FormatFloat(v, 'g', -1, 64))
+}
+
+// Parse reads an optionally signed decimal with an optional fraction and
+// exponent, such as "-12.50" or "1.5E+3".
+func Parse(s string) (Decimal, error) {
+	raw := s
+	if s == "" {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", raw)
+	}
+
+	exponent := int64(0)
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		exp, err := strconv.ParseInt(s[i+1:], 10, 32)
+		if err != nil {
+			return Decimal{}, fmt.Errorf("invalid decimal %q", raw)
+		}
+		exponent = exp
+		s = s[:i]
+	}
+
+	negative := false
+	switch {
+	case strings.HasPrefix(s, "-"):
+		negative = true
+		s = s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	digits := intPart + fracPart
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", raw)
+	}
+
+	unscaled, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", raw)
+	}
+	if negative {
+		unscaled.Neg(unscaled)
+	}
+	scale := int64(len(fracPart)) - exponent
+	if scale > math.MaxInt32 || scale < math.MinInt32 {
+		return Decimal{}, fmt.Errorf("decimal %q is out of range", raw)
+	}
+	return Decimal{unscaled: unscaled, scale: int32(scale)}, nil
+}
+
+// MustParse is like Parse but panics on invalid input; it is meant for
+// constants and tests.
+func MustParse(s string) Decimal {
+	d, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func (d Decimal) int() *big.Int {
+	if d.unscaled == nil {
+		return new(big.Int)
+	}
+	return d.unscaled
+}
+
+// Scale returns the number of digits after the decimal point.
+func (d Decimal) Scale() int32 {
+	return d.scale
+}
+
+// Sign returns -1, 0 or 1.
+func (d Decimal) Sign() int {
+	return d.int().Sign()
+}
+
+// Neg returns -d.
+func (d Decimal) Neg() Decimal {
+	return Decimal{unscaled: new(big.Int).Neg(d.int()), scale: d.scale}
+}
+
+// Abs returns |d|.
+func (d Decimal) Abs() Decimal {
+	return Decimal{unscaled: new(big.Int).Abs(d.int()), scale: d.scale}
+}
+
+// rescaled returns d's unscaled value at a larger scale.
+func (d Decimal) rescaled(scale int32) *big.Int {
+	if scale == d.scale {
+		return d.int()
+	}
+	factor := new(big.Int).Exp(bigTen, big.NewInt(int64(scale-d.scale)), nil)
+	return factor.Mul(factor, d.int())
+}
+
+func align(a Decimal, b Decimal) (*big.Int, *big.Int, int32) {
+	scale := a.scale
+	if b.scale > scale {
+		scale = b.scale
+	}
+	return a.rescaled(scale), b.rescaled(scale), scale
+}
+
+// Add returns d + o with the larger of the two scales.
+func (d Decimal) Add(o Decimal) Decimal {
+	a, b, scale := align(d, o)
+	return Decimal{unscaled: new(big.Int).Add(a, b), scale: scale}
+}
+
+// Sub returns d - o with the larger of the two scales.
+func (d Decimal) Sub(o Decimal) Decimal {
+	a, b, scale := align(d, o)
+	return Decimal{unscaled: new(big.Int).Sub(a, b), scale: scale}
+}
+
+// Mul returns d × o with the sum of the two scales.
+func (d Decimal) Mul(o Decimal) Decimal {
+	return Decimal{unscaled: new(big.Int).Mul(d.int(), o.int()), scale: d.scale + o.scale}
+}
+
+// Quo returns d / o rounded to scale digits after the decimal point.
+func (d Decimal) Quo(o Decimal, scale int32, mode RoundingMode) (Decimal, error) {
+	if o.Sign() == 0 {
+		return Decimal{}, fmt.Errorf("division by zero")
+	}
+	// d / o = (d.unscaled × 10^(scale + o.scale - d.scale)) / o.unscaled × 10^-scale
+	num := new(big.Int).Set(d.int())
+	den := new(big.Int).Set(o.int())
+	shift := int64(scale) + int64(o.scale) - int64(d.scale)
+	if shift >= 0 {
+		num.Mul(num, new(big.Int).Exp(bigTen, big.NewInt(shift), nil))
+	} else {
+		den.Mul(den, new(big.Int).Exp(bigTen, big.NewInt(-shift), nil))
+	}
+	return Decimal{unscaled: divRound(num, den, mode), scale: scale}, nil
+}
+
+// Round returns d rounded to scale digits after the decimal point.
+func (d Decimal) Round(scale int32, mode RoundingMode) Decimal {
+	if scale >= d.scale {
+		return Decimal{unscaled: d.rescaled(scale), scale: scale}
+	}
+	den := new(big.Int).Exp(bigTen, big.NewInt(int64(d.scale-scale)), nil)
+	return Decimal{unscaled: divRound(new(big.Int).Set(d.int()), den, mode), scale: scale}
+}
+
+// divRound divides num by den and rounds the quotient with mode.
+func divRound(num *big.Int, den *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() == 0 || mode == Down {
+		return q
+	}
+	negative := (num.Sign() < 0) != (den.Sign() < 0)
+	twice := new(big.Int).Abs(r)
+	twice.Lsh(twice, 1)
+	cmp := twice.Cmp(new(big.Int).Abs(den))
+	up := cmp > 0 || (cmp == 0 && (mode == HalfUp || q.Bit(0) == 1))
+	if !up {
+		return q
+	}
+	if negative {
+		return q.Sub(q, bigOne)
+	}
+	return q.Add(q, bigOne)
+}
+
+// Cmp compares values regardless of scale, returning -1, 0 or 1.
+func (d Decimal) Cmp(o Decimal) int {
+	a, b, _ := align(d, o)
+	return a.Cmp(b)
+}
+
+// IsInteger reports whether d has no fractional part.
+func (d Decimal) IsInteger() bool {
+	if d.scale <= 0 {
+		return true
+	}
+	return d.Round(0, Down).Cmp(d) == 0
+}
+
+// Int64 returns d as an int64 when it is an integer within range.
+func (d Decimal) Int64() (int64, bool) {
+	if !d.IsInteger() {
+		return 0, false
+	}
+	i := d.Round(0, Down).int()
+	if !i.IsInt64() {
+		return 0, false
+	}
+	return i.Int64(), true
+}
+
+// Float64 returns the nearest float64.
+func (d Decimal) Float64() float64 {
+	f, _ := d.Rat().Float64()
+	return f
+}
+
+// Rat returns d as an exact rational.
+func (d Decimal) Rat() *big.Rat {
+	if d.scale <= 0 {
+		return new(big.Rat).SetInt(d.rescaled(0))
+	}
+	den := new(big.Int).Exp(bigTen, big.NewInt(int64(d.scale)), nil)
+	return new(big.Rat).SetFrac(d.int(), den)
+}
+
+// StripTrailingZeros removes fractional trailing zeros, keeping scale >= 0.
+func (d Decimal) StripTrailingZeros() Decimal {
+	u := new(big.Int).Set(d.int())
+	scale := d.scale
+	if u.Sign() == 0 {
+		return Decimal{unscaled: u}
+	}
+	r := new(big.Int)
+	for scale > 0 {
+		q, rem := new(big.Int).QuoRem(u, bigTen, r)
+		if rem.Sign() != 0 {
+			break
+		}
+		u = q
+		scale--
+	}
+	return Decimal{unscaled: u, scale: scale}
+}
+
+// String renders d without an exponent, like BigDecimal.toPlainString.
+func (d Decimal) String() string {
+	if d.scale <= 0 {
+		return d.rescaled(0).String()
+	}
+	digits := new(big.Int).Abs(d.int()).String()
+	for len(digits) <= int(d.scale) {
+		digits = "0" + digits
+	}
+	point := len(digits) - int(d.scale)
+	sign := ""
+	if d.Sign() < 0 {
+		sign = "-"
+	}
+	return sign + digits[:point] + "." + digits[point:]
+}
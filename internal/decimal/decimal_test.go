@@ -0,0 +1,77 @@
+package decimal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAndString(t *testing.T) {
+	tests := map[string]string{
+		"0":                        "0",
+		"19.99":                    "19.99",
+		"-0.050":                   "-0.050",
+		"+7":                       "7",
+		"1.5E+3":                   "1500",
+		"1.5e-3":                   "0.0015",
+		".5":                       "0.5",
+		"12345678901234567890.123": "12345678901234567890.123",
+	}
+	for in, want := range tests {
+		d, err := Parse(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, d.String(), in)
+	}
+
+	for _, in := range []string{"", "-", ".", "1.2.3", "abc", "1e", "1_000"} {
+		_, err := Parse(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestArithmeticMatchesBigDecimal(t *testing.T) {
+	assert.Equal(t, "59.97", MustParse("19.99").Mul(FromInt64(3)).String())
+	assert.Equal(t, "0.3", MustParse("0.1").Add(MustParse("0.2")).String())
+	assert.Equal(t, "-0.10", MustParse("1.00").Sub(MustParse("1.1")).String())
+
+	q, err := FromInt64(1).Quo(FromInt64(3), 12, HalfUp)
+	require.NoError(t, err)
+	assert.Equal(t, "0.333333333333", q.String())
+
+	q, err = FromInt64(-2).Quo(FromInt64(3), 2, HalfUp)
+	require.NoError(t, err)
+	assert.Equal(t, "-0.67", q.String())
+
+	_, err = FromInt64(1).Quo(Decimal{}, 2, HalfUp)
+	assert.Error(t, err)
+}
+
+func TestRound(t *testing.T) {
+	assert.Equal(t, "2.68", MustParse("2.675").Round(2, HalfUp).String())
+	assert.Equal(t, "2.68", MustParse("2.675").Round(2, HalfEven).String())
+	assert.Equal(t, "2.62", MustParse("2.625").Round(2, HalfEven).String())
+	assert.Equal(t, "-2.63", MustParse("-2.625").Round(2, HalfUp).String())
+	assert.Equal(t, "2.67", MustParse("2.679").Round(2, Down).String())
+	assert.Equal(t, "5.000", MustParse("5").Round(3, HalfUp).String())
+}
+
+func TestComparisonsAndConversions(t *testing.T) {
+	assert.Equal(t, 0, MustParse("5.00").Cmp(FromInt64(5)))
+	assert.Equal(t, -1, MustParse("-0.01").Cmp(Decimal{}))
+	assert.True(t, MustParse("5.00").IsInteger())
+	assert.False(t, MustParse("5.01").IsInteger())
+	assert.Equal(t, "5", MustParse("5.00").StripTrailingZeros().String())
+	assert.Equal(t, "500", MustParse("5E+2").StripTrailingZeros().String())
+
+	i, ok := MustParse("42.0").Int64()
+	assert.True(t, ok)
+	assert.Equal(t, int64(42), i)
+	_, ok = MustParse("99999999999999999999").Int64()
+	assert.False(t, ok)
+
+	d, err := FromFloat64(0.1)
+	require.NoError(t, err)
+	assert.Equal(t, "0.1", d.String())
+	assert.Equal(t, 0.1, d.Float64())
+}
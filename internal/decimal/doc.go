@@ -0,0 +1,2 @@
+// Package decimal implements arbitrary-precision decimal numbers for helper arithmetic.
+package decimal
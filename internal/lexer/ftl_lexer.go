@@ -17,6 +17,7 @@ const (
 	TokenDirective     TokenKind = "directive"
 	TokenInterpolation TokenKind = "interpolation"
 	TokenMacroCall     TokenKind = "macro_call"
+	TokenComment       TokenKind = "comment"
 )
 
 // Token represents one lexical unit with source coordinates and metadata.
@@ -61,16 +62,25 @@ func (s *scanner) consumeText() Token {
 	}
 }
 
-// consumeComment skips FreeMarker comments (<#-- ... -->).
-func (s *scanner) consumeComment() error {
+// consumeComment consumes FreeMarker comments (<#-- ... -->), keeping the
+// text between the markers as the token value.
+func (s *scanner) consumeComment() (Token, error) {
+	startLine, startCol := s.line, s.column
 	start := s.index
-	idx := strings.Index(s.src[start:], "-->")
+	idx := strings.Index(s.src[start+len("<#--"):], "-->")
 	if idx < 0 {
-		return diagnostics.New("LEX_UNCLOSED_COMMENT", s.file, s.line, s.column, "unclosed FreeMarker comment", "")
+		return Token{}, diagnostics.New("LEX_UNCLOSED_COMMENT", s.file, s.line, s.column, "unclosed FreeMarker comment", "")
 	}
-	end := start + idx + len("-->")
-	s.advanceByString(s.src[start:end])
-	return nil
+	end := start + len("<#--") + idx + len("-->")
+	raw := s.src[start:end]
+	s.advanceByString(raw)
+	return Token{
+		Kind:    TokenComment,
+		PosLine: startLine,
+		PosCol:  startCol,
+		Raw:     raw,
+		Value:   raw[len("<#--") : len(raw)-len("-->")],
+	}, nil
 }
 
 // consumeInterpolation consumes ${...} and #{...} blocks with nesting.
@@ -132,6 +142,34 @@ func (s *scanner) consumeInterpolation() (Token, error) {
 	return Token{}, diagnostics.New("LEX_UNCLOSED_INTERPOLATION", s.file, startLine, startCol, "unclosed interpolation", "")
 }
 
+// consumeNoparse consumes the body of a <#noparse> block, whose opening tag
+// was just read, as raw text up to and including </#noparse>.
+func (s *scanner) consumeNoparse(open Token) (Token, error) {
+	startLine, startCol := s.line, s.column
+	start := s.index
+	for offset := start; ; {
+		idx := strings.Index(s.src[offset:], "</#noparse")
+		if idx < 0 {
+			return Token{}, diagnostics.New("LEX_UNCLOSED_NOPARSE", s.file, open.PosLine, open.PosCol, "noparse directive not closed", open.Raw)
+		}
+		end := offset + idx
+		rest := strings.TrimLeftFunc(s.src[end+len("</#noparse"):], unicode.IsSpace)
+		if !strings.HasPrefix(rest, ">") {
+			offset = end + len("</#noparse")
+			continue
+		}
+		text := s.src[start:end]
+		s.advanceByString(s.src[start : len(s.src)-len(rest)+1])
+		return Token{
+			Kind:    TokenText,
+			PosLine: startLine,
+			PosCol:  startCol,
+			Value:   text,
+			Raw:     text,
+		}, nil
+	}
+}
+
 // splitNameArgs splits a tag body into name and trailing arguments.
 func splitNameArgs(body string) (string, string) {
 	body = strings.TrimSpace(body)
@@ -200,12 +238,14 @@ func parseTagToken(raw string, line int, col int, file string) (Token, error) {
 	return Token{}, diagnostics.New("LEX_UNKNOWN_TAG", file, line, col, fmt.Sprintf("unknown tag kind %q", raw), raw)
 }
 
-// consumeTag consumes directive and macro-call tags until >.
+// consumeTag consumes directive and macro-call tags until the first > that
+// is outside quotes and brackets, so (a > b) stays inside tag arguments.
 func (s *scanner) consumeTag() (Token, error) {
 	startLine, startCol := s.line, s.column
 	start := s.index
 	inQuote := byte(0)
 	escaped := false
+	depth := 0
 
 	for !s.eof() {
 		ch := s.src[s.index]
@@ -229,7 +269,17 @@ func (s *scanner) consumeTag() (Token, error) {
 			inQuote = ch
 			continue
 		}
-		if ch == '>' {
+		switch ch {
+		case '(', '[', '{':
+			depth++
+			continue
+		case ')', ']', '}':
+			if depth > 0 {
+				depth--
+			}
+			continue
+		}
+		if ch == '>' && depth == 0 {
 			raw := s.src[start:s.index]
 			return parseTagToken(raw, startLine, startCol, s.file)
 		}
@@ -274,9 +324,11 @@ func Lex(file string, src string) ([]Token, error) {
 	for !s.eof() {
 		switch {
 		case s.hasPrefix("<#--"):
-			if err := s.consumeComment(); err != nil {
+			tok, err := s.consumeComment()
+			if err != nil {
 				return nil, err
 			}
+			tokens = append(tokens, tok)
 		case s.hasPrefix("${") || s.hasPrefix("#{"):
 			tok, err := s.consumeInterpolation()
 			if err != nil {
@@ -288,6 +340,16 @@ func Lex(file string, src string) ([]Token, error) {
 			if err != nil {
 				return nil, err
 			}
+			if tok.Kind == TokenDirective && tok.Name == "noparse" && !tok.Closing {
+				// The body is kept verbatim, FreeMarker syntax included.
+				tok, err = s.consumeNoparse(tok)
+				if err != nil {
+					return nil, err
+				}
+				if tok.Value == "" {
+					continue
+				}
+			}
 			tokens = append(tokens, tok)
 		default:
 			tok := s.consumeText()
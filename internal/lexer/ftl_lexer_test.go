@@ -30,3 +30,41 @@ func TestLexReportsLineAndColumn(t *testing.T) {
 	require.Equal(t, 1, diag.Line)
 	require.Equal(t, 5, diag.Column)
 }
+
+func TestLexTagKeepsParenthesizedGreaterThan(t *testing.T) {
+	tokens, err := Lex("sample.ftl", `<#if (a > b) && (items[0] >= 1)>yes</#if>`)
+	require.NoError(t, err)
+	require.Len(t, tokens, 3)
+	require.Equal(t, "if", tokens[0].Name)
+	require.Equal(t, "(a > b) && (items[0] >= 1)", tokens[0].Args)
+	require.Equal(t, "yes", tokens[1].Value)
+
+	tokens, err = Lex("sample.ftl", `<#if a gt ">">x</#if>`)
+	require.NoError(t, err)
+	require.Equal(t, `a gt ">"`, tokens[0].Args)
+}
+
+func TestLexNoparseKeepsBodyAsText(t *testing.T) {
+	tokens, err := Lex("sample.ftl", "a<#noparse>${x} <#if y>#{z}</#noparse >b<#noparse></#noparse>")
+	require.NoError(t, err)
+	require.Len(t, tokens, 3)
+	require.Equal(t, TokenText, tokens[1].Kind)
+	require.Equal(t, "${x} <#if y>#{z}", tokens[1].Value)
+	require.Equal(t, 12, tokens[1].PosCol)
+	require.Equal(t, "b", tokens[2].Value)
+
+	_, err = Lex("sample.ftl", "<#noparse>${x}")
+	require.ErrorContains(t, err, "LEX_UNCLOSED_NOPARSE")
+}
+
+func TestLexComments(t *testing.T) {
+	tokens, err := Lex("sample.ftl", "a<#-- note -->b<#---->")
+	require.NoError(t, err)
+	require.Len(t, tokens, 4)
+	require.Equal(t, TokenComment, tokens[1].Kind)
+	require.Equal(t, " note ", tokens[1].Value)
+	require.Equal(t, "", tokens[3].Value)
+
+	_, err = Lex("sample.ftl", "<#-->")
+	require.ErrorContains(t, err, "LEX_UNCLOSED_COMMENT")
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"unicode"
 
 	"github.com/cruffinoni/ftl2gotpl/internal/ast"
 	"github.com/cruffinoni/ftl2gotpl/internal/diagnostics"
@@ -13,7 +14,8 @@ import (
 
 var (
 	listDirectiveRe   = regexp.MustCompile(`(?is)^(.*?)\s+as\s+([A-Za-z_][A-Za-z0-9_]*)$`)
-	assignDirectiveRe = regexp.MustCompile(`(?is)^([A-Za-z_][A-Za-z0-9_]*)\s*=\s*(.+)$`)
+	identifierRe      = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	escapeDirectiveRe = regexp.MustCompile(`(?is)^([A-Za-z_][A-Za-z0-9_]*)\s+as\s+(.+)$`)
 )
 
 // state stores parser progress while consuming lexer tokens.
@@ -31,22 +33,234 @@ func directiveKey(tok lexer.Token) string {
 	return "dir:" + tok.Name
 }
 
-// parseAssign parses assign/local directives into AssignNode.
-func parseAssign(file string, tok lexer.Token, local bool) (ast.Node, error) {
-	match := assignDirectiveRe.FindStringSubmatch(strings.TrimSpace(tok.Args))
-	if len(match) != 3 {
-		return nil, diagnostics.New("PARSE_INVALID_ASSIGN", file, tok.PosLine, tok.PosCol, "assign/local must be '<#assign x = expr>'", tok.Raw)
-	}
-	name := strings.TrimSpace(match[1])
-	expr := strings.TrimSpace(match[2])
-	return ast.AssignNode{
+// assignOperators lists assignment operators, longest first.
+var assignOperators = []string{"++", "--", "+=", "-=", "*=", "/=", "%=", "="}
+
+// parseAssign parses assign, local and global directives into AssignNode:
+// one or more "name op expr" pairs, or the capture form whose body is read
+// up to the matching closing tag.
+func (s *state) parseAssign(tok lexer.Token) (ast.Node, error) {
+	node := ast.AssignNode{
 		Position: ast.Position{Line: tok.PosLine, Column: tok.PosCol},
-		Name:     name,
-		Expr:     expr,
-		Local:    local,
+		Local:    tok.Name == "local",
+		Global:   tok.Name == "global",
+	}
+	args := strings.TrimSpace(tok.Args)
+	if identifierRe.MatchString(args) {
+		body, stop, err := s.parseNodes(map[string]struct{}{"close:" + tok.Name: {}})
+		if err != nil {
+			return nil, err
+		}
+		if stop == nil {
+			return nil, diagnostics.New("PARSE_UNCLOSED_ASSIGN", s.file, tok.PosLine, tok.PosCol, tok.Name+" capture block not closed", tok.Raw)
+		}
+		node.Assignments = []ast.Assignment{{Name: args}}
+		node.Capture = true
+		node.Body = body
+		return node, nil
+	}
+
+	assignments, err := parseAssignments(args)
+	if err != nil {
+		return nil, diagnostics.New("PARSE_INVALID_ASSIGN", s.file, tok.PosLine, tok.PosCol, err.Error(), tok.Raw)
+	}
+	node.Assignments = assignments
+	return node, nil
+}
+
+// parseAssignments splits "a=1 b+=x, c++" into assignments. A value ends at
+// a top-level comma, or at whitespace followed by the next "name op".
+func parseAssignments(args string) ([]ast.Assignment, error) {
+	var out []ast.Assignment
+	i := 0
+	for {
+		for i < len(args) && (args[i] == ',' || unicode.IsSpace(rune(args[i]))) {
+			i++
+		}
+		if i >= len(args) {
+			if len(out) == 0 {
+				return nil, fmt.Errorf("assignment must be '<#assign x = expr>'")
+			}
+			return out, nil
+		}
+
+		name, op, next := scanAssignTarget(args, i)
+		if name == "" {
+			return nil, fmt.Errorf("expected variable name at %q", args[i:])
+		}
+		if op == "" {
+			return nil, fmt.Errorf("%q must be followed by '=' or an assignment operator", name)
+		}
+		i = next
+		if op == "++" || op == "--" {
+			out = append(out, ast.Assignment{Name: name, Op: op})
+			continue
+		}
+
+		valueStart := i
+		depth := 0
+		quote := byte(0)
+		escaped := false
+	value:
+		for ; i < len(args); i++ {
+			ch := args[i]
+			if quote != 0 {
+				if escaped {
+					escaped = false
+				} else if ch == '\\' {
+					escaped = true
+				} else if ch == quote {
+					quote = 0
+				}
+				continue
+			}
+			switch {
+			case ch == '"' || ch == '\'':
+				quote = ch
+			case ch == '{' || ch == '[' || ch == '(':
+				depth++
+			case ch == '}' || ch == ']' || ch == ')':
+				depth--
+			case depth == 0 && ch == ',':
+				break value
+			case depth == 0 && unicode.IsSpace(rune(ch)):
+				j := i
+				for j < len(args) && unicode.IsSpace(rune(args[j])) {
+					j++
+				}
+				if name, op, _ := scanAssignTarget(args, j); name != "" && op != "" {
+					break value
+				}
+			}
+		}
+		if quote != 0 || depth != 0 {
+			return nil, fmt.Errorf("unterminated value for %q", name)
+		}
+		expr := strings.TrimSpace(args[valueStart:i])
+		if expr == "" {
+			return nil, fmt.Errorf("%q requires a value", name)
+		}
+		out = append(out, ast.Assignment{Name: name, Op: op, Expr: expr})
+	}
+}
+
+// scanAssignTarget reads "name op" at i and returns the index after op; op
+// is empty when none follows (== is a comparison, not an assignment).
+func scanAssignTarget(args string, i int) (string, string, int) {
+	start := i
+	for i < len(args) && (unicode.IsLetter(rune(args[i])) || args[i] == '_' || (i > start && unicode.IsDigit(rune(args[i])))) {
+		i++
+	}
+	name := args[start:i]
+	if name == "" {
+		return "", "", i
+	}
+	for i < len(args) && unicode.IsSpace(rune(args[i])) {
+		i++
+	}
+	for _, op := range assignOperators {
+		if strings.HasPrefix(args[i:], op) && !strings.HasPrefix(args[i:], "==") {
+			return name, op, i + len(op)
+		}
+	}
+	return name, "", i
+}
+
+// parseSetting parses setting/ftl directives into key=value parameters.
+func parseSetting(file string, tok lexer.Token) (ast.Node, error) {
+	raw := strings.TrimSpace(tok.Args)
+	params, err := parseSettingParams(raw)
+	if err != nil {
+		return nil, diagnostics.New("PARSE_INVALID_SETTING", file, tok.PosLine, tok.PosCol, err.Error(), tok.Raw)
+	}
+	if tok.Name == "setting" && len(params) != 1 {
+		return nil, diagnostics.New("PARSE_INVALID_SETTING", file, tok.PosLine, tok.PosCol, "setting must be '<#setting name=value>'", tok.Raw)
+	}
+	if tok.Name == "ftl" {
+		raw = "ftl " + raw
+	}
+	return ast.SettingNode{
+		Position:  ast.Position{Line: tok.PosLine, Column: tok.PosCol},
+		Directive: tok.Name,
+		Raw:       raw,
+		Params:    params,
 	}, nil
 }
 
+// parseSettingParams splits "a=1 b='x', c=true" into ordered key/value pairs.
+func parseSettingParams(args string) ([]ast.SettingParam, error) {
+	var params []ast.SettingParam
+	i := 0
+	for {
+		for i < len(args) && (args[i] == ',' || unicode.IsSpace(rune(args[i]))) {
+			i++
+		}
+		if i >= len(args) {
+			return params, nil
+		}
+
+		start := i
+		for i < len(args) && (unicode.IsLetter(rune(args[i])) || unicode.IsDigit(rune(args[i])) || args[i] == '_') {
+			i++
+		}
+		key := args[start:i]
+		if key == "" {
+			return nil, fmt.Errorf("expected setting name at %q", args[start:])
+		}
+		for i < len(args) && unicode.IsSpace(rune(args[i])) {
+			i++
+		}
+		if i >= len(args) || args[i] != '=' {
+			return nil, fmt.Errorf("setting %q must be followed by '='", key)
+		}
+		i++
+		for i < len(args) && unicode.IsSpace(rune(args[i])) {
+			i++
+		}
+
+		valueStart := i
+		depth := 0
+		quote := byte(0)
+		escaped := false
+		for ; i < len(args); i++ {
+			ch := args[i]
+			if quote != 0 {
+				if escaped {
+					escaped = false
+				} else if ch == '\\' {
+					escaped = true
+				} else if ch == quote {
+					quote = 0
+				}
+				continue
+			}
+			if ch == '"' || ch == '\'' {
+				quote = ch
+				continue
+			}
+			if ch == '{' || ch == '[' || ch == '(' {
+				depth++
+				continue
+			}
+			if ch == '}' || ch == ']' || ch == ')' {
+				depth--
+				continue
+			}
+			if depth == 0 && (ch == ',' || unicode.IsSpace(rune(ch))) {
+				break
+			}
+		}
+		if quote != 0 || depth != 0 {
+			return nil, fmt.Errorf("unterminated value for setting %q", key)
+		}
+		value := args[valueStart:i]
+		if value == "" {
+			return nil, fmt.Errorf("setting %q requires a value", key)
+		}
+		params = append(params, ast.SettingParam{Key: key, Value: value})
+	}
+}
+
 // Parse converts lexer tokens into an AST document.
 func Parse(file string, tokens []lexer.Token) (ast.Document, error) {
 	s := &state{
@@ -92,6 +306,12 @@ func (s *state) parseNodes(stoppers map[string]struct{}) ([]ast.Node, *lexer.Tok
 				AltStyle: tok.AltStyle,
 			})
 
+		case lexer.TokenComment:
+			nodes = append(nodes, ast.CommentNode{
+				Position: ast.Position{Line: tok.PosLine, Column: tok.PosCol},
+				Text:     tok.Value,
+			})
+
 		case lexer.TokenMacroCall:
 			nodes = append(nodes, ast.MacroCallNode{
 				Position: ast.Position{Line: tok.PosLine, Column: tok.PosCol},
@@ -133,17 +353,45 @@ func (s *state) parseDirective(tok lexer.Token) (ast.Node, error) {
 		return s.parseIf(tok)
 	case "list":
 		return s.parseList(tok)
-	case "assign":
-		return parseAssign(s.file, tok, false)
-	case "local":
-		return parseAssign(s.file, tok, true)
-	case "setting":
-		return ast.SettingNode{Position: pos, Raw: strings.TrimSpace(tok.Args)}, nil
-	case "ftl":
-		return ast.SettingNode{Position: pos, Raw: "ftl " + strings.TrimSpace(tok.Args)}, nil
+	case "assign", "local", "global":
+		return s.parseAssign(tok)
+	case "setting", "ftl":
+		return parseSetting(s.file, tok)
 	case "function":
 		return s.parseFunction(tok)
-	case "return", "break":
+	case "attempt":
+		return s.parseAttempt(tok)
+	case "compress":
+		body, err := s.parseBlock(tok)
+		if err != nil {
+			return nil, err
+		}
+		return ast.CompressNode{Position: pos, Body: body}, nil
+	case "escape":
+		return s.parseEscape(tok)
+	case "noescape":
+		body, err := s.parseBlock(tok)
+		if err != nil {
+			return nil, err
+		}
+		return ast.NoEscapeNode{Position: pos, Body: body}, nil
+	case "outputformat":
+		format := strings.TrimSpace(tok.Args)
+		if format == "" {
+			return nil, diagnostics.New("PARSE_INVALID_OUTPUTFORMAT", s.file, tok.PosLine, tok.PosCol, "outputformat directive requires a format name", tok.Raw)
+		}
+		body, err := s.parseBlock(tok)
+		if err != nil {
+			return nil, err
+		}
+		return ast.OutputFormatNode{Position: pos, Format: format, Body: body}, nil
+	case "autoesc", "noautoesc":
+		body, err := s.parseBlock(tok)
+		if err != nil {
+			return nil, err
+		}
+		return ast.AutoEscNode{Position: pos, Enabled: tok.Name == "autoesc", Body: body}, nil
+	case "return", "break", "continue", "stop", "flush":
 		return ast.BareDirectiveNode{Position: pos, Name: tok.Name, Args: strings.TrimSpace(tok.Args)}, nil
 	default:
 		return nil, diagnostics.New(
@@ -253,6 +501,79 @@ func (s *state) parseList(tok lexer.Token) (ast.Node, error) {
 	}, nil
 }
 
+// parseBlock parses the body of a block directive without intermediate
+// directives, up to its closing tag.
+func (s *state) parseBlock(tok lexer.Token) ([]ast.Node, error) {
+	body, stop, err := s.parseNodes(map[string]struct{}{
+		"close:" + tok.Name: {},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if stop == nil {
+		return nil, diagnostics.New(
+			"PARSE_UNCLOSED_"+strings.ToUpper(tok.Name),
+			s.file,
+			tok.PosLine,
+			tok.PosCol,
+			fmt.Sprintf("%s directive not closed", tok.Name),
+			tok.Raw,
+		)
+	}
+	return body, nil
+}
+
+// parseEscape parses <#escape x as expr>...</#escape> blocks.
+func (s *state) parseEscape(tok lexer.Token) (ast.Node, error) {
+	match := escapeDirectiveRe.FindStringSubmatch(strings.TrimSpace(tok.Args))
+	if len(match) != 3 {
+		return nil, diagnostics.New("PARSE_INVALID_ESCAPE", s.file, tok.PosLine, tok.PosCol, "escape directive must be '<#escape name as expr>'", tok.Raw)
+	}
+	body, err := s.parseBlock(tok)
+	if err != nil {
+		return nil, err
+	}
+
+	return ast.EscapeNode{
+		Position: ast.Position{Line: tok.PosLine, Column: tok.PosCol},
+		Var:      match[1],
+		Expr:     strings.TrimSpace(match[2]),
+		Body:     body,
+	}, nil
+}
+
+// parseAttempt parses <#attempt>...<#recover>...</#attempt> blocks.
+func (s *state) parseAttempt(tok lexer.Token) (ast.Node, error) {
+	body, stop, err := s.parseNodes(map[string]struct{}{
+		"dir:recover":   {},
+		"close:attempt": {},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if stop == nil {
+		return nil, diagnostics.New("PARSE_UNCLOSED_ATTEMPT", s.file, tok.PosLine, tok.PosCol, "attempt directive not closed", tok.Raw)
+	}
+	if stop.Closing {
+		return nil, diagnostics.New("PARSE_INVALID_ATTEMPT", s.file, tok.PosLine, tok.PosCol, "attempt directive requires a recover block", tok.Raw)
+	}
+	recover, stop, err := s.parseNodes(map[string]struct{}{
+		"close:attempt": {},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if stop == nil {
+		return nil, diagnostics.New("PARSE_UNCLOSED_ATTEMPT", s.file, tok.PosLine, tok.PosCol, "attempt directive not closed", tok.Raw)
+	}
+
+	return ast.AttemptNode{
+		Position: ast.Position{Line: tok.PosLine, Column: tok.PosCol},
+		Body:     body,
+		Recover:  recover,
+	}, nil
+}
+
 // parseFunction parses <#function ...> blocks.
 func (s *state) parseFunction(tok lexer.Token) (ast.Node, error) {
 	parts := strings.Fields(tok.Args)
@@ -27,3 +27,82 @@ func TestParseIfElseAndList(t *testing.T) {
 	_, ok = ifNode.Then[0].(ast.ListNode)
 	require.True(t, ok)
 }
+
+func TestParseSettingParams(t *testing.T) {
+	src := `<#ftl encoding="UTF-8" output_format='HTML', strip_whitespace=true><#setting number_format="#,##0.00">`
+	tokens, err := lexer.Lex("sample.ftl", src)
+	require.NoError(t, err)
+
+	doc, err := Parse("sample.ftl", tokens)
+	require.NoError(t, err)
+	require.Len(t, doc.Nodes, 2)
+
+	header, ok := doc.Nodes[0].(ast.SettingNode)
+	require.True(t, ok)
+	require.Equal(t, "ftl", header.Directive)
+	require.Equal(t, []ast.SettingParam{
+		{Key: "encoding", Value: `"UTF-8"`},
+		{Key: "output_format", Value: `'HTML'`},
+		{Key: "strip_whitespace", Value: "true"},
+	}, header.Params)
+
+	setting, ok := doc.Nodes[1].(ast.SettingNode)
+	require.True(t, ok)
+	require.Equal(t, "setting", setting.Directive)
+	require.Equal(t, []ast.SettingParam{{Key: "number_format", Value: `"#,##0.00"`}}, setting.Params)
+
+	for _, bad := range []string{`<#setting locale>`, `<#setting a=1 b=2>`, `<#setting =x>`} {
+		tokens, err := lexer.Lex("bad.ftl", bad)
+		require.NoError(t, err, bad)
+		_, err = Parse("bad.ftl", tokens)
+		require.Error(t, err, bad)
+	}
+}
+
+func TestParseAttempt(t *testing.T) {
+	tokens, err := lexer.Lex("sample.ftl", `<#attempt>${a}<#recover>failed</#attempt>`)
+	require.NoError(t, err)
+	doc, err := Parse("sample.ftl", tokens)
+	require.NoError(t, err)
+	require.Len(t, doc.Nodes, 1)
+	attempt := doc.Nodes[0].(ast.AttemptNode)
+	require.Len(t, attempt.Body, 1)
+	require.Equal(t, []ast.Node{ast.TextNode{Position: ast.Position{Line: 1, Column: 25}, Text: "failed"}}, attempt.Recover)
+}
+
+func TestParseAssignForms(t *testing.T) {
+	src := `<#assign a=1 b = a + 2, c="x y" d++><#global g += 1><#local l = x gt y><#assign body>Hi ${name}</#assign>`
+	tokens, err := lexer.Lex("sample.ftl", src)
+	require.NoError(t, err)
+	doc, err := Parse("sample.ftl", tokens)
+	require.NoError(t, err)
+	require.Len(t, doc.Nodes, 4)
+
+	multi := doc.Nodes[0].(ast.AssignNode)
+	require.Equal(t, []ast.Assignment{
+		{Name: "a", Op: "=", Expr: "1"},
+		{Name: "b", Op: "=", Expr: "a + 2"},
+		{Name: "c", Op: "=", Expr: `"x y"`},
+		{Name: "d", Op: "++"},
+	}, multi.Assignments)
+
+	global := doc.Nodes[1].(ast.AssignNode)
+	require.True(t, global.Global)
+	require.Equal(t, []ast.Assignment{{Name: "g", Op: "+=", Expr: "1"}}, global.Assignments)
+
+	local := doc.Nodes[2].(ast.AssignNode)
+	require.True(t, local.Local)
+	require.Equal(t, "x gt y", local.Assignments[0].Expr)
+
+	capture := doc.Nodes[3].(ast.AssignNode)
+	require.True(t, capture.Capture)
+	require.Equal(t, []ast.Assignment{{Name: "body"}}, capture.Assignments)
+	require.Len(t, capture.Body, 2)
+
+	for _, bad := range []string{`<#assign>`, `<#assign a = >`, `<#assign a == 1>`, `<#assign x>never closed`} {
+		tokens, err := lexer.Lex("bad.ftl", bad)
+		require.NoError(t, err, bad)
+		_, err = Parse("bad.ftl", tokens)
+		require.Error(t, err, bad)
+	}
+}
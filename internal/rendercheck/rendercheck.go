@@ -4,13 +4,16 @@ package rendercheck
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
-	"math"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/cruffinoni/ftl2gotpl/internal/convert"
+	"github.com/cruffinoni/ftl2gotpl/internal/decimal"
+	"github.com/cruffinoni/ftl2gotpl/internal/diagnostics"
 )
 
 // Status reports the outcome of render validation for one converted template.
@@ -21,11 +24,18 @@ const (
 	StatusNoSample Status = "no_sample"
 )
 
+// ReferenceTime is the fixed clock reading returned by .now during render
+// checks so that rendered output stays reproducible between runs.
+var ReferenceTime = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
 // SamplePath returns the sidecar JSON sample path for a template relative path.
 func SamplePath(samplesRoot string, relTemplatePath string) string {
 	return filepath.Join(samplesRoot, relTemplatePath+".json")
 }
 
+// normalizeJSONNumbers replaces JSON numbers with int64 when they are
+// integral and fit, and with exact decimals otherwise, so sample values keep
+// their precision through template arithmetic and formatting.
 func normalizeJSONNumbers(value any) any {
 	switch v := value.(type) {
 	case map[string]any:
@@ -39,17 +49,14 @@ func normalizeJSONNumbers(value any) any {
 		}
 		return v
 	case json.Number:
-		if i, err := v.Int64(); err == nil {
-			return i
-		}
-		f, err := v.Float64()
+		d, err := decimal.Parse(string(v))
 		if err != nil {
 			return v
 		}
-		if f == math.Trunc(f) && f >= math.MinInt64 && f <= math.MaxInt64 {
-			return int64(f)
+		if i, ok := d.Int64(); ok {
+			return i
 		}
-		return f
+		return d
 	default:
 		return value
 	}
@@ -57,6 +64,12 @@ func normalizeJSONNumbers(value any) any {
 
 // RenderConvertedTemplate parses and executes converted content.
 func RenderConvertedTemplate(name string, content string, samplePath string) (Status, string, error) {
+	return RenderConvertedTemplateWithDelims(name, content, samplePath, convert.Delims{})
+}
+
+// RenderConvertedTemplateWithDelims parses and executes content converted
+// with custom action delimiters.
+func RenderConvertedTemplateWithDelims(name string, content string, samplePath string, delims convert.Delims) (Status, string, error) {
 	raw, err := os.ReadFile(samplePath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -74,13 +87,37 @@ func RenderConvertedTemplate(name string, content string, samplePath string) (St
 	}
 	payload = normalizeJSONNumbers(payload)
 
-	t, err := template.New(name).Funcs(convert.StubFuncMap()).Parse(content)
+	funcs := convert.NewFuncMap(convert.FuncMapOptions{
+		Now: func() time.Time { return ReferenceTime },
+	})
+	t, err := template.New(name).Delims(delims.Left, delims.Right).Funcs(funcs).Parse(content)
 	if err != nil {
 		return StatusNoSample, "", fmt.Errorf("parse converted template %q before render: %w", name, err)
 	}
+	convert.BindTemplate(t)
 
 	var buf bytes.Buffer
 	if err := t.Execute(&buf, payload); err != nil {
+		if errors.Is(err, convert.ErrNonBooleanCondition) {
+			return StatusNoSample, "", diagnostics.New(
+				"RENDER_NON_BOOLEAN_CONDITION",
+				name,
+				0,
+				0,
+				fmt.Sprintf("render template with sample %q: %v", samplePath, err),
+				"",
+			)
+		}
+		if errors.Is(err, convert.ErrStopped) {
+			return StatusNoSample, "", diagnostics.New(
+				"RENDER_STOPPED",
+				name,
+				0,
+				0,
+				fmt.Sprintf("render template with sample %q: %v", samplePath, err),
+				"",
+			)
+		}
 		return StatusNoSample, "", fmt.Errorf("render template %q with sample %q: %w", name, samplePath, err)
 	}
 
@@ -6,6 +6,8 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/cruffinoni/ftl2gotpl/internal/decimal"
+	"github.com/cruffinoni/ftl2gotpl/internal/diagnostics"
 	"github.com/stretchr/testify/require"
 )
 
@@ -60,7 +62,42 @@ func TestNormalizeJSONNumbersNestedStructures(t *testing.T) {
 	require.Equal(t, int64(3), nestedSlice[0])
 
 	nestedMap := nestedSlice[1].(map[string]any)
-	require.Equal(t, 3.25, nestedMap["float"])
+	require.Equal(t, decimal.MustParse("3.25"), nestedMap["float"])
 
 	require.Equal(t, json.Number("not-a-number"), got["bad_number"])
 }
+
+func TestRenderConvertedTemplateKeepsDecimalPrecision(t *testing.T) {
+	root := t.TempDir()
+	samplePath := filepath.Join(root, "sample.json")
+	require.NoError(t, os.WriteFile(samplePath, []byte(`{"price":19.99,"qty":3,"big":12345678901234567890.05}`), 0o644))
+
+	status, htmlOut, err := RenderConvertedTemplate("tpl",
+		`{{computerString (multiply .price .qty)}}|{{computerString (add .big 0.95)}}|{{computerString (subtract 0.3 0.1)}}`, samplePath)
+	require.NoError(t, err)
+	require.Equal(t, StatusRendered, status)
+	require.Equal(t, "59.97|12345678901234567891|0.2", htmlOut)
+}
+
+func TestRenderConvertedTemplateUsesReferenceClock(t *testing.T) {
+	root := t.TempDir()
+	samplePath := filepath.Join(root, "sample.json")
+	require.NoError(t, os.WriteFile(samplePath, []byte(`{}`), 0o644))
+
+	status, htmlOut, err := RenderConvertedTemplate("tpl", `{{(now).Format "2006-01-02"}}`, samplePath)
+	require.NoError(t, err)
+	require.Equal(t, StatusRendered, status)
+	require.Equal(t, ReferenceTime.Format("2006-01-02"), htmlOut)
+}
+
+func TestRenderConvertedTemplateReportsStop(t *testing.T) {
+	root := t.TempDir()
+	samplePath := filepath.Join(root, "sample.json")
+	require.NoError(t, os.WriteFile(samplePath, []byte(`{}`), 0o644))
+
+	_, _, err := RenderConvertedTemplate("tpl", `a{{stop "no recipient"}}`, samplePath)
+	var diag diagnostics.Diagnostic
+	require.ErrorAs(t, err, &diag)
+	require.Equal(t, "RENDER_STOPPED", diag.Code)
+	require.Contains(t, diag.Message, "no recipient")
+}
@@ -55,6 +55,10 @@ type Summary struct {
 	RenderFailed     int      `json:"render_failed"`
 	NoSample         int      `json:"no_sample"`
 	HelpersNeeded    []string `json:"helpers_needed,omitempty"`
+	// LeftDelim and RightDelim are the action delimiters to parse the
+	// converted templates with.
+	LeftDelim  string `json:"left_delim,omitempty"`
+	RightDelim string `json:"right_delim,omitempty"`
 }
 
 // JSONReport is the structured report persisted by --report-json.
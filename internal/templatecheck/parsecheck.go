@@ -10,7 +10,13 @@ import (
 
 // ParseConvertedTemplate verifies html/template parsing for converted content.
 func ParseConvertedTemplate(name string, content string) error {
-	t := template.New(name).Funcs(convert.StubFuncMap())
+	return ParseConvertedTemplateWithDelims(name, content, convert.Delims{})
+}
+
+// ParseConvertedTemplateWithDelims verifies parsing for content converted with
+// custom action delimiters.
+func ParseConvertedTemplateWithDelims(name string, content string, delims convert.Delims) error {
+	t := template.New(name).Delims(delims.Left, delims.Right).Funcs(convert.StubFuncMap())
 	if _, err := t.Parse(content); err != nil {
 		return fmt.Errorf("parse converted template %q: %w", name, err)
 	}